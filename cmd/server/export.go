@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"taskservice/internal/config"
+	"taskservice/internal/model"
+	"taskservice/internal/statefile"
+	"taskservice/internal/storage"
+)
+
+// Выгружает задачи, расписания и ссылки на файлы из хранилища в файл выгрузки
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "-", "output file (- for stdout)")
+	statuses := fs.String("status", "", "comma separated task statuses to export")
+	since := fs.String("since", "", "export tasks created at or after this time (RFC3339)")
+	until := fs.String("until", "", "export tasks created before this time (RFC3339)")
+	_ = fs.Parse(args)
+
+	cfg := config.Load()
+	setupLogger(cfg)
+	filter, err := parseFilter(*statuses, *since, *until)
+	if err != nil {
+		fatal("invalid filter", "error", err)
+	}
+
+	st, err := storage.NewStore(cfg.StateDir)
+	if err != nil {
+		fatal("failed to init storage", "error", err)
+	}
+	defer st.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal("failed to create output", "error", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	state := statefile.Select(statefile.State{Tasks: st.ListTasks(), Schedules: st.ListSchedules(), Blobs: st.ListBlobs()}, filter)
+	if err := statefile.Write(w, state); err != nil {
+		fatal("export error", "error", err)
+	}
+	slog.Info("export complete", "tasks", len(state.Tasks), "schedules", len(state.Schedules), "blobs", len(state.Blobs))
+}
+
+// Разбирает параметры фильтра из флагов командной строки
+func parseFilter(statuses, since, until string) (statefile.Filter, error) {
+	var f statefile.Filter
+	for _, s := range strings.Split(statuses, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			f.Statuses = append(f.Statuses, model.TaskStatus(s))
+		}
+	}
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return f, fmt.Errorf("since: %w", err)
+		}
+		f.Since = t
+	}
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return f, fmt.Errorf("until: %w", err)
+		}
+		f.Until = t
+	}
+	return f, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"taskservice/internal/config"
+	"taskservice/internal/model"
+	"taskservice/internal/statefile"
+	"taskservice/internal/storage"
+	"taskservice/internal/util"
+)
+
+// Политики обработки конфликтов ID при импорте
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictNewID     = "new-id"
+	conflictFail      = "fail"
+)
+
+// Загружает задачи, расписания и ссылки на файлы из файла выгрузки в хранилище.
+// Сервер должен быть остановлен на время импорта.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "-", "input file (- for stdin)")
+	mode := fs.String("mode", "merge", "merge or replace")
+	onConflict := fs.String("on-conflict", conflictSkip, "skip, overwrite, new-id or fail")
+	statuses := fs.String("status", "", "comma separated task statuses to import")
+	since := fs.String("since", "", "import tasks created at or after this time (RFC3339)")
+	until := fs.String("until", "", "import tasks created before this time (RFC3339)")
+	_ = fs.Parse(args)
+
+	cfg := config.Load()
+	setupLogger(cfg)
+	if *mode != "merge" && *mode != "replace" {
+		fatal("invalid mode", "mode", *mode)
+	}
+	switch *onConflict {
+	case conflictSkip, conflictOverwrite, conflictNewID, conflictFail:
+	default:
+		fatal("invalid on-conflict policy", "on_conflict", *onConflict)
+	}
+	filter, err := parseFilter(*statuses, *since, *until)
+	if err != nil {
+		fatal("invalid filter", "error", err)
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			fatal("failed to open input", "error", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	h, state, err := statefile.Read(r)
+	if err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	// Файл старой версии не содержит расписаний и ссылок на файлы: замена по нему удалила бы их
+	if *mode == "replace" && !h.HasFullState() {
+		fatal("replace mode needs an export with schedules and blob refs; re-export or use merge",
+			"file_version", h.Version, "required_version", statefile.Version)
+	}
+	state = statefile.Select(state, filter)
+
+	st, err := storage.NewStore(cfg.StateDir)
+	if err != nil {
+		fatal("failed to init storage", "error", err)
+	}
+	defer st.Close()
+
+	if *mode == "replace" {
+		if err := st.ReplaceAll(state.Tasks, state.Schedules, state.Blobs); err != nil {
+			fatal("import error", "error", err)
+		}
+		slog.Info("state replaced", "tasks", len(state.Tasks), "schedules", len(state.Schedules), "blobs", len(state.Blobs))
+		return
+	}
+
+	stats, err := mergeState(st, state, *onConflict)
+	if err != nil {
+		fatal("import error", "error", err)
+	}
+	slog.Info("import complete",
+		"tasks", stats.tasks, "skipped_tasks", stats.skippedTasks, "new_task_ids", stats.renamedTasks,
+		"schedules", stats.schedules, "skipped_schedules", stats.skippedSchedules, "new_schedule_ids", stats.renamedSchedules)
+}
+
+// Итоги импорта в режиме merge
+type importStats struct {
+	tasks, skippedTasks, renamedTasks             int
+	schedules, skippedSchedules, renamedSchedules int
+}
+
+// Добавляет содержимое файла выгрузки к хранилищу, разрешая конфликты ID политикой onConflict.
+// Расписания импортируются первыми, чтобы задачи ссылались на их итоговые ID; ссылки на файлы
+// добавляются для импортированных задач под их итоговыми ID
+func mergeState(st *storage.Store, s statefile.State, onConflict string) (importStats, error) {
+	var stats importStats
+	// Конфликты проверяются до первой записи, чтобы неудачный импорт не оставлял хранилище наполовину обновленным
+	if onConflict == conflictFail {
+		if err := checkConflicts(st, s); err != nil {
+			return stats, err
+		}
+	}
+	scheduleIDs := make(map[model.ScheduleID]model.ScheduleID)
+	for _, sc := range s.Schedules {
+		if _, exists := st.GetSchedule(sc.ID); exists {
+			switch onConflict {
+			case conflictSkip:
+				stats.skippedSchedules++
+				continue
+			case conflictNewID:
+				id := model.ScheduleID(util.NewID())
+				scheduleIDs[sc.ID] = id
+				sc.ID = id
+				stats.renamedSchedules++
+			case conflictOverwrite:
+			}
+		}
+		if err := st.UpsertSchedule(sc); err != nil {
+			return stats, err
+		}
+		stats.schedules++
+	}
+
+	// Итоговые ID задач определяются до записи, чтобы зависимости ссылались на них
+	taskIDs := make(map[model.TaskID]model.TaskID)
+	var tasks []*model.Task
+	replaced := make(map[model.TaskID]bool)
+	for _, t := range s.Tasks {
+		id := t.ID
+		if _, exists := st.GetTask(t.ID); exists {
+			switch onConflict {
+			case conflictSkip:
+				stats.skippedTasks++
+				continue
+			case conflictNewID:
+				t.ID = model.TaskID(util.NewID())
+				stats.renamedTasks++
+			case conflictOverwrite:
+				replaced[t.ID] = true
+			}
+		}
+		taskIDs[id] = t.ID
+		tasks = append(tasks, t)
+	}
+	for _, t := range tasks {
+		if replaced[t.ID] {
+			// Ссылки заменяемой задачи заменяются ссылками из файла; файлы без ссылок удалит janitor
+			if _, err := st.ReleaseBlobRefs(t.ID); err != nil {
+				return stats, err
+			}
+		}
+		if renamed, ok := scheduleIDs[t.ScheduleID]; ok {
+			t.ScheduleID = renamed
+		}
+		if len(t.Options.DependsOn) > 0 {
+			deps := make([]model.TaskID, len(t.Options.DependsOn))
+			for i, dep := range t.Options.DependsOn {
+				if renamed, ok := taskIDs[dep]; ok {
+					dep = renamed
+				}
+				deps[i] = dep
+			}
+			t.Options.DependsOn = deps
+		}
+		if err := st.UpsertTask(t); err != nil {
+			return stats, err
+		}
+		stats.tasks++
+	}
+
+	for _, b := range s.Blobs {
+		for _, ref := range b.Refs {
+			id, ok := taskIDs[ref.TaskID]
+			if !ok {
+				continue
+			}
+			if err := st.AddBlobRef(b.SHA256, b.Size, storage.BlobRef{TaskID: id, ItemIdx: ref.ItemIdx}); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Ищет в файле выгрузки расписания и задачи, ID которых уже заняты в хранилище
+func checkConflicts(st *storage.Store, s statefile.State) error {
+	for _, sc := range s.Schedules {
+		if _, exists := st.GetSchedule(sc.ID); exists {
+			return fmt.Errorf("schedule %s already exists", sc.ID)
+		}
+	}
+	for _, t := range s.Tasks {
+		if _, exists := st.GetTask(t.ID); exists {
+			return fmt.Errorf("task %s already exists", t.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"taskservice/internal/model"
+	"taskservice/internal/statefile"
+	"taskservice/internal/storage"
+)
+
+func TestMergeStateRemapsBlobRefsAndSchedules(t *testing.T) {
+	st, err := storage.NewStore(filepath.Join(t.TempDir(), "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.UpsertTask(&model.Task{ID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpsertSchedule(&model.Schedule{ID: "s1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := statefile.State{
+		Tasks: []*model.Task{
+			{ID: "t1", ScheduleID: "s1"},
+			{ID: "t2", Options: model.TaskOptions{DependsOn: []model.TaskID{"t1"}}},
+		},
+		Schedules: []*model.Schedule{{ID: "s1"}},
+		Blobs:     []storage.Blob{{SHA256: "aa", Size: 1, Refs: []storage.BlobRef{{TaskID: "t1"}, {TaskID: "t2", ItemIdx: 1}}}},
+	}
+	stats, err := mergeState(st, s, conflictNewID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.tasks != 2 || stats.renamedTasks != 1 || stats.schedules != 1 || stats.renamedSchedules != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+	renamed := s.Tasks[0]
+	if renamed.ID == "t1" || renamed.ScheduleID == "s1" {
+		t.Fatalf("conflicting task kept its ids: %+v", renamed)
+	}
+	if _, ok := st.GetSchedule(renamed.ScheduleID); !ok {
+		t.Fatalf("task refers to missing schedule %s", renamed.ScheduleID)
+	}
+	if dependent, _ := st.GetTask("t2"); len(dependent.Options.DependsOn) != 1 || dependent.Options.DependsOn[0] != renamed.ID {
+		t.Fatalf("dependent task depends on %v, want [%s]", dependent.Options.DependsOn, renamed.ID)
+	}
+	var refs []storage.BlobRef
+	for _, b := range st.ListBlobs() {
+		refs = append(refs, b.Refs...)
+	}
+	if len(refs) != 2 || refs[0] != (storage.BlobRef{TaskID: renamed.ID}) || refs[1] != (storage.BlobRef{TaskID: "t2", ItemIdx: 1}) {
+		t.Fatalf("blob refs %+v, want refs under the imported task ids", refs)
+	}
+}
+
+func TestMergeStateFailsOnConflict(t *testing.T) {
+	st, err := storage.NewStore(filepath.Join(t.TempDir(), "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := st.UpsertTask(&model.Task{ID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	s := statefile.State{
+		Tasks:     []*model.Task{{ID: "t0"}, {ID: "t1"}},
+		Schedules: []*model.Schedule{{ID: "s1"}},
+	}
+	if _, err := mergeState(st, s, conflictFail); err == nil {
+		t.Fatal("conflicting task was imported")
+	}
+	if _, ok := st.GetTask("t0"); ok {
+		t.Fatal("task before the conflict was imported")
+	}
+	if _, ok := st.GetSchedule("s1"); ok {
+		t.Fatal("schedule was imported despite the conflict")
+	}
+}
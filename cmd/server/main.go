@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,27 +11,100 @@ import (
 	"time"
 
 	"taskservice/internal/config"
+	"taskservice/internal/download"
+	"taskservice/internal/egress"
 	"taskservice/internal/httpapi"
 	"taskservice/internal/manager"
+	"taskservice/internal/model"
+	"taskservice/internal/sink"
 	"taskservice/internal/storage"
 )
 
 func main() {
+	// Подкоманды обслуживания состояния; без подкоманды запускается сервер
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "serve":
+		default:
+			fatal("unknown command (expected serve, export or import)", "command", os.Args[1])
+		}
+	}
+	runServer()
+}
+
+// Запускает HTTP сервер и менеджер загрузок
+func runServer() {
 	cfg := config.Load()
 
+	// Журнал сервиса
+	logger := setupLogger(cfg)
+
 	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
-		log.Fatalf("failed to create data dir: %v", err)
+		fatal("failed to create data dir", "error", err)
 	}
 	if err := os.MkdirAll(cfg.StateDir, 0o755); err != nil {
-		log.Fatalf("failed to create state dir: %v", err)
+		fatal("failed to create state dir", "error", err)
 	}
 
 	// Инициализация хранилища
 	st, err := storage.NewStore(cfg.StateDir)
 	if err != nil {
-		log.Fatalf("failed to init storage: %v", err)
+		fatal("failed to init storage", "error", err)
 	}
 	defer st.Close()
+	st.SetHistoryRetention(storage.HistoryRetention{MaxEntries: cfg.HistoryMaxEntries, MaxAge: cfg.HistoryMaxAge})
+
+	// Внешние приемники файлов
+	sinks := map[string]sink.Sink{}
+	if cfg.S3Bucket != "" {
+		s3, err := sink.NewS3(sink.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			Prefix:    cfg.S3Prefix,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+			PartSize:  cfg.S3PartSize,
+		}, st)
+		if err != nil {
+			fatal("failed to init s3 sink", "error", err)
+		}
+		sinks["s3"] = s3
+	}
+
+	// Политика исходящих соединений загрузчиков
+	policy, err := egress.New(cfg.EgressAllow, cfg.EgressDeny, cfg.EgressAllowPrivate)
+	if err != nil {
+		fatal("invalid egress policy", "error", err)
+	}
+
+	// Транспорт загрузчика http
+	hosts, err := download.ParseHostOverrides(cfg.DownloadHosts)
+	if err != nil {
+		fatal("invalid DOWNLOAD_HOSTS", "error", err)
+	}
+	transport := download.TransportConfig{
+		HostTransport: download.HostTransport{
+			Proxy:                 cfg.DownloadProxy,
+			CAFile:                cfg.DownloadCAFile,
+			ClientCert:            cfg.DownloadClientCert,
+			ClientKey:             cfg.DownloadClientKey,
+			InsecureSkipVerify:    &cfg.DownloadInsecureSkipVerify,
+			ConnectTimeout:        cfg.DownloadConnectTimeout,
+			TLSHandshakeTimeout:   cfg.DownloadTLSHandshakeTimeout,
+			ResponseHeaderTimeout: cfg.DownloadResponseHeaderTimeout,
+		},
+		IdleConnTimeout:     cfg.DownloadIdleConnTimeout,
+		MaxIdleConnsPerHost: cfg.DownloadMaxIdleConnsPerHost,
+		Hosts:               hosts,
+	}
 
 	// Инициализация менеджера
 	mgr, err := manager.NewManager(manager.Config{
@@ -40,13 +114,44 @@ func main() {
 		MaxRetryPerItem: cfg.RetryMax,
 		BaseBackoff:     time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
 		SnapshotEveryN:  50,
+		Retention: map[model.TaskStatus]time.Duration{
+			model.TaskStatusCompleted: cfg.RetentionCompleted,
+			model.TaskStatusFailed:    cfg.RetentionFailed,
+			model.TaskStatusCanceled:  cfg.RetentionCanceled,
+		},
+		JanitorInterval:   cfg.JanitorInterval,
+		MaxItemBytes:      cfg.MaxItemBytes,
+		DiskQuotaBytes:    cfg.DiskQuotaBytes,
+		MinFreeBytes:      cfg.MinFreeBytes,
+		ResumeFreeBytes:   cfg.ResumeFreeBytes,
+		FileRoot:          cfg.FileRoot,
+		Sinks:             sinks,
+		DefaultSink:       cfg.Sink,
+		ProcessCommands:   cfg.ProcessCommands,
+		ProcessTimeout:    cfg.ProcessTimeout,
+		ProcessMaxBytes:   cfg.ProcessMaxBytes,
+		ProcessMaxEntries: cfg.ProcessMaxEntries,
+
+		ManifestMaxBytes: cfg.ManifestMaxBytes,
+		Egress:           policy,
+		Transport:        transport,
+
+		MaxRedirects:           cfg.RedirectMax,
+		AllowRedirectDowngrade: cfg.RedirectAllowDowngrade,
+
+		IdleTimeout:      cfg.DownloadIdleTimeout,
+		MinThroughput:    cfg.DownloadMinThroughput,
+		ThroughputWindow: cfg.DownloadThroughputWindow,
+		ItemDeadline:     cfg.DownloadItemDeadline,
+
+		Logger: logger,
 	})
 	if err != nil {
-		log.Fatalf("failed to init manager: %v", err)
+		fatal("failed to init manager", "error", err)
 	}
 
 	if err := mgr.Start(); err != nil {
-		log.Fatalf("failed to start manager: %v", err)
+		fatal("failed to start manager", "error", err)
 	}
 	defer mgr.StopAndWait(context.Background())
 
@@ -66,9 +171,9 @@ func main() {
 
 	// Запуск HTTP сервера
 	go func() {
-		log.Printf("HTTP server listening on :%s", cfg.Port)
+		slog.Info("HTTP server listening", "port", cfg.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+			fatal("server error", "error", err)
 		}
 	}()
 
@@ -76,23 +181,51 @@ func main() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
-	log.Printf("shutdown signal received")
+	slog.Info("shutdown signal received")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("server shutdown error: %v", err)
+		slog.Error("server shutdown error", "error", err)
 	}
 
 	if err := mgr.StopAndWait(ctx); err != nil {
-		log.Printf("manager stop error: %v", err)
+		slog.Error("manager stop error", "error", err)
 	}
 
 	// Сохранение снапшота
 	if err := st.SaveSnapshot(); err != nil {
-		log.Printf("snapshot save error: %v", err)
+		slog.Error("snapshot save error", "error", err)
 	}
 
-	log.Printf("shutdown complete")
+	slog.Info("shutdown complete")
+}
+
+// Создает журнал по LOG_FORMAT и LOG_LEVEL и делает его журналом по умолчанию; используется сервером и подкомандами
+func setupLogger(cfg *config.Config) *slog.Logger {
+	logger, err := newLogger(cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fatal("invalid LOG_FORMAT", "error", err)
+	}
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Создает журнал в формате text или json с заданным минимальным уровнем
+func newLogger(format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (expected text or json)", format)
+}
+
+// Записывает ошибку в журнал и завершает процесс
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
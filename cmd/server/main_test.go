@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+func TestNewLogger(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		logger, err := newLogger(format, slog.LevelWarn)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if logger.Enabled(context.Background(), slog.LevelInfo) || !logger.Enabled(context.Background(), slog.LevelWarn) {
+			t.Fatalf("%s: level is not applied", format)
+		}
+	}
+	if _, err := newLogger("xml", slog.LevelInfo); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
+
+func TestExportImportLogThroughSlog(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	st, err := storage.NewStore(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpsertTask(&model.Task{ID: "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+	t.Setenv("STATE_DIR", stateDir)
+	t.Setenv("LOG_FORMAT", "json")
+
+	// Журнал команд пишется в stderr, который подменяется файлом
+	logFile, err := os.Create(filepath.Join(dir, "log.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	stderr, logger := os.Stderr, slog.Default()
+	os.Stderr = logFile
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		slog.SetDefault(logger)
+	})
+
+	out := filepath.Join(dir, "state.jsonl")
+	runExport([]string{"-out", out})
+	runImport([]string{"-in", out, "-on-conflict", "skip"})
+
+	if _, err := logFile.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]map[string]any)
+	sc := bufio.NewScanner(logFile)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("log line %q is not json: %v", sc.Text(), err)
+		}
+		records[rec["msg"].(string)] = rec
+	}
+	if rec := records["export complete"]; rec == nil || rec["level"] != "INFO" || rec["tasks"] != 1.0 {
+		t.Fatalf("export log record %v, want INFO with tasks=1", rec)
+	}
+	if rec := records["import complete"]; rec == nil || rec["tasks"] != 0.0 || rec["skipped_tasks"] != 1.0 {
+		t.Fatalf("import log record %v, want tasks=0 and skipped_tasks=1", rec)
+	}
+}
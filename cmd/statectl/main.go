@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"taskservice/internal/config"
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+const usage = `usage: statectl [-state DIR] <command> [args]
+
+commands:
+  list [-status s1,s2]           list tasks
+  show <task-id>                 print a task as JSON
+  history <task-id>              print task state transitions
+  stats                          print task and item counters
+  wal [-task ID] [-from OFFSET]  dump WAL records with offsets
+  verify                         check snapshot and WAL consistency
+`
+
+func main() {
+	log.SetFlags(0)
+	stateDir := flag.String("state", "", "state directory (defaults to STATE_DIR)")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := *stateDir
+	if dir == "" {
+		dir = config.Load().StateDir
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "list":
+		cmdList(openStore(dir), args)
+	case "show":
+		cmdShow(openStore(dir), args)
+	case "history":
+		cmdHistory(openStore(dir), args)
+	case "stats":
+		cmdStats(openStore(dir))
+	case "wal":
+		cmdWal(dir, args)
+	case "verify":
+		cmdVerify(dir)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// Открывает хранилище только для чтения, не запуская менеджер
+func openStore(dir string) *storage.Store {
+	st, err := storage.OpenReadOnly(dir)
+	if err != nil {
+		log.Fatalf("failed to open state: %v", err)
+	}
+	return st
+}
+
+// Возвращает задачи в порядке создания
+func sortedTasks(st *storage.Store) []*model.Task {
+	tasks := st.ListTasks()
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// Список задач
+func cmdList(st *storage.Store, args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	statuses := fs.String("status", "", "comma separated task statuses")
+	_ = fs.Parse(args)
+	want := make(map[model.TaskStatus]bool)
+	for _, s := range strings.Split(*statuses, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			want[model.TaskStatus(s)] = true
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tCREATED\tITEMS\tDONE\tERRORS")
+	for _, t := range sortedTasks(st) {
+		if len(want) > 0 && !want[t.Status] {
+			continue
+		}
+		counts := itemCounts(t)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\n", t.ID, t.Status, t.CreatedAt.Format("2006-01-02 15:04:05"),
+			len(t.Items), counts[model.ItemStatusDone], counts[model.ItemStatusError])
+	}
+	tw.Flush()
+}
+
+// Подробности задачи
+func cmdShow(st *storage.Store, args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: statectl show <task-id>")
+	}
+	t, ok := st.GetTask(model.TaskID(args[0]))
+	if !ok {
+		log.Fatalf("task %s not found", args[0])
+	}
+	printJSON(t)
+}
+
+// История переходов задачи
+func cmdHistory(st *storage.Store, args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: statectl history <task-id>")
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ	AT	ACTOR	ITEM	FROM	TO	ATTEMPT	ERROR")
+	for _, tr := range st.GetHistory(model.TaskID(args[0])) {
+		item := "-"
+		if tr.ItemIdx != nil {
+			item = fmt.Sprint(*tr.ItemIdx)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", tr.Seq, tr.At.Format(time.RFC3339), tr.Actor, item,
+			tr.From, tr.To, tr.Attempt, tr.Error)
+	}
+	tw.Flush()
+}
+
+// Сводка по задачам и элементам
+func cmdStats(st *storage.Store) {
+	tasks := st.ListTasks()
+	byTask := make(map[model.TaskStatus]int)
+	byItem := make(map[model.ItemStatus]int)
+	var downloaded int64
+	for _, t := range tasks {
+		byTask[t.Status]++
+		for s, n := range itemCounts(t) {
+			byItem[s] += n
+		}
+		for i := range t.Items {
+			downloaded += t.Items[i].SizeDownloaded
+		}
+	}
+	fmt.Println(st.Stats())
+	fmt.Printf("tasks by status: %s\n", formatCounts(byTask))
+	fmt.Printf("items by status: %s\n", formatCounts(byItem))
+	fmt.Printf("bytes downloaded: %d\n", downloaded)
+}
+
+// Вывод записей WAL со смещениями
+func cmdWal(dir string, args []string) {
+	fs := flag.NewFlagSet("wal", flag.ExitOnError)
+	taskID := fs.String("task", "", "only records for this task")
+	from := fs.Int64("from", 0, "skip records before this offset")
+	full := fs.Bool("data", false, "print record payloads")
+	_ = fs.Parse(args)
+
+	_, err := storage.ScanWAL(dir, func(e storage.WALEntry) error {
+		if e.Offset < *from || (*taskID != "" && string(e.TaskID) != *taskID) {
+			return nil
+		}
+		fmt.Printf("%10d  %6d  %-12s  %s\n", e.Offset, e.Size, e.Type, e.TaskID)
+		if *full {
+			fmt.Printf("            %s\n", e.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("wal: %v", err)
+	}
+}
+
+// Проверка согласованности snapshot и WAL
+func cmdVerify(dir string) {
+	rep, err := storage.Verify(dir)
+	if err != nil {
+		log.Fatalf("verify: %v", err)
+	}
+	fmt.Printf("snapshot: %d tasks\n", rep.SnapshotTasks)
+	if rep.SnapshotError != "" {
+		fmt.Printf("  ERROR: %s\n", rep.SnapshotError)
+	}
+	fmt.Printf("wal: %d records, %d valid bytes\n", rep.WALRecords, rep.WALBytes)
+	if rep.WALError != "" {
+		fmt.Printf("  ERROR: %s\n", rep.WALError)
+	}
+	for _, off := range rep.UnknownRecords {
+		fmt.Printf("  ERROR: unknown record type at offset %d\n", off)
+	}
+	for _, off := range rep.OrphanUpdates {
+		fmt.Printf("  ERROR: update of unknown task at offset %d\n", off)
+	}
+	fmt.Printf("tasks only in snapshot: %d\n", len(rep.OnlyInSnapshot))
+	fmt.Printf("tasks only in wal: %d\n", len(rep.OnlyInWAL))
+	fmt.Printf("tasks matching snapshot: %d\n", len(rep.Matching))
+	fmt.Printf("tasks where wal is ahead of snapshot: %d\n", len(rep.Diverging))
+	for _, id := range rep.Diverging {
+		fmt.Printf("  %s\n", id)
+	}
+	if !rep.OK() {
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// Подсчет элементов задачи по статусам
+func itemCounts(t *model.Task) map[model.ItemStatus]int {
+	out := make(map[model.ItemStatus]int)
+	for i := range t.Items {
+		out[t.Items[i].Status]++
+	}
+	return out
+}
+
+// Форматирует счетчики в стабильном порядке
+func formatCounts[K ~string](m map[K]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[K(k)]))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Печатает значение в формате JSON
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,153 @@
+package cas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Хранилище файлов по содержимому: файл хранится под именем sha256 своего содержимого
+type Store struct {
+	dir string
+}
+
+// Создает Store в указанной директории
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Путь к файлу с указанным хешем
+func (s *Store) Path(sha string) string {
+	if len(sha) < 2 {
+		return filepath.Join(s.dir, sha)
+	}
+	return filepath.Join(s.dir, sha[:2], sha)
+}
+
+// Проверяет наличие файла
+func (s *Store) Has(sha string) bool {
+	_, err := os.Stat(s.Path(sha))
+	return err == nil
+}
+
+// Перемещает файл в хранилище под именем его хеша.
+// Если такое содержимое уже есть, исходный файл удаляется.
+func (s *Store) Put(src, sha string) error {
+	dst := s.Path(sha)
+	if _, err := os.Stat(dst); err == nil {
+		return os.Remove(src)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// Создает файл dst с содержимым sha: жесткой ссылкой, а если это невозможно - копией.
+// Существующий dst атомарно заменяется.
+func (s *Store) Link(sha, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	src := s.Path(sha)
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(srcInfo, dstInfo) {
+		return nil
+	}
+	tmp := dst + ".link"
+	_ = os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		if err := copyFile(src, tmp); err != nil {
+			return fmt.Errorf("link %s: %w", sha, err)
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Удаляет файл и возвращает его размер
+func (s *Store) Remove(sha string) (int64, error) {
+	p := s.Path(sha)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(p); err != nil {
+		return 0, err
+	}
+	_ = os.Remove(filepath.Dir(p)) // удаляется только пустая директория
+	return fi.Size(), nil
+}
+
+// Возвращает хеши всех файлов хранилища
+func (s *Store) List() ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(s.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() && isHash(d.Name()) {
+			out = append(out, d.Name())
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Вычисляет sha256 и размер файла
+func HashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Проверяет, что имя является hex записью sha256
+func isHash(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	return strings.Trim(name, "0123456789abcdef") == ""
+}
+
+// Копирует файл
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
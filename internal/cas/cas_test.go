@@ -0,0 +1,113 @@
+package cas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemp(t *testing.T, dir, content string) (string, string) {
+	t.Helper()
+	f, err := os.CreateTemp(dir, "part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	sum := sha256.Sum256([]byte(content))
+	return f.Name(), hex.EncodeToString(sum[:])
+}
+
+func TestPutDeduplicates(t *testing.T) {
+	tmp := t.TempDir()
+	s, err := New(filepath.Join(tmp, "cas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, sha := writeTemp(t, tmp, "hello")
+	gotSHA, size, err := HashFile(first)
+	if err != nil || gotSHA != sha || size != 5 {
+		t.Fatalf("HashFile = %s, %d, %v; want %s, 5", gotSHA, size, err, sha)
+	}
+	if err := s.Put(first, sha); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Has(sha) || filepath.Base(filepath.Dir(s.Path(sha))) != sha[:2] {
+		t.Fatalf("content is not stored under %s", s.Path(sha))
+	}
+	second, _ := writeTemp(t, tmp, "hello")
+	if err := s.Put(second, sha); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Fatal("duplicate source file is not removed")
+	}
+	list, err := s.List()
+	if err != nil || len(list) != 1 || list[0] != sha {
+		t.Fatalf("List = %v, %v; want [%s]", list, err, sha)
+	}
+}
+
+func TestLinkAndRemove(t *testing.T) {
+	tmp := t.TempDir()
+	s, err := New(filepath.Join(tmp, "cas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, sha := writeTemp(t, tmp, "content")
+	if err := s.Put(src, sha); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmp, "out", "file.txt")
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Существующий файл заменяется, повторная ссылка ничего не меняет
+	for i := 0; i < 2; i++ {
+		if err := s.Link(sha, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b, err := os.ReadFile(dst); err != nil || string(b) != "content" {
+		t.Fatalf("linked file %q, %v", b, err)
+	}
+	if _, err := os.Stat(dst + ".link"); !os.IsNotExist(err) {
+		t.Fatal("temporary link file is left behind")
+	}
+
+	size, err := s.Remove(sha)
+	if err != nil || size != 7 {
+		t.Fatalf("Remove = %d, %v; want 7", size, err)
+	}
+	if s.Has(sha) {
+		t.Fatal("content is still stored after Remove")
+	}
+	if _, err := os.Stat(filepath.Dir(s.Path(sha))); !os.IsNotExist(err) {
+		t.Fatal("empty hash directory is not removed")
+	}
+	// Опубликованный файл не зависит от хранилища
+	if b, err := os.ReadFile(dst); err != nil || string(b) != "content" {
+		t.Fatalf("linked file after Remove %q, %v", b, err)
+	}
+}
+
+func TestListSkipsForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := s.List(); err != nil || len(list) != 0 {
+		t.Fatalf("List = %v, %v; want empty", list, err)
+	}
+}
@@ -0,0 +1,94 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrInvalid возвращается для контрольной суммы в неизвестном формате
+	ErrInvalid = errors.New("invalid checksum")
+	// ErrMismatch возвращается, если содержимое не совпадает с контрольной суммой
+	ErrMismatch = errors.New("checksum mismatch")
+)
+
+// Поддерживаемые алгоритмы и длина их значения в байтах
+var algos = map[string]struct {
+	size int
+	new  func() hash.Hash
+}{
+	"md5":    {md5.Size, md5.New},
+	"sha1":   {sha1.Size, sha1.New},
+	"sha256": {sha256.Size, sha256.New},
+	"sha512": {sha512.Size, sha512.New},
+}
+
+// Sum - ожидаемая контрольная сумма содержимого
+type Sum struct {
+	Algo string // md5, sha1, sha256, sha512
+	Hex  string // значение в нижнем регистре
+}
+
+// Parse разбирает контрольную сумму вида "sha256:<hex>"
+func Parse(s string) (Sum, error) {
+	algo, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return Sum{}, fmt.Errorf("%w %q: expected algo:hex", ErrInvalid, s)
+	}
+	algo = NormalizeAlgo(algo)
+	a, ok := algos[algo]
+	if !ok {
+		return Sum{}, fmt.Errorf("%w %q: unsupported algorithm", ErrInvalid, s)
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	if b, err := hex.DecodeString(value); err != nil || len(b) != a.size {
+		return Sum{}, fmt.Errorf("%w %q: bad %s value", ErrInvalid, s, algo)
+	}
+	return Sum{Algo: algo, Hex: value}, nil
+}
+
+// NormalizeAlgo приводит имя алгоритма к виду без дефиса в нижнем регистре ("SHA-256" -> "sha256")
+func NormalizeAlgo(algo string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(algo)), "-", "")
+}
+
+// Supported сообщает, умеет ли пакет проверять алгоритм
+func Supported(algo string) bool {
+	_, ok := algos[NormalizeAlgo(algo)]
+	return ok
+}
+
+func (s Sum) String() string { return s.Algo + ":" + s.Hex }
+
+// Verify проверяет содержимое файла; sha256 файла, если он уже известен, избавляет от повторного чтения
+func (s Sum) Verify(path, knownSHA256 string) error {
+	if s.Algo == "sha256" && knownSHA256 != "" {
+		return s.compare(knownSHA256)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := algos[s.Algo].new()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	return s.compare(hex.EncodeToString(h.Sum(nil)))
+}
+
+func (s Sum) compare(got string) error {
+	if got != s.Hex {
+		return fmt.Errorf("%w: expected %s, got %s:%s", ErrMismatch, s, s.Algo, got)
+	}
+	return nil
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"bufio"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Конфигурация приложения
@@ -15,6 +17,67 @@ type Config struct {
 	Workers        int
 	RetryMax       int
 	RetryBackoffMs int
+	// Хранение истории переходов задач
+	HistoryMaxEntries int
+	HistoryMaxAge     time.Duration
+	// Срок хранения задач по конечному статусу (0 - бессрочно) и период очистки
+	RetentionCompleted time.Duration
+	RetentionFailed    time.Duration
+	RetentionCanceled  time.Duration
+	JanitorInterval    time.Duration
+	// Ограничения размера файлов и места на диске (0 - без ограничения)
+	MaxItemBytes    int64
+	DiskQuotaBytes  int64
+	MinFreeBytes    int64
+	ResumeFreeBytes int64
+	// Каталог для загрузки file:// URL; пусто - схема file отключена
+	FileRoot string
+	// Приемник файлов по умолчанию (local или s3) и параметры S3; S3 доступен, если задан S3_BUCKET
+	Sink        string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3Prefix    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+	S3PartSize  int64
+	// Команды шагов обработки (имя=программа аргументы;...), их таймаут, лимиты объема и числа файлов результатов шага
+	ProcessCommands   map[string][]string
+	ProcessTimeout    time.Duration
+	ProcessMaxBytes   int64
+	ProcessMaxEntries int
+	// Максимальный размер манифеста задачи
+	ManifestMaxBytes int64
+	// Политика исходящих соединений: разрешенные и запрещенные CIDR, доступ к частным и локальным адресам
+	EgressAllow        []string
+	EgressDeny         []string
+	EgressAllowPrivate bool
+	// Перенаправления http: максимальное число и разрешение перехода с https на http
+	RedirectMax            int
+	RedirectAllowDowngrade bool
+	// Транспорт загрузчика http: прокси (URL, env или direct), корневые сертификаты, клиентский сертификат,
+	// отключение проверки сертификата, таймауты, пул соединений и переопределения по хостам
+	DownloadProxy                 string
+	DownloadCAFile                string
+	DownloadClientCert            string
+	DownloadClientKey             string
+	DownloadInsecureSkipVerify    bool
+	DownloadConnectTimeout        time.Duration
+	DownloadTLSHandshakeTimeout   time.Duration
+	DownloadResponseHeaderTimeout time.Duration
+	DownloadIdleConnTimeout       time.Duration
+	DownloadMaxIdleConnsPerHost   int
+	DownloadHosts                 string
+	// Ограничения попытки загрузки элемента: время без данных, минимальная скорость (байт/с) за окно
+	// и общее время попытки; 0 - без ограничения
+	DownloadIdleTimeout      time.Duration
+	DownloadMinThroughput    int64
+	DownloadThroughputWindow time.Duration
+	DownloadItemDeadline     time.Duration
+	// Журнал: уровень (debug, info, warn, error) и формат (text или json)
+	LogLevel  slog.Level
+	LogFormat string
 }
 
 // Загрузка конфигурации из переменных окружения и .env файла
@@ -29,6 +92,65 @@ func Load() *Config {
 		Workers:        getenvInt("WORKERS", 4),
 		RetryMax:       getenvInt("RETRY_MAX", 3),
 		RetryBackoffMs: getenvInt("RETRY_BACKOFF_MS", 500),
+
+		HistoryMaxEntries: getenvInt("HISTORY_MAX_ENTRIES", 1000),
+		HistoryMaxAge:     getenvDuration("HISTORY_MAX_AGE", 30*24*time.Hour),
+
+		RetentionCompleted: getenvDuration("RETENTION_COMPLETED", 0),
+		RetentionFailed:    getenvDuration("RETENTION_FAILED", 0),
+		RetentionCanceled:  getenvDuration("RETENTION_CANCELED", 0),
+		JanitorInterval:    getenvDuration("JANITOR_INTERVAL", 10*time.Minute),
+
+		MaxItemBytes:    getenvInt64("MAX_ITEM_BYTES", 0),
+		DiskQuotaBytes:  getenvInt64("DISK_QUOTA_BYTES", 0),
+		MinFreeBytes:    getenvInt64("MIN_FREE_BYTES", 0),
+		ResumeFreeBytes: getenvInt64("RESUME_FREE_BYTES", 0),
+
+		FileRoot: getenv("FILE_ROOT", ""),
+
+		Sink:        getenv("SINK", "local"),
+		S3Endpoint:  getenv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:    getenv("S3_REGION", "us-east-1"),
+		S3Bucket:    getenv("S3_BUCKET", ""),
+		S3Prefix:    getenv("S3_PREFIX", ""),
+		S3AccessKey: getenv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getenv("S3_SECRET_KEY", ""),
+		S3PathStyle: getenvBool("S3_PATH_STYLE", true),
+		S3PartSize:  getenvInt64("S3_PART_SIZE", 8<<20),
+
+		ProcessCommands:   getenvCommands("PROCESS_COMMANDS"),
+		ProcessTimeout:    getenvDuration("PROCESS_TIMEOUT", 5*time.Minute),
+		ProcessMaxBytes:   getenvInt64("PROCESS_MAX_BYTES", 4<<30),
+		ProcessMaxEntries: getenvInt("PROCESS_MAX_ENTRIES", 10000),
+
+		ManifestMaxBytes: getenvInt64("MANIFEST_MAX_BYTES", 64<<20),
+
+		EgressAllow:        getenvList("EGRESS_ALLOW_CIDRS"),
+		EgressDeny:         getenvList("EGRESS_DENY_CIDRS"),
+		EgressAllowPrivate: getenvBool("EGRESS_ALLOW_PRIVATE", false),
+
+		RedirectMax:            getenvInt("REDIRECT_MAX", 10),
+		RedirectAllowDowngrade: getenvBool("REDIRECT_ALLOW_DOWNGRADE", false),
+
+		DownloadProxy:                 getenv("DOWNLOAD_PROXY", ""),
+		DownloadCAFile:                getenv("DOWNLOAD_CA_FILE", ""),
+		DownloadClientCert:            getenv("DOWNLOAD_CLIENT_CERT", ""),
+		DownloadClientKey:             getenv("DOWNLOAD_CLIENT_KEY", ""),
+		DownloadInsecureSkipVerify:    getenvBool("DOWNLOAD_INSECURE_SKIP_VERIFY", false),
+		DownloadConnectTimeout:        getenvDuration("DOWNLOAD_CONNECT_TIMEOUT", 30*time.Second),
+		DownloadTLSHandshakeTimeout:   getenvDuration("DOWNLOAD_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+		DownloadResponseHeaderTimeout: getenvDuration("DOWNLOAD_RESPONSE_HEADER_TIMEOUT", 0),
+		DownloadIdleConnTimeout:       getenvDuration("DOWNLOAD_IDLE_CONN_TIMEOUT", 90*time.Second),
+		DownloadMaxIdleConnsPerHost:   getenvInt("DOWNLOAD_MAX_IDLE_CONNS_PER_HOST", 0),
+		DownloadHosts:                 getenv("DOWNLOAD_HOSTS", ""),
+
+		DownloadIdleTimeout:      getenvDuration("DOWNLOAD_IDLE_TIMEOUT", 60*time.Second),
+		DownloadMinThroughput:    getenvInt64("DOWNLOAD_MIN_THROUGHPUT", 0),
+		DownloadThroughputWindow: getenvDuration("DOWNLOAD_THROUGHPUT_WINDOW", 30*time.Second),
+		DownloadItemDeadline:     getenvDuration("DOWNLOAD_ITEM_DEADLINE", 0),
+
+		LogLevel:  getenvLevel("LOG_LEVEL", slog.LevelInfo),
+		LogFormat: strings.ToLower(getenv("LOG_FORMAT", "text")),
 	}
 }
 
@@ -50,6 +172,71 @@ func getenvInt(key string, def int) int {
 	return def
 }
 
+// Возвращает значение переменной окружения как int64 или значение по умолчанию
+func getenvInt64(key string, def int64) int64 {
+	if v := os.Getenv(key); v != "" {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// Возвращает именованные команды из переменной окружения вида "имя=программа аргументы;имя2=..."
+func getenvCommands(key string) map[string][]string {
+	cmds := make(map[string][]string)
+	for _, def := range strings.Split(os.Getenv(key), ";") {
+		name, cmd, ok := strings.Cut(def, "=")
+		name = strings.TrimSpace(name)
+		if argv := strings.Fields(cmd); ok && name != "" && len(argv) > 0 {
+			cmds[name] = argv
+		}
+	}
+	return cmds
+}
+
+// Возвращает список из переменной окружения через запятую
+func getenvList(key string) []string {
+	var out []string
+	for _, s := range strings.Split(os.Getenv(key), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Возвращает значение переменной окружения как bool или значение по умолчанию
+func getenvBool(key string, def bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// Возвращает значение переменной окружения как time.Duration (например, "90s", "72h") или значение по умолчанию
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// Возвращает значение переменной окружения как уровень журнала (например, "debug", "warn") или значение по умолчанию
+func getenvLevel(key string, def slog.Level) slog.Level {
+	if v := os.Getenv(key); v != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(v)); err == nil {
+			return l
+		}
+	}
+	return def
+}
+
 // Загружает .env файл, если он существует
 func loadEnvFile(filename string) {
 	file, err := os.Open(filename)
@@ -0,0 +1,237 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExpr возвращается для некорректного cron-выражения
+var ErrInvalidExpr = errors.New("invalid cron expression")
+
+// На сколько лет вперед ищется следующее срабатывание
+const searchYears = 5
+
+// Разобранное cron-выражение из пяти полей: минута, час, день месяца, месяц, день недели
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// День месяца или день недели задан как "*": тогда дни проверяются вместе, иначе - любой из них
+	domStar, dowStar bool
+}
+
+// Описание поля выражения
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Сокращения для распространенных расписаний
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Разбирает выражение вида "*/15 9-18 * * mon-fri" или сокращение @hourly, @daily и т.п.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidExpr, len(parts))
+	}
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseField(parts[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(parts[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(parts[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(parts[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(parts[4], dowField); err != nil {
+		return nil, err
+	}
+	// 7 - тоже воскресенье
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(parts[2], "*")
+	s.dowStar = strings.HasPrefix(parts[4], "*")
+	return s, nil
+}
+
+// Разбирает поле: список через запятую из "*", "a", "a-b" с необязательным шагом "/n"
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%w: bad step %q in %s", ErrInvalidExpr, part, f.name)
+			}
+			step = n
+		}
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if hasStep {
+				hi = f.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("%w: empty range %q in %s", ErrInvalidExpr, part, f.name)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Значение поля: число или имя
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: bad %s %q", ErrInvalidExpr, f.name, s)
+	}
+	return v, nil
+}
+
+func has(bits uint64, v int) bool { return bits&(1<<uint(v)) != 0 }
+
+// Подходит ли день
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Возвращает первое срабатывание строго после t в часовом поясе t.
+// Нулевое время означает, что срабатываний в ближайшие годы нет (например, 30 февраля).
+//
+// Расписание задает показания часов, поэтому при переводе часов каждое время срабатывает не больше одного раза:
+// время, которого нет из-за перевода вперед (02:30 при переходе 02:00 -> 03:00), сдвигается на длину
+// пропуска (03:30), а время, которое при переводе назад повторяется, срабатывает только в первый раз.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// Поиск идет по показаниям часов в UTC, где переходов нет; найденное время переводится в пояс t
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	for {
+		wall = s.nextWall(wall)
+		if wall.IsZero() {
+			return wall
+		}
+		next := resolve(wall, loc)
+		// Во втором проходе повторяющегося часа первое такое время уже прошло
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// Момент, когда часы в поясе loc показывают wall (показания в UTC). Из повторяющихся моментов выбирается первый;
+// для несуществующего времени берется смещение до перехода, то есть время сдвигается на длину пропуска.
+// time.Date не гарантирует выбор в этих случаях
+func resolve(wall time.Time, loc *time.Location) time.Time {
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	first := wall.Add(-time.Duration(before) * time.Second).In(loc)
+	second := wall.Add(-time.Duration(after) * time.Second).In(loc)
+	if shows(second, wall) && (second.Before(first) || !shows(first, wall)) {
+		return second
+	}
+	return first
+}
+
+// Показывают ли часы t время wall с точностью до минуты
+func shows(t, wall time.Time) bool {
+	return t.Year() == wall.Year() && t.YearDay() == wall.YearDay() && t.Hour() == wall.Hour() && t.Minute() == wall.Minute()
+}
+
+// Первое подходящее показание часов строго после wall; wall и результат в UTC
+func (s *Schedule) nextWall(t time.Time) time.Time {
+	loc := time.UTC
+	t = t.Add(time.Minute)
+	limit := t.Year() + searchYears
+
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for !has(s.month, int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for !has(s.hour, t.Hour()) {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.minute, t.Minute()) {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
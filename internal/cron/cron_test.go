@@ -0,0 +1,171 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(expr); !errors.Is(err, ErrInvalidExpr) {
+			t.Errorf("Parse(%q) error %v, want ErrInvalidExpr", expr, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want []string
+	}{
+		{"every minute", "* * * * *", "2025-01-01T10:00:30Z",
+			[]string{"2025-01-01T10:01:00Z", "2025-01-01T10:02:00Z"}},
+		{"range", "0 9-11 * * *", "2025-01-01T10:30:00Z",
+			[]string{"2025-01-01T11:00:00Z", "2025-01-02T09:00:00Z", "2025-01-02T10:00:00Z"}},
+		{"step", "*/20 * * * *", "2025-01-01T10:41:00Z",
+			[]string{"2025-01-01T11:00:00Z", "2025-01-01T11:20:00Z", "2025-01-01T11:40:00Z"}},
+		{"step in range", "10-30/10 8 * * *", "2025-01-01T00:00:00Z",
+			[]string{"2025-01-01T08:10:00Z", "2025-01-01T08:20:00Z", "2025-01-01T08:30:00Z", "2025-01-02T08:10:00Z"}},
+		{"step from value", "50/5 * * * *", "2025-01-01T10:00:00Z",
+			[]string{"2025-01-01T10:50:00Z", "2025-01-01T10:55:00Z", "2025-01-01T11:50:00Z"}},
+		{"list", "0 6,18 * * *", "2025-01-01T07:00:00Z",
+			[]string{"2025-01-01T18:00:00Z", "2025-01-02T06:00:00Z"}},
+		{"list of ranges", "0 0 1-2,15 * *", "2025-01-01T12:00:00Z",
+			[]string{"2025-01-02T00:00:00Z", "2025-01-15T00:00:00Z", "2025-02-01T00:00:00Z"}},
+		{"month and day names", "0 12 * feb-mar mon", "2025-01-01T00:00:00Z",
+			[]string{"2025-02-03T12:00:00Z", "2025-02-10T12:00:00Z"}},
+		{"weekdays", "0 9 * * mon-fri", "2025-01-03T10:00:00Z", // пятница
+			[]string{"2025-01-06T09:00:00Z", "2025-01-07T09:00:00Z"}},
+		{"sunday as 7", "0 0 * * 7", "2025-01-01T00:00:00Z",
+			[]string{"2025-01-05T00:00:00Z", "2025-01-12T00:00:00Z"}},
+		// День месяца и день недели заданы оба: подходит любой из них
+		{"dom or dow", "0 0 13 * fri", "2025-06-01T00:00:00Z",
+			[]string{"2025-06-06T00:00:00Z", "2025-06-13T00:00:00Z", "2025-06-20T00:00:00Z", "2025-06-27T00:00:00Z", "2025-07-04T00:00:00Z"}},
+		// Одно из полей начинается с "*": дни должны подходить оба
+		{"dom star and dow", "0 0 * * fri", "2025-06-01T00:00:00Z",
+			[]string{"2025-06-06T00:00:00Z", "2025-06-13T00:00:00Z"}},
+		{"dom step and dow", "0 0 */2 * mon", "2025-06-01T00:00:00Z",
+			[]string{"2025-06-09T00:00:00Z", "2025-06-23T00:00:00Z"}},
+		{"last day of short month", "0 0 31 * *", "2025-01-31T00:00:00Z",
+			[]string{"2025-03-31T00:00:00Z", "2025-05-31T00:00:00Z"}},
+		{"leap day", "0 0 29 2 *", "2025-01-01T00:00:00Z",
+			[]string{"2028-02-29T00:00:00Z", "2032-02-29T00:00:00Z"}},
+		{"year end", "@yearly", "2025-12-31T23:59:00Z",
+			[]string{"2026-01-01T00:00:00Z", "2027-01-01T00:00:00Z"}},
+		{"hourly", "@hourly", "2025-01-01T10:00:00Z",
+			[]string{"2025-01-01T11:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur, _ := time.Parse(time.RFC3339, tt.from)
+			for _, w := range tt.want {
+				cur = s.Next(cur)
+				if got := cur.Format(time.RFC3339); got != w {
+					t.Fatalf("Next = %s, want %s", got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("Next for February 30 = %s, want zero time", next)
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	berlin := mustLoad(t, "Europe/Berlin")
+	lordHowe := mustLoad(t, "Australia/Lord_Howe")
+	tests := []struct {
+		name string
+		expr string
+		loc  *time.Location
+		from string
+		want []string
+	}{
+		// 2025-03-09 02:00 EST -> 03:00 EDT: 02:30 не существует и сдвигается на длину пропуска
+		{"gap daily", "30 2 * * *", ny, "2025-03-08T12:00:00-05:00",
+			[]string{"2025-03-09T03:30:00-04:00", "2025-03-10T02:30:00-04:00"}},
+		{"gap shifted time does not repeat", "30 2,3 * * *", ny, "2025-03-09T00:00:00-05:00",
+			[]string{"2025-03-09T03:30:00-04:00", "2025-03-10T02:30:00-04:00", "2025-03-10T03:30:00-04:00"}},
+		{"gap every 30 minutes", "*/30 * * * *", ny, "2025-03-09T01:00:00-05:00",
+			[]string{"2025-03-09T01:30:00-05:00", "2025-03-09T03:00:00-04:00", "2025-03-09T03:30:00-04:00"}},
+		{"gap hour outside of it", "0 4 * * *", ny, "2025-03-09T00:00:00-05:00",
+			[]string{"2025-03-09T04:00:00-04:00", "2025-03-10T04:00:00-04:00"}},
+		// 2025-11-02 02:00 EDT -> 01:00 EST: 01:30 бывает дважды и срабатывает один раз, в первый
+		{"overlap daily", "30 1 * * *", ny, "2025-11-01T12:00:00-04:00",
+			[]string{"2025-11-02T01:30:00-04:00", "2025-11-03T01:30:00-05:00"}},
+		{"overlap from second pass", "30 1 * * *", ny, "2025-11-02T01:10:00-05:00",
+			[]string{"2025-11-03T01:30:00-05:00"}},
+		{"overlap hourly", "0 * * * *", ny, "2025-11-02T00:30:00-04:00",
+			[]string{"2025-11-02T01:00:00-04:00", "2025-11-02T02:00:00-05:00", "2025-11-02T03:00:00-05:00"}},
+		// Европа: 2025-03-30 02:00 CET -> 03:00 CEST, 2025-10-26 03:00 CEST -> 02:00 CET
+		{"berlin gap", "15 2 * * *", berlin, "2025-03-29T12:00:00+01:00",
+			[]string{"2025-03-30T03:15:00+02:00", "2025-03-31T02:15:00+02:00"}},
+		{"berlin overlap", "15 2 * * *", berlin, "2025-10-25T12:00:00+02:00",
+			[]string{"2025-10-26T02:15:00+02:00", "2025-10-27T02:15:00+01:00"}},
+		// Лорд-Хау переводит часы на 30 минут: 2025-10-05 02:00 -> 02:30
+		{"half hour gap", "15 2 * * *", lordHowe, "2025-10-04T12:00:00+10:30",
+			[]string{"2025-10-05T02:45:00+11:00", "2025-10-06T02:15:00+11:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur, err := time.Parse(time.RFC3339, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur = cur.In(tt.loc)
+			for _, w := range tt.want {
+				want, _ := time.Parse(time.RFC3339, w)
+				next := s.Next(cur)
+				if !next.Equal(want) {
+					t.Fatalf("Next(%s) = %s, want %s", cur.Format(time.RFC3339), next.Format(time.RFC3339), w)
+				}
+				if next.Location() != tt.loc {
+					t.Fatalf("Next returned time in %s, want %s", next.Location(), tt.loc)
+				}
+				cur = next
+			}
+		})
+	}
+}
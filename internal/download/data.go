@@ -0,0 +1,91 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Тип содержимого data: URL по умолчанию (RFC 2397)
+const dataDefaultType = "text/plain;charset=US-ASCII"
+
+// Загрузчик data: URL
+type Data struct{}
+
+// Проверяет, что URL разбирается
+func (Data) Validate(u *url.URL) error {
+	_, _, err := parseData(u)
+	return err
+}
+
+// Возвращает содержимое URL
+func (Data) Fetch(ctx context.Context, req Request) (*Response, error) {
+	ct, body, err := parseData(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	offset := req.Offset
+	if offset > int64(len(body)) || req.IfRange != "" {
+		offset = 0
+	}
+	return &Response{
+		Body:        io.NopCloser(bytes.NewReader(body[offset:])),
+		Offset:      offset,
+		Size:        int64(len(body)),
+		ContentType: ct,
+	}, nil
+}
+
+// Разбирает data:[<тип>][;base64],<данные>
+func parseData(u *url.URL) (string, []byte, error) {
+	raw := u.Opaque
+	if raw == "" {
+		raw = strings.TrimPrefix(u.Path, "/")
+	}
+	if u.RawQuery != "" {
+		raw += "?" + u.RawQuery
+	}
+	header, payload, ok := strings.Cut(raw, ",")
+	if !ok {
+		return "", nil, fmt.Errorf("%w: data url without comma", ErrInvalidURL)
+	}
+	isBase64 := false
+	if h, found := strings.CutSuffix(header, ";base64"); found {
+		header, isBase64 = h, true
+	}
+	ct := header
+	if ct == "" || strings.HasPrefix(ct, ";") {
+		ct = "text/plain" + ct
+		if header == "" {
+			ct = dataDefaultType
+		}
+	}
+	if unescaped, err := url.PathUnescape(ct); err == nil {
+		ct = unescaped
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if !isBase64 {
+		return ct, []byte(data), nil
+	}
+	data = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, data)
+	body, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		body, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	return ct, body, nil
+}
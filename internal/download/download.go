@@ -0,0 +1,176 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedScheme возвращается для URL, схему которых не обслуживает ни один загрузчик
+	ErrUnsupportedScheme = errors.New("unsupported url scheme")
+	// ErrInvalidURL возвращается для URL, которые загрузчик не может обработать ни при какой попытке
+	ErrInvalidURL = errors.New("invalid url")
+	// ErrRedirect возвращается, если перенаправление запрещено политикой запроса
+	ErrRedirect = errors.New("redirect not allowed")
+)
+
+// Ответ источника с неожиданным кодом статуса
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string { return "bad status: " + e.Status }
+
+// Политика перенаправлений http и https
+type RedirectPolicy struct {
+	// Максимальное число перенаправлений; 0 - не следовать им
+	Max int
+	// Разрешить перенаправление с https на http
+	AllowDowngrade bool
+}
+
+// Перенаправление, пройденное при загрузке: адрес и код ответа с перенаправлением
+type Redirect struct {
+	URL    string
+	Status int
+}
+
+// Запрос на загрузку
+type Request struct {
+	URL *url.URL
+	// Смещение, с которого нужно продолжить загрузку
+	Offset int64
+	// Валидатор уже полученной части: если содержимое источника другое, оно отдается целиком с Offset 0
+	IfRange string
+	// Вызывается по мере чтения тела с числом прочитанных байт, может быть nil
+	Progress func(read int64)
+	// Валидаторы ранее полученного содержимого: если оно не изменилось,
+	// источник может вернуть ответ с NotModified. Используются только при Offset == 0
+	ETag         string
+	LastModified string
+	// Политика перенаправлений; nil - поведение клиента по умолчанию
+	Redirects *RedirectPolicy
+}
+
+// Ответ источника
+type Response struct {
+	Body io.ReadCloser
+	// Смещение, с которого начинается Body: 0, если источник не поддерживает продолжение
+	Offset int64
+	// Полный размер содержимого, -1 если неизвестен
+	Size            int64
+	ContentType     string
+	DispositionName string
+	// Валидаторы содержимого для следующих условных запросов
+	ETag         string
+	LastModified string
+	// Содержимое не изменилось с получения по валидаторам запроса; Body пустой
+	NotModified bool
+	// Адрес, с которого получен ответ, и пройденные перенаправления; заполняются загрузчиком http
+	FinalURL  string
+	Redirects []Redirect
+}
+
+// Загрузчик для одной или нескольких схем URL
+type Downloader interface {
+	Fetch(ctx context.Context, req Request) (*Response, error)
+}
+
+// Загрузчик, который умеет заранее проверить URL
+type Validator interface {
+	Validate(u *url.URL) error
+}
+
+// Набор загрузчиков по схемам URL
+type Registry struct {
+	byScheme map[string]Downloader
+}
+
+// Создает пустой набор загрузчиков
+func NewRegistry() *Registry {
+	return &Registry{byScheme: make(map[string]Downloader)}
+}
+
+// Регистрирует загрузчик для схем
+func (r *Registry) Register(d Downloader, schemes ...string) {
+	for _, s := range schemes {
+		r.byScheme[strings.ToLower(s)] = d
+	}
+}
+
+// Список поддерживаемых схем
+func (r *Registry) Schemes() []string {
+	out := make([]string, 0, len(r.byScheme))
+	for s := range r.byScheme {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Разбирает URL и находит загрузчик для его схемы
+func (r *Registry) resolve(rawURL string) (*url.URL, Downloader, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	d, ok := r.byScheme[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w %q", ErrUnsupportedScheme, u.Scheme)
+	}
+	return u, d, nil
+}
+
+// Проверяет, что URL может быть загружен
+func (r *Registry) Validate(rawURL string) error {
+	u, d, err := r.resolve(rawURL)
+	if err != nil {
+		return err
+	}
+	if v, ok := d.(Validator); ok {
+		return v.Validate(u)
+	}
+	return nil
+}
+
+// Открывает URL загрузчиком его схемы; поле URL запроса заполняется из rawURL
+func (r *Registry) Fetch(ctx context.Context, rawURL string, req Request) (*Response, error) {
+	u, d, err := r.resolve(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u
+	if req.Offset > 0 {
+		req.ETag, req.LastModified = "", ""
+	}
+	resp, err := d.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Progress != nil {
+		resp.Body = &progressReader{ReadCloser: resp.Body, fn: req.Progress}
+	}
+	return resp, nil
+}
+
+// Сообщает о числе прочитанных байт
+type progressReader struct {
+	io.ReadCloser
+	fn   func(int64)
+	read int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.fn(p.read)
+	}
+	return n, err
+}
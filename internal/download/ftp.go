@@ -0,0 +1,237 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Загрузчик ftp:// в пассивном режиме
+type FTP struct {
+	dialer *net.Dialer
+}
+
+// Создает загрузчик FTP; управляющее соединение и соединение данных открываются через dialer
+func NewFTP(dialer *net.Dialer) *FTP {
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: 30 * time.Second}
+	}
+	return &FTP{dialer: dialer}
+}
+
+// Проверяет наличие хоста и пути
+func (f *FTP) Validate(u *url.URL) error {
+	if u.Host == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("%w: missing file path", ErrInvalidURL)
+	}
+	_, _, _, err := ftpArgs(u)
+	return err
+}
+
+// Возвращает пользователя, пароль и путь файла из URL. Они передаются серверу аргументами команд,
+// поэтому перевод строки или NUL в них позволил бы отправить лишнюю команду
+func ftpArgs(u *url.URL) (user, pass, p string, err error) {
+	user, pass = "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		if pw, ok := u.User.Password(); ok {
+			pass = pw
+		}
+	}
+	// Путь в URL задается относительно каталога пользователя (RFC 1738)
+	p, err = url.PathUnescape(strings.TrimPrefix(u.EscapedPath(), "/"))
+	if err != nil {
+		return "", "", "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	for _, s := range []string{user, pass, p} {
+		if strings.ContainsAny(s, "\r\n\x00") {
+			return "", "", "", fmt.Errorf("%w: control characters in ftp user, password or path", ErrInvalidURL)
+		}
+	}
+	return user, pass, p, nil
+}
+
+// Загружает файл командой RETR, продолжая с req.Offset через REST
+func (f *FTP) Fetch(ctx context.Context, req Request) (*Response, error) {
+	if err := f.Validate(req.URL); err != nil {
+		return nil, err
+	}
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		host = net.JoinHostPort(req.URL.Hostname(), "21")
+	}
+	conn, err := f.dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn)}
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	resp, err := c.open(ctx, f, req)
+	if err != nil {
+		stop()
+		c.conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	resp.Body.(*ftpBody).stop = stop
+	return resp, nil
+}
+
+// Управляющее соединение FTP
+type ftpConn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// Отправляет команду и читает ответ, ожидая код из класса want (1-5)
+func (c *ftpConn) cmd(want int, format string, args ...interface{}) (int, string, error) {
+	if format != "" {
+		if err := c.text.PrintfLine(format, args...); err != nil {
+			return 0, "", err
+		}
+	}
+	code, msg, err := c.text.ReadResponse(0)
+	if err != nil {
+		return code, msg, err
+	}
+	if code/100 != want {
+		cmd := "reply"
+		if fields := strings.Fields(format); len(fields) > 0 {
+			cmd = fields[0]
+		}
+		return code, msg, fmt.Errorf("ftp %s: %d %s", cmd, code, msg)
+	}
+	return code, msg, nil
+}
+
+// Авторизуется, открывает пассивное соединение данных и начинает передачу
+func (c *ftpConn) open(ctx context.Context, f *FTP, req Request) (*Response, error) {
+	user, pass, p, err := ftpArgs(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.cmd(2, ""); err != nil {
+		return nil, err
+	}
+	code, _, err := c.cmd(2, "USER %s", user)
+	if code == 331 {
+		_, _, err = c.cmd(2, "PASS %s", pass)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.cmd(2, "TYPE I"); err != nil {
+		return nil, err
+	}
+	size := int64(-1)
+	if _, msg, err := c.cmd(2, "SIZE %s", p); err == nil {
+		if n, perr := strconv.ParseInt(strings.TrimSpace(msg), 10, 64); perr == nil {
+			size = n
+		}
+	}
+	dataAddr, err := c.passive()
+	if err != nil {
+		return nil, err
+	}
+	// FTP не сообщает валидаторов, поэтому часть с валидатором другого источника не продолжается
+	offset := req.Offset
+	if offset > 0 && req.IfRange == "" && (size < 0 || offset <= size) {
+		if _, _, err := c.cmd(3, "REST %d", offset); err != nil {
+			offset = 0
+		}
+	} else {
+		offset = 0
+	}
+	data, err := f.dialer.DialContext(ctx, "tcp", dataAddr)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.cmd(1, "RETR %s", p); err != nil {
+		data.Close()
+		return nil, err
+	}
+	return &Response{
+		Body:   &ftpBody{c: c, data: data},
+		Offset: offset,
+		Size:   size,
+	}, nil
+}
+
+// Переходит в пассивный режим и возвращает адрес соединения данных.
+// Адрес из ответа PASV не используется: данные идут на хост управляющего соединения.
+func (c *ftpConn) passive() (string, error) {
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return "", err
+	}
+	if _, msg, err := c.cmd(2, "EPSV"); err == nil {
+		// 229 Entering Extended Passive Mode (|||port|)
+		if i, j := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)"); i >= 0 && j > i+4 {
+			if port, perr := strconv.Atoi(msg[i+4 : j]); perr == nil {
+				return net.JoinHostPort(host, strconv.Itoa(port)), nil
+			}
+		}
+	}
+	_, msg, err := c.cmd(2, "PASV")
+	if err != nil {
+		return "", err
+	}
+	// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)
+	i, j := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if i < 0 || j < i {
+		return "", fmt.Errorf("ftp PASV: unexpected reply %q", msg)
+	}
+	parts := strings.Split(msg[i+1:j], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("ftp PASV: unexpected reply %q", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(parts[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(parts[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("ftp PASV: unexpected reply %q", msg)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p1<<8|p2)), nil
+}
+
+// Тело передачи: по окончании данных проверяется итоговый ответ сервера
+type ftpBody struct {
+	c        *ftpConn
+	data     net.Conn
+	stop     func() bool
+	finished bool
+}
+
+func (b *ftpBody) Read(p []byte) (int, error) {
+	n, err := b.data.Read(p)
+	if err == io.EOF && !b.finished {
+		b.finished = true
+		b.data.Close()
+		// Обрыв передачи сервер сообщает кодом 426 или 451 после закрытия соединения данных
+		if _, _, rerr := b.c.cmd(2, ""); rerr != nil {
+			return n, rerr
+		}
+	}
+	return n, err
+}
+
+func (b *ftpBody) Close() error {
+	b.data.Close()
+	if b.finished {
+		_ = b.c.text.PrintfLine("QUIT")
+	}
+	if b.stop != nil {
+		b.stop()
+	}
+	return b.c.conn.Close()
+}
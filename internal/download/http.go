@@ -0,0 +1,131 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"taskservice/internal/naming"
+)
+
+// Загрузчик http и https
+type HTTP struct {
+	client *http.Client
+}
+
+// Создает загрузчик HTTP с указанным клиентом
+func NewHTTP(client *http.Client) *HTTP {
+	return &HTTP{client: client}
+}
+
+// Проверяет наличие хоста и корректность порта
+func (h *HTTP) Validate(u *url.URL) error {
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	if p := u.Port(); p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("%w: invalid port %q", ErrInvalidURL, p)
+		}
+	}
+	return nil
+}
+
+// Загружает URL, продолжая с req.Offset через Range, если сервер это поддерживает.
+// Валидаторы запроса передаются в If-None-Match и If-Modified-Since
+func (h *HTTP) Fetch(ctx context.Context, req Request) (*Response, error) {
+	client := h.client
+	if p := req.Redirects; p != nil {
+		c := *h.client
+		c.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+			return p.check(len(via), via[len(via)-1].URL, r.URL)
+		}
+		client = &c
+	}
+	hreq, err := http.NewRequestWithContext(ctx, "GET", req.URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.Offset > 0 {
+		hreq.Header.Set("Range", fmt.Sprintf("bytes=%d-", req.Offset))
+		if req.IfRange != "" {
+			hreq.Header.Set("If-Range", req.IfRange)
+		}
+	}
+	if req.ETag != "" {
+		hreq.Header.Set("If-None-Match", req.ETag)
+	}
+	if req.LastModified != "" {
+		hreq.Header.Set("If-Modified-Since", req.LastModified)
+	}
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	finalURL, redirects := redirectChain(resp)
+	if resp.StatusCode == http.StatusNotModified && (req.ETag != "" || req.LastModified != "") {
+		resp.Body.Close()
+		return &Response{
+			Body:         http.NoBody,
+			Size:         -1,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			NotModified:  true,
+			FinalURL:     finalURL,
+			Redirects:    redirects,
+		}, nil
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	out := &Response{
+		Body:            resp.Body,
+		Offset:          req.Offset,
+		Size:            -1,
+		ContentType:     resp.Header.Get("Content-Type"),
+		DispositionName: naming.DispositionFileName(resp.Header.Get("Content-Disposition")),
+		ETag:            resp.Header.Get("ETag"),
+		LastModified:    resp.Header.Get("Last-Modified"),
+		FinalURL:        finalURL,
+		Redirects:       redirects,
+	}
+	// Сервер проигнорировал Range и отдает файл целиком
+	if resp.StatusCode == http.StatusOK {
+		out.Offset = 0
+	}
+	if resp.ContentLength >= 0 {
+		out.Size = out.Offset + resp.ContentLength
+	}
+	return out, nil
+}
+
+// Проверяет очередное перенаправление from -> to; n - число уже выполненных запросов
+func (p *RedirectPolicy) check(n int, from, to *url.URL) error {
+	if n > p.Max {
+		if p.Max == 0 {
+			return fmt.Errorf("%w: redirects are disabled (to %s)", ErrRedirect, to.Redacted())
+		}
+		return fmt.Errorf("%w: stopped after %d redirects", ErrRedirect, p.Max)
+	}
+	if !p.AllowDowngrade && strings.EqualFold(from.Scheme, "https") && strings.EqualFold(to.Scheme, "http") {
+		return fmt.Errorf("%w: https to http downgrade (to %s)", ErrRedirect, to.Redacted())
+	}
+	return nil
+}
+
+// Восстанавливает цепочку перенаправлений по ответу клиента: адрес итогового запроса
+// и ответы с перенаправлением от первого к последнему
+func redirectChain(resp *http.Response) (string, []Redirect) {
+	var chain []Redirect
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		chain = append(chain, Redirect{URL: r.Request.URL.String(), Status: r.StatusCode})
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return resp.Request.URL.String(), chain
+}
@@ -0,0 +1,70 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRedirectPolicyCheck(t *testing.T) {
+	https, _ := url.Parse("https://a.example/x")
+	http1, _ := url.Parse("http://b.example/y")
+	tests := []struct {
+		name   string
+		policy RedirectPolicy
+		n      int
+		to     *url.URL
+		ok     bool
+	}{
+		{"within limit", RedirectPolicy{Max: 2}, 2, https, true},
+		{"over limit", RedirectPolicy{Max: 2}, 3, https, false},
+		{"disabled", RedirectPolicy{}, 1, https, false},
+		{"downgrade", RedirectPolicy{Max: 5}, 1, http1, false},
+		{"allowed downgrade", RedirectPolicy{Max: 5, AllowDowngrade: true}, 1, http1, true},
+	}
+	for _, tt := range tests {
+		err := tt.policy.check(tt.n, https, tt.to)
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrRedirect)) {
+			t.Errorf("%s: check = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestHTTPRedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/start", http.RedirectHandler("/middle", http.StatusMovedPermanently))
+	mux.Handle("/middle", http.RedirectHandler("/final", http.StatusFound))
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("done")) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	r := NewRegistry()
+	r.Register(NewHTTP(srv.Client()), "http")
+
+	resp, err := r.Fetch(context.Background(), srv.URL+"/start", Request{Redirects: &RedirectPolicy{Max: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := readAll(t, resp); body != "done" || resp.FinalURL != srv.URL+"/final" {
+		t.Fatalf("body %q from %s", body, resp.FinalURL)
+	}
+	want := []Redirect{{URL: srv.URL + "/start", Status: 301}, {URL: srv.URL + "/middle", Status: 302}}
+	if !reflect.DeepEqual(resp.Redirects, want) {
+		t.Fatalf("redirects %+v, want %+v", resp.Redirects, want)
+	}
+
+	if _, err := r.Fetch(context.Background(), srv.URL+"/start", Request{Redirects: &RedirectPolicy{Max: 1}}); !errors.Is(err, ErrRedirect) {
+		t.Fatalf("redirect limit: %v, want ErrRedirect", err)
+	}
+	resp, err = r.Fetch(context.Background(), srv.URL+"/final", Request{Redirects: &RedirectPolicy{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readAll(t, resp); resp.FinalURL != srv.URL+"/final" || len(resp.Redirects) != 0 {
+		t.Fatalf("direct response from %s with redirects %+v", resp.FinalURL, resp.Redirects)
+	}
+}
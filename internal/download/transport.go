@@ -0,0 +1,326 @@
+package download
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"taskservice/internal/egress"
+)
+
+// Параметры соединений http и https; нулевые значения наследуются от общих параметров
+type HostTransport struct {
+	// Прокси: URL (http, https, socks5), "env" - из переменных окружения, "direct" - без прокси
+	Proxy string
+	// Дополнительные корневые сертификаты (PEM) к системным
+	CAFile string
+	// Клиентский сертификат и ключ (PEM)
+	ClientCert string
+	ClientKey  string
+	// Не проверять сертификат сервера
+	InsecureSkipVerify *bool
+	// Таймауты подключения, TLS рукопожатия и ожидания заголовков ответа
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+}
+
+// Параметры для хостов по шаблону: точное имя или "*.example.com" для поддоменов
+type HostOverride struct {
+	Pattern string
+	HostTransport
+}
+
+// Настройки транспорта загрузчика http
+type TransportConfig struct {
+	HostTransport
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	// Переопределения по хостам; применяется первое подходящее
+	Hosts []HostOverride
+}
+
+// Транспорт с общим пулом соединений и отдельными транспортами для переопределенных хостов
+type hostRouter struct {
+	base   *guardedTransport
+	routes []hostRoute
+}
+
+type hostRoute struct {
+	pattern string
+	rt      *guardedTransport
+}
+
+// Транспорт, соединения которого проверяются политикой исходящих соединений
+type guardedTransport struct {
+	*http.Transport
+	policy *egress.Policy
+	// Разрешение имен хостов запросов через прокси
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// Создает транспорт загрузчика http. Прямые соединения проверяются policy при подключении;
+// соединение с прокси, заданным администратором, не проверяется, а хост запроса через прокси
+// разрешается и проверяется до отправки
+func NewTransport(cfg TransportConfig, policy *egress.Policy) (http.RoundTripper, error) {
+	base, err := newGuardedTransport(cfg, cfg.HostTransport, policy)
+	if err != nil {
+		return nil, err
+	}
+	r := &hostRouter{base: base}
+	for _, h := range cfg.Hosts {
+		rt, err := newGuardedTransport(cfg, mergeHost(cfg.HostTransport, h.HostTransport), policy)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", h.Pattern, err)
+		}
+		r.routes = append(r.routes, hostRoute{pattern: strings.ToLower(h.Pattern), rt: rt})
+	}
+	return r, nil
+}
+
+func (r *hostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, route := range r.routes {
+		if matchHost(route.pattern, host) {
+			return route.rt.RoundTrip(req)
+		}
+	}
+	return r.base.RoundTrip(req)
+}
+
+func (g *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if g.Proxy != nil {
+		if u, err := g.Proxy(req); err == nil && u != nil {
+			if err := g.checkProxiedHost(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g.Transport.RoundTrip(req)
+}
+
+// Проверяет хост запроса, который уходит через прокси: имя разрешается здесь, так как соединение
+// устанавливает прокси. Прокси разрешает имя сам, поэтому смена DNS ответа между проверкой
+// и соединением не исключена; запрос с именем, которое не удалось разрешить, не отправляется
+func (g *guardedTransport) checkProxiedHost(ctx context.Context, host string) error {
+	if err := g.policy.CheckHost(host); err != nil {
+		return err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+	addrs, err := g.lookup(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, a := range addrs {
+		if err := g.policy.CheckAddr(a.WithZone("")); err != nil {
+			return fmt.Errorf("%s: %w", host, err)
+		}
+	}
+	return nil
+}
+
+func lookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// Шаблон "*.example.com" совпадает с поддоменами example.com, остальные - только с точным именем
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+// Накладывает заданные параметры хоста на общие
+func mergeHost(base, o HostTransport) HostTransport {
+	if o.Proxy != "" {
+		base.Proxy = o.Proxy
+	}
+	if o.CAFile != "" {
+		base.CAFile = o.CAFile
+	}
+	if o.ClientCert != "" || o.ClientKey != "" {
+		base.ClientCert, base.ClientKey = o.ClientCert, o.ClientKey
+	}
+	if o.InsecureSkipVerify != nil {
+		base.InsecureSkipVerify = o.InsecureSkipVerify
+	}
+	if o.ConnectTimeout > 0 {
+		base.ConnectTimeout = o.ConnectTimeout
+	}
+	if o.TLSHandshakeTimeout > 0 {
+		base.TLSHandshakeTimeout = o.TLSHandshakeTimeout
+	}
+	if o.ResponseHeaderTimeout > 0 {
+		base.ResponseHeaderTimeout = o.ResponseHeaderTimeout
+	}
+	return base
+}
+
+func newGuardedTransport(cfg TransportConfig, h HostTransport, policy *egress.Policy) (*guardedTransport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	plain := &net.Dialer{Timeout: h.ConnectTimeout, KeepAlive: 30 * time.Second}
+	guarded := policy.Dialer(*plain)
+	proxyAddrs := map[string]bool{}
+	switch p := strings.ToLower(h.Proxy); p {
+	case "", "direct":
+		t.Proxy = nil
+	case "env":
+		t.Proxy = http.ProxyFromEnvironment
+		for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			if u, err := url.Parse(os.Getenv(key)); err == nil && u.Host != "" {
+				proxyAddrs[proxyAddr(u)] = true
+			}
+		}
+	default:
+		u, err := url.Parse(h.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", h.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		t.Proxy = http.ProxyURL(u)
+		proxyAddrs[proxyAddr(u)] = true
+	}
+	// Соединение с прокси идет без проверки политикой, остальные - через проверяющий dialer
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if proxyAddrs[addr] {
+			return plain.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+	if h.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = h.TLSHandshakeTimeout
+	}
+	t.ResponseHeaderTimeout = h.ResponseHeaderTimeout
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	tc, err := tlsConfig(h)
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tc
+	return &guardedTransport{Transport: t, policy: policy, lookup: lookupHost}, nil
+}
+
+// Адрес прокси в том виде, в котором его передает в DialContext http.Transport
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// Настройки TLS: системные корневые сертификаты с добавлением CAFile и клиентский сертификат
+func tlsConfig(h HostTransport) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if h.InsecureSkipVerify != nil && *h.InsecureSkipVerify {
+		tc.InsecureSkipVerify = true
+	}
+	if h.CAFile != "" {
+		pem, err := os.ReadFile(h.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca file %s: no certificates found", h.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+	if h.ClientCert != "" || h.ClientKey != "" {
+		if h.ClientCert == "" || h.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(h.ClientCert, h.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// Разбирает переопределения по хостам вида "шаблон ключ=значение ...;шаблон2 ...".
+// Ключи: proxy, ca_file, client_cert, client_key, insecure_skip_verify,
+// connect_timeout, tls_handshake_timeout, response_header_timeout
+func ParseHostOverrides(s string) ([]HostOverride, error) {
+	var out []HostOverride
+	for _, def := range strings.Split(s, ";") {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		h := HostOverride{Pattern: strings.ToLower(fields[0])}
+		if strings.Contains(h.Pattern, "=") {
+			return nil, fmt.Errorf("host override %q: missing host pattern", def)
+		}
+		for _, kv := range fields[1:] {
+			key, val, ok := strings.Cut(kv, "=")
+			if !ok || val == "" {
+				return nil, fmt.Errorf("host %s: invalid setting %q", h.Pattern, kv)
+			}
+			if err := h.set(key, val); err != nil {
+				return nil, fmt.Errorf("host %s: %s: %w", h.Pattern, key, err)
+			}
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func (h *HostOverride) set(key, val string) error {
+	var err error
+	switch key {
+	case "proxy":
+		h.Proxy = val
+	case "ca_file":
+		h.CAFile = val
+	case "client_cert":
+		h.ClientCert = val
+	case "client_key":
+		h.ClientKey = val
+	case "insecure_skip_verify":
+		var b bool
+		b, err = strconv.ParseBool(val)
+		h.InsecureSkipVerify = &b
+	case "connect_timeout":
+		h.ConnectTimeout, err = time.ParseDuration(val)
+	case "tls_handshake_timeout":
+		h.TLSHandshakeTimeout, err = time.ParseDuration(val)
+	case "response_header_timeout":
+		h.ResponseHeaderTimeout, err = time.ParseDuration(val)
+	default:
+		return fmt.Errorf("unknown setting")
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"taskservice/internal/egress"
+)
+
+// Прокси, который отвечает сам и запоминает запрошенные URL
+type recordingProxy struct {
+	*httptest.Server
+	mu   sync.Mutex
+	urls []string
+}
+
+func newRecordingProxy(t *testing.T) *recordingProxy {
+	p := &recordingProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.urls = append(p.urls, r.URL.String())
+		p.mu.Unlock()
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *recordingProxy) requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.urls...)
+}
+
+func TestProxiedRequestsAreCheckedAgainstEgress(t *testing.T) {
+	proxy := newRecordingProxy(t)
+	policy, err := egress.New(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := NewTransport(TransportConfig{HostTransport: HostTransport{Proxy: proxy.URL}}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := map[string][]netip.Addr{
+		"public.example":   {netip.MustParseAddr("93.184.216.34")},
+		"internal.example": {netip.MustParseAddr("10.0.0.5")},
+		"mixed.example":    {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("169.254.169.254")},
+		"nat64.example":    {netip.MustParseAddr("64:ff9b::7f00:1")},
+	}
+	rt.(*hostRouter).base.lookup = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if addrs, ok := hosts[host]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	client := &http.Client{Transport: rt}
+
+	resp, err := client.Get("http://public.example/file")
+	if err != nil {
+		t.Fatalf("public host: %v", err)
+	}
+	resp.Body.Close()
+
+	for _, u := range []string{
+		"http://internal.example/file",
+		"http://mixed.example/file",
+		"http://nat64.example/file",
+		"http://127.0.0.1/file",
+		"http://localhost/file",
+	} {
+		if _, err := client.Get(u); !errors.Is(err, egress.ErrBlocked) {
+			t.Errorf("GET %s: %v, want ErrBlocked", u, err)
+		}
+	}
+	if _, err := client.Get("http://unknown.example/file"); err == nil {
+		t.Error("GET of an unresolvable host succeeded")
+	}
+
+	if got := proxy.requests(); len(got) != 1 || got[0] != "http://public.example/file" {
+		t.Fatalf("proxy received %v, want only the public host", got)
+	}
+}
+
+func TestDirectRequestsAreCheckedOnDial(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	policy, err := egress.New(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := NewTransport(TransportConfig{}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: rt}).Get(srv.URL); !errors.Is(err, egress.ErrBlocked) {
+		t.Fatalf("GET %s: %v, want ErrBlocked", srv.URL, err)
+	}
+}
+
+func TestParseHostOverrides(t *testing.T) {
+	got, err := ParseHostOverrides(" *.corp.example proxy=direct ca_file=/etc/ca.pem connect_timeout=5s ; Legacy.example insecure_skip_verify=true;;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("overrides %+v, want 2", got)
+	}
+	if h := got[0]; h.Pattern != "*.corp.example" || h.Proxy != "direct" || h.CAFile != "/etc/ca.pem" || h.ConnectTimeout != 5*time.Second {
+		t.Fatalf("first override %+v", h)
+	}
+	if h := got[1]; h.Pattern != "legacy.example" || h.InsecureSkipVerify == nil || !*h.InsecureSkipVerify {
+		t.Fatalf("second override %+v", h)
+	}
+
+	for _, in := range []string{
+		"proxy=direct",
+		"host.example proxy",
+		"host.example unknown=1",
+		"host.example connect_timeout=soon",
+		"host.example insecure_skip_verify=maybe",
+	} {
+		if _, err := ParseHostOverrides(in); err == nil {
+			t.Errorf("ParseHostOverrides(%q) accepted", in)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchHost(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostOverridesSelectTransport(t *testing.T) {
+	proxy := newRecordingProxy(t)
+	policy, err := egress.New(nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer direct.Close()
+	cfg := TransportConfig{
+		HostTransport: HostTransport{Proxy: proxy.URL},
+		Hosts:         []HostOverride{{Pattern: "127.0.0.1", HostTransport: HostTransport{Proxy: "direct"}}},
+	}
+	rt, err := NewTransport(cfg, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.(*hostRouter).base.lookup = func(context.Context, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+	}
+	client := &http.Client{Transport: rt}
+
+	// Хост из переопределения идет напрямую, остальные - через общий прокси
+	resp, err := client.Get(direct.URL + "/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "direct" {
+		t.Fatalf("overridden host answered %q, want direct", body)
+	}
+	resp, err = client.Get("http://other.example/y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := proxy.requests(); len(got) != 1 || got[0] != "http://other.example/y" {
+		t.Fatalf("proxy received %v", got)
+	}
+
+	if _, err := NewTransport(TransportConfig{HostTransport: HostTransport{Proxy: "ftp://proxy.example"}}, policy); err == nil {
+		t.Fatal("unsupported proxy scheme accepted")
+	}
+	if _, err := NewTransport(TransportConfig{Hosts: []HostOverride{{Pattern: "x", HostTransport: HostTransport{CAFile: "/nonexistent.pem"}}}}, policy); err == nil {
+		t.Fatal("missing CA file accepted")
+	}
+}
@@ -0,0 +1,178 @@
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrBlocked возвращается для адресов, соединение с которыми запрещено политикой
+var ErrBlocked = errors.New("address blocked by egress policy")
+
+// Диапазоны, закрытые по умолчанию: локальные, частные, link-local и служебные
+var privateRanges = mustPrefixes(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b:1::/48",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// Префиксы NAT64 (RFC 6052) и 6to4 (RFC 3056): адрес ведет на встроенный в него IPv4 адрес
+var (
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour   = netip.MustParsePrefix("2002::/16")
+)
+
+// Политика исходящих соединений загрузчиков.
+// Порядок проверки: deny, затем allow, затем закрытые по умолчанию диапазоны
+type Policy struct {
+	allow        []netip.Prefix
+	deny         []netip.Prefix
+	allowPrivate bool
+}
+
+// Создает политику из списков CIDR; allowPrivate открывает диапазоны, закрытые по умолчанию
+func New(allow, deny []string, allowPrivate bool) (*Policy, error) {
+	p := &Policy{allowPrivate: allowPrivate}
+	var err error
+	if p.allow, err = parsePrefixes(allow); err != nil {
+		return nil, fmt.Errorf("egress allow: %w", err)
+	}
+	if p.deny, err = parsePrefixes(deny); err != nil {
+		return nil, fmt.Errorf("egress deny: %w", err)
+	}
+	return p, nil
+}
+
+// Проверяет адрес, с которым устанавливается соединение
+func (p *Policy) CheckAddr(ip netip.Addr) error {
+	ip = ip.Unmap()
+	switch {
+	case contains(p.deny, ip):
+		return fmt.Errorf("%w: %s is denied", ErrBlocked, ip)
+	case contains(p.allow, ip):
+		return nil
+	}
+	// Встроенный IPv4 адрес проверяется той же политикой, иначе через NAT64 или 6to4 доступны частные сети
+	if v4, ok := embeddedIPv4(ip); ok {
+		if err := p.CheckAddr(v4); err != nil {
+			return fmt.Errorf("%w (embedded in %s)", err, ip)
+		}
+		return nil
+	}
+	if !p.allowPrivate && contains(privateRanges, ip) {
+		return fmt.Errorf("%w: %s is a private or local address", ErrBlocked, ip)
+	}
+	return nil
+}
+
+// Проверяет хост URL без обращения к DNS: IP адреса и localhost.
+// Имена проверяются только при соединении, после разрешения
+func (p *Policy) CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return p.CheckAddr(netip.AddrFrom4([4]byte{127, 0, 0, 1}))
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return nil
+	}
+	return p.CheckAddr(ip.WithZone(""))
+}
+
+// Функция для net.Dialer.Control: вызывается для каждого соединения с уже разрешенным адресом,
+// поэтому действует и после перенаправлений, и при смене DNS ответа
+func (p *Policy) Control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: unexpected address %q", ErrBlocked, address)
+	}
+	return p.CheckAddr(ap.Addr().WithZone(""))
+}
+
+// Возвращает dialer, соединения которого проверяются политикой
+func (p *Policy) Dialer(d net.Dialer) *net.Dialer {
+	d.Control = p.Control
+	return &d
+}
+
+// IPv4 адрес, встроенный в адрес NAT64 (последние 4 байта) или 6to4 (байты 2-5)
+func embeddedIPv4(ip netip.Addr) (netip.Addr, bool) {
+	b := ip.As16()
+	switch {
+	case !ip.Is6():
+		return netip.Addr{}, false
+	case nat64Prefix.Contains(ip):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case sixToFour.Contains(ip):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	}
+	return netip.Addr{}, false
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, pr := range prefixes {
+		if pr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Разбирает CIDR; одиночный адрес означает префикс из одного адреса
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			ip = ip.Unmap()
+			out = append(out, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		pr, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", s)
+		}
+		out = append(out, unmapPrefix(pr.Masked()))
+	}
+	return out, nil
+}
+
+// Приводит IPv4-mapped префикс к IPv4, так как адреса сравниваются после Unmap
+func unmapPrefix(pr netip.Prefix) netip.Prefix {
+	if a := pr.Addr(); a.Is4In6() && pr.Bits() >= 96 {
+		return netip.PrefixFrom(a.Unmap(), pr.Bits()-96)
+	}
+	return pr
+}
+
+func mustPrefixes(list ...string) []netip.Prefix {
+	out, err := parsePrefixes(list)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
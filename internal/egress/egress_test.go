@@ -0,0 +1,118 @@
+package egress
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestCheckAddr(t *testing.T) {
+	p, err := New([]string{"10.1.2.0/24", "2002:a01:203::/48"}, []string{"8.8.4.4", "::ffff:1.1.1.0/120"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"127.0.0.1", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		// Разрешенные и запрещенные списки
+		{"10.1.2.3", false},
+		{"8.8.4.4", true},
+		{"1.1.1.1", true},
+		// NAT64 и 6to4 проверяются по встроенному IPv4 адресу
+		{"64:ff9b::7f00:1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::5db8:d822", false},
+		{"64:ff9b::808:404", true},
+		{"64:ff9b::a01:203", false},
+		{"64:ff9b:1::5db8:d822", true},
+		{"2002:7f00:1::1", true},
+		{"2002:c0a8:101::1", true},
+		{"2002:5db8:d822::1", false},
+		{"2002:a01:203::1", false},
+	}
+	for _, tt := range tests {
+		err := p.CheckAddr(netip.MustParseAddr(tt.addr))
+		if blocked := errors.Is(err, ErrBlocked); blocked != tt.blocked {
+			t.Errorf("CheckAddr(%s) = %v, want blocked %v", tt.addr, err, tt.blocked)
+		}
+	}
+}
+
+func TestAllowPrivate(t *testing.T) {
+	p, err := New(nil, []string{"10.9.0.0/16"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, blocked := range map[string]bool{
+		"127.0.0.1":        false,
+		"64:ff9b::a00:1":   false,
+		"10.9.1.1":         true,
+		"64:ff9b::a09:101": true,
+		"2002:a09:101::1":  true,
+	} {
+		if err := p.CheckAddr(netip.MustParseAddr(addr)); errors.Is(err, ErrBlocked) != blocked {
+			t.Errorf("CheckAddr(%s) = %v, want blocked %v", addr, err, blocked)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	p, err := New(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for host, blocked := range map[string]bool{
+		"localhost":         true,
+		"LOCALHOST.":        true,
+		"api.localhost":     true,
+		"127.0.0.1":         true,
+		"::1":               true,
+		"fe80::1%eth0":      true,
+		"example.com":       false,
+		"93.184.216.34":     false,
+		"localhost.example": false,
+		"64:ff9b::c0a8:101": true,
+	} {
+		if err := p.CheckHost(host); errors.Is(err, ErrBlocked) != blocked {
+			t.Errorf("CheckHost(%s) = %v, want blocked %v", host, err, blocked)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	p, err := New(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Control("tcp", "127.0.0.1:80", nil); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("Control(127.0.0.1:80) = %v, want ErrBlocked", err)
+	}
+	if err := p.Control("tcp6", "[64:ff9b::a00:1]:443", nil); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("Control(NAT64 10.0.0.1) = %v, want ErrBlocked", err)
+	}
+	if err := p.Control("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Fatalf("Control(public) = %v", err)
+	}
+}
+
+func TestNewRejectsInvalidCIDR(t *testing.T) {
+	if _, err := New([]string{"10.0.0.0/33"}, nil, false); err == nil {
+		t.Fatal("invalid allow cidr accepted")
+	}
+	if _, err := New(nil, []string{"not-an-ip"}, false); err == nil {
+		t.Fatal("invalid deny address accepted")
+	}
+}
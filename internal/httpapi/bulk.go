@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"taskservice/internal/manager"
+	"taskservice/internal/manifest"
+	"taskservice/internal/model"
+)
+
+// Сколько отклоненных строк перечисляется в отчете; остальные только считаются
+const maxReportedLines = 1000
+
+// Отклоненная строка списка URL
+type rejectedLine struct {
+	File  string `json:"file,omitempty"` // имя загруженного файла для multipart/form-data
+	Line  int    `json:"line"`
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error"`
+}
+
+// Отчет о создании задачи из списка URL
+type bulkReport struct {
+	ID       string         `json:"id,omitempty"`
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Errors   []rejectedLine `json:"errors,omitempty"`
+}
+
+// Собирает источники из строк списка и проверяет каждую строку
+type bulkCollector struct {
+	mgr    *manager.Manager
+	srcs   []model.Source
+	report bulkReport
+}
+
+// Возвращает обработчик строк файла для manifest.ScanLines
+func (c *bulkCollector) lines(file string) func(int, model.Source, error) error {
+	return func(n int, src model.Source, err error) error {
+		if err == nil {
+			err = c.mgr.ValidateSource(src)
+		}
+		if err != nil {
+			c.report.Rejected++
+			if len(c.report.Errors) < maxReportedLines {
+				c.report.Errors = append(c.report.Errors, rejectedLine{File: file, Line: n, URL: src.URL, Error: err.Error()})
+			}
+			return nil
+		}
+		c.srcs = append(c.srcs, src)
+		c.report.Accepted++
+		return nil
+	}
+}
+
+// Создает задачу из text/plain: по одному URL в строке, параметры задачи - JSON в параметре запроса options
+func handleCreateTaskText(w http.ResponseWriter, r *http.Request, mgr *manager.Manager) {
+	var opts model.TaskOptions
+	if v := r.URL.Query().Get("options"); v != "" {
+		if err := json.Unmarshal([]byte(v), &opts); err != nil {
+			http.Error(w, "invalid options json", http.StatusBadRequest)
+			return
+		}
+	}
+	c := &bulkCollector{mgr: mgr}
+	if err := manifest.ScanLines(r.Body, c.lines("")); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	createBulkTask(w, r, mgr, c, opts)
+}
+
+// Создает задачу из multipart/form-data: списки URL в файлах формы, параметры задачи - JSON в поле options.
+// Части читаются потоком по мере поступления
+func handleCreateTaskMultipart(w http.ResponseWriter, r *http.Request, mgr *manager.Manager) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "invalid multipart body", http.StatusBadRequest)
+		return
+	}
+	var opts model.TaskOptions
+	c := &bulkCollector{mgr: mgr}
+	files := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		switch {
+		case part.FileName() != "" || part.FormName() == "urls":
+			files++
+			err = manifest.ScanLines(part, c.lines(part.FileName()))
+		case part.FormName() == "options":
+			if derr := json.NewDecoder(io.LimitReader(part, 1<<20)).Decode(&opts); derr != nil {
+				err = errors.New("invalid options json")
+			}
+		}
+		part.Close()
+		if err != nil {
+			http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if files == 0 {
+		http.Error(w, "multipart body must contain a file with urls", http.StatusBadRequest)
+		return
+	}
+	createBulkTask(w, r, mgr, c, opts)
+}
+
+// Создает задачу из собранных строк. Если есть отклоненные строки, задача создается
+// только с параметром skip_invalid=true; отчет возвращается в обоих случаях
+func createBulkTask(w http.ResponseWriter, r *http.Request, mgr *manager.Manager, c *bulkCollector, opts model.TaskOptions) {
+	skipInvalid := r.URL.Query().Get("skip_invalid") == "true"
+	if c.report.Accepted == 0 || (c.report.Rejected > 0 && !skipInvalid) {
+		writeJSON(w, c.report, http.StatusBadRequest)
+		return
+	}
+	id, err := mgr.CreateTask(c.srcs, opts)
+	if errors.Is(err, manager.ErrInvalidTask) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("create task failed", "error", err)
+		http.Error(w, "failed to create task", http.StatusInternalServerError)
+		return
+	}
+	c.report.ID = string(id)
+	writeJSON(w, c.report, http.StatusCreated)
+}
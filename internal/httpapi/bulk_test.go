@@ -0,0 +1,155 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"taskservice/internal/egress"
+	"taskservice/internal/manager"
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+// Задачи в тестах откладываются, чтобы воркеры ничего не загружали
+const laterOptions = `{"not_before": "2099-01-01T00:00:00Z"}`
+
+func newTestServer(t *testing.T) (*httptest.Server, *manager.Manager, *storage.Store) {
+	t.Helper()
+	dir := t.TempDir()
+	st, err := storage.NewStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	policy, err := egress.New(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := manager.NewManager(manager.Config{
+		Store:       st,
+		DataDir:     filepath.Join(dir, "data"),
+		WorkerCount: 1,
+		Egress:      policy,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = mgr.StopAndWait(ctx)
+	})
+	mux := http.NewServeMux()
+	RegisterHandlers(mux, mgr)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, mgr, st
+}
+
+func decodeReport(t *testing.T, resp *http.Response) bulkReport {
+	t.Helper()
+	defer resp.Body.Close()
+	var rep bulkReport
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		t.Fatalf("decode report (status %d): %v", resp.StatusCode, err)
+	}
+	return rep
+}
+
+const urlList = `# список
+https://a.example/1
+https://b.example/2 https://m.example/2
+ftp:/broken
+http://127.0.0.1/internal
+`
+
+func TestCreateTaskFromText(t *testing.T) {
+	srv, mgr, _ := newTestServer(t)
+	post := func(query string) *http.Response {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/tasks?options="+url.QueryEscape(laterOptions)+query, "text/plain", bytes.NewBufferString(urlList))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := post("")
+	rep := decodeReport(t, resp)
+	if resp.StatusCode != http.StatusBadRequest || rep.ID != "" || rep.Accepted != 2 || rep.Rejected != 2 {
+		t.Fatalf("status %d, report %+v; want 400 with 2 accepted and 2 rejected", resp.StatusCode, rep)
+	}
+	if rep.Errors[0].Line != 4 || rep.Errors[1].Line != 5 || rep.Errors[1].URL != "http://127.0.0.1/internal" {
+		t.Fatalf("rejected lines %+v, want lines 4 and 5", rep.Errors)
+	}
+
+	resp = post("&skip_invalid=true")
+	rep = decodeReport(t, resp)
+	if resp.StatusCode != http.StatusCreated || rep.ID == "" {
+		t.Fatalf("status %d, report %+v; want 201 with task id", resp.StatusCode, rep)
+	}
+	task, ok := mgr.GetTask(model.TaskID(rep.ID))
+	if !ok || len(task.Items) != 2 || len(task.Items[1].Mirrors) != 1 || task.Status != model.TaskStatusScheduled {
+		t.Fatalf("created task %+v", task)
+	}
+}
+
+func TestCreateTaskFromMultipart(t *testing.T) {
+	srv, mgr, _ := newTestServer(t)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("options", laterOptions); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"one.txt": "https://a.example/1\n", "two.txt": "https://b.example/2\nnot a url\n"} {
+		fw, err := mw.CreateFormFile("urls", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, content)
+	}
+	mw.Close()
+
+	resp, err := http.Post(srv.URL+"/tasks?skip_invalid=true", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := decodeReport(t, resp)
+	if resp.StatusCode != http.StatusCreated || rep.Accepted != 2 || rep.Rejected != 1 {
+		t.Fatalf("status %d, report %+v", resp.StatusCode, rep)
+	}
+	if e := rep.Errors[0]; e.File != "two.txt" || e.Line != 2 {
+		t.Fatalf("rejected line %+v, want two.txt line 2", e)
+	}
+	if task, ok := mgr.GetTask(model.TaskID(rep.ID)); !ok || len(task.Items) != 2 {
+		t.Fatalf("created task %+v", task)
+	}
+
+	// Форма без файла со списком
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	mw.WriteField("options", laterOptions)
+	mw.Close()
+	resp, err = http.Post(srv.URL+"/tasks", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("multipart without urls: status %d, want 400", resp.StatusCode)
+	}
+}
@@ -2,16 +2,23 @@ package httpapi
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strings"
+	"time"
 
 	"taskservice/internal/manager"
+	"taskservice/internal/metrics"
 	"taskservice/internal/model"
 )
 
 type createTaskRequest struct {
-	URLs []string `json:"urls"`
+	URLs []model.Source `json:"urls"` // строки URL или объекты {"url", "mirrors", "checksum"}
+	// URL манифеста со списком файлов вместо urls
+	Manifest string `json:"manifest"`
+	model.TaskOptions
 }
 
 type createTaskResponse struct {
@@ -31,38 +38,112 @@ func RegisterHandlers(mux *http.ServeMux, mgr *manager.Manager) {
 		}
 	})
 	mux.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		id := strings.TrimPrefix(r.URL.Path, "/tasks/")
+		id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/")
 		if id == "" {
 			http.NotFound(w, r)
 			return
 		}
-		t, ok := mgr.GetTask(model.TaskID(id))
-		if !ok {
+		switch {
+		case sub == "" && r.Method == http.MethodGet:
+			t, ok := mgr.GetTask(model.TaskID(id))
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, t, http.StatusOK)
+		case sub == "" && r.Method == http.MethodDelete:
+			handleDeleteTask(w, r, mgr, model.TaskID(id))
+		case sub == "history" && r.Method == http.MethodGet:
+			handleTaskHistory(w, r, mgr, model.TaskID(id))
+		case sub == "" || sub == "history":
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		default:
 			http.NotFound(w, r)
+		}
+	})
+	registerScheduleHandlers(mux, mgr)
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		writeJSON(w, t, http.StatusOK)
+		metrics.Default.Handler().ServeHTTP(w, r)
 	})
 }
 
-// Обработчик создания задачи
+type deleteTaskResponse struct {
+	ID           string   `json:"id"`
+	RemovedFiles []string `json:"removed_files"`
+}
+
+// Обработчик удаления задачи
+func handleDeleteTask(w http.ResponseWriter, r *http.Request, mgr *manager.Manager, id model.TaskID) {
+	q := r.URL.Query()
+	purge := q.Get("purge_files") == "true"
+	force := q.Get("force") == "true"
+	files, err := mgr.DeleteTask(id, purge, force)
+	switch {
+	case errors.Is(err, manager.ErrTaskNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, manager.ErrTaskBusy):
+		http.Error(w, "task has items downloading, use force=true to cancel it first", http.StatusConflict)
+		return
+	case err != nil:
+		slog.Error("delete task failed", "task_id", id, "error", err)
+		http.Error(w, "failed to delete task", http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		files = []string{}
+	}
+	writeJSON(w, deleteTaskResponse{ID: string(id), RemovedFiles: files}, http.StatusOK)
+}
+
+// Обработчик истории переходов задачи
+func handleTaskHistory(w http.ResponseWriter, r *http.Request, mgr *manager.Manager, id model.TaskID) {
+	h, ok := mgr.GetTaskHistory(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, h, http.StatusOK)
+}
+
+// Обработчик создания задачи: JSON, text/plain или multipart/form-data со списком URL
 func handleCreateTask(w http.ResponseWriter, r *http.Request, mgr *manager.Manager) {
+	switch mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt {
+	case "text/plain":
+		handleCreateTaskText(w, r, mgr)
+		return
+	case "multipart/form-data":
+		handleCreateTaskMultipart(w, r, mgr)
+		return
+	}
 	var req createTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	if len(req.URLs) == 0 {
-		http.Error(w, "urls is required", http.StatusBadRequest)
+	if (len(req.URLs) == 0) == (req.Manifest == "") {
+		http.Error(w, "exactly one of urls and manifest is required", http.StatusBadRequest)
+		return
+	}
+	var (
+		id  model.TaskID
+		err error
+	)
+	if req.Manifest != "" {
+		id, err = mgr.CreateManifestTask(req.Manifest, req.TaskOptions)
+	} else {
+		id, err = mgr.CreateTask(req.URLs, req.TaskOptions)
+	}
+	if errors.Is(err, manager.ErrInvalidTask) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	id, err := mgr.CreateTask(req.URLs)
 	if err != nil {
-		log.Printf("create task error: %v", err)
+		slog.Error("create task failed", "error", err)
 		http.Error(w, "failed to create task", http.StatusInternalServerError)
 		return
 	}
@@ -70,8 +151,36 @@ func handleCreateTask(w http.ResponseWriter, r *http.Request, mgr *manager.Manag
 }
 
 // Обработчик списка задач
+// Параметры scheduled_after и scheduled_before (RFC3339) оставляют задачи с not_before в интервале [after, before)
 func handleListTasks(w http.ResponseWriter, r *http.Request, mgr *manager.Manager) {
+	var after, before time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"scheduled_after", &after}, {"scheduled_before", &before}} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid "+p.name+": expected RFC3339 time", http.StatusBadRequest)
+			return
+		}
+		*p.dst = ts
+	}
 	tasks := mgr.ListTasks()
+	if !after.IsZero() || !before.IsZero() {
+		filtered := make([]*model.Task, 0, len(tasks))
+		for _, t := range tasks {
+			nb := t.Options.NotBefore
+			if nb == nil || (!after.IsZero() && nb.Before(after)) || (!before.IsZero() && !nb.Before(before)) {
+				continue
+			}
+			filtered = append(filtered, t)
+		}
+		tasks = filtered
+	}
 	writeJSON(w, tasks, http.StatusOK)
 }
 
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Буфер журнала, в который обработчики пишут из горутин сервера
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHandlerErrorsAreLogged(t *testing.T) {
+	var logs logBuffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	srv, _, st := newTestServer(t)
+	// Закрытое хранилище не может сохранить задачу
+	st.Close()
+	resp, err := http.Post(srv.URL+"/tasks", "application/json",
+		strings.NewReader(`{"urls": ["https://a.example/1"], "not_before": "2099-01-01T00:00:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", resp.StatusCode)
+	}
+	if out := logs.String(); !strings.Contains(out, "level=ERROR") || !strings.Contains(out, `msg="create task failed"`) {
+		t.Fatalf("log %q, want create task failure at ERROR", out)
+	}
+}
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"taskservice/internal/manager"
+	"taskservice/internal/model"
+)
+
+// Параметры расписания, задаваемые клиентом
+type scheduleRequest struct {
+	URLs     []model.Source      `json:"urls"`
+	Manifest string              `json:"manifest"`
+	Cron     string              `json:"cron"`
+	Timezone string              `json:"timezone"`
+	Options  model.TaskOptions   `json:"options"`
+	Retain   int                 `json:"retain"`
+	CatchUp  model.CatchUpPolicy `json:"catch_up"`
+	Paused   bool                `json:"paused"`
+}
+
+func (req scheduleRequest) schedule() model.Schedule {
+	return model.Schedule{
+		URLs:     req.URLs,
+		Manifest: req.Manifest,
+		Cron:     req.Cron,
+		Timezone: req.Timezone,
+		Options:  req.Options,
+		Retain:   req.Retain,
+		CatchUp:  req.CatchUp,
+		Paused:   req.Paused,
+	}
+}
+
+// Регистрирует обработчики ресурса /schedules
+func registerScheduleHandlers(mux *http.ServeMux, mgr *manager.Manager) {
+	mux.HandleFunc("/schedules", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleSaveSchedule(w, r, mgr, "")
+		case http.MethodGet:
+			writeJSON(w, mgr.ListSchedules(), http.StatusOK)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/schedules/", func(w http.ResponseWriter, r *http.Request) {
+		raw, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/schedules/"), "/")
+		if raw == "" {
+			http.NotFound(w, r)
+			return
+		}
+		id := model.ScheduleID(raw)
+		switch {
+		case sub == "" && r.Method == http.MethodGet:
+			sc, ok := mgr.GetSchedule(id)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, sc, http.StatusOK)
+		case sub == "" && r.Method == http.MethodPut:
+			handleSaveSchedule(w, r, mgr, id)
+		case sub == "" && r.Method == http.MethodDelete:
+			if err := mgr.DeleteSchedule(id); err != nil {
+				if errors.Is(err, manager.ErrScheduleNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				slog.Error("delete schedule failed", "schedule_id", id, "error", err)
+				http.Error(w, "failed to delete schedule", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case sub == "tasks" && r.Method == http.MethodGet:
+			handleScheduleTasks(w, r, mgr, id)
+		case sub == "" || sub == "tasks":
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// Обработчик создания (пустой id) и замены расписания
+func handleSaveSchedule(w http.ResponseWriter, r *http.Request, mgr *manager.Manager, id model.ScheduleID) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	var (
+		sc   *model.Schedule
+		err  error
+		code = http.StatusOK
+	)
+	if id == "" {
+		sc, err = mgr.CreateSchedule(req.schedule())
+		code = http.StatusCreated
+	} else {
+		sc, err = mgr.UpdateSchedule(id, req.schedule())
+	}
+	switch {
+	case errors.Is(err, manager.ErrScheduleNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, manager.ErrInvalidSchedule), errors.Is(err, manager.ErrInvalidTask):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		slog.Error("save schedule failed", "schedule_id", id, "error", err)
+		http.Error(w, "failed to save schedule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, sc, code)
+}
+
+// Обработчик списка задач, созданных расписанием
+func handleScheduleTasks(w http.ResponseWriter, r *http.Request, mgr *manager.Manager, id model.ScheduleID) {
+	if _, ok := mgr.GetSchedule(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	tasks := make([]*model.Task, 0)
+	for _, t := range mgr.ListTasks() {
+		if t.ScheduleID == id {
+			tasks = append(tasks, t)
+		}
+	}
+	writeJSON(w, tasks, http.StatusOK)
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+
+	"taskservice/internal/model"
+)
+
+// Регистрирует обработку элемента задачи и возвращает контекст, отменяемый CancelTask
+func (m *Manager) beginItem(id model.TaskID) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.tasksMu.Lock()
+	m.active[id] = cancel
+	m.tasksMu.Unlock()
+	return ctx, cancel
+}
+
+// Снимает регистрацию обработки элемента задачи
+func (m *Manager) endItem(id model.TaskID, cancel context.CancelFunc) {
+	m.tasksMu.Lock()
+	delete(m.active, id)
+	m.tasksMu.Unlock()
+	cancel()
+}
+
+// Обрабатывает ли воркер сейчас элемент задачи
+func (m *Manager) taskActive(id model.TaskID) bool {
+	m.tasksMu.RLock()
+	defer m.tasksMu.RUnlock()
+	_, ok := m.active[id]
+	return ok
+}
+
+// Прерывает загрузку элемента задачи, если она идет
+func (m *Manager) abortActive(id model.TaskID) {
+	m.tasksMu.RLock()
+	cancel, ok := m.active[id]
+	m.tasksMu.RUnlock()
+	if ok {
+		cancel()
+	}
+}
+
+// CancelTask отменяет задачу: прерывает текущую загрузку и помечает незавершенные элементы отмененными
+func (m *Manager) CancelTask(id model.TaskID, actor model.Actor) error {
+	if _, ok := m.store.GetTask(id); !ok {
+		return ErrTaskNotFound
+	}
+	m.abortActive(id)
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+	t, ok := m.store.GetTask(id)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if t.Status.IsTerminal() {
+		return nil
+	}
+	for idx := range t.Items {
+		if t.Items[idx].Status != model.ItemStatusDone {
+			m.setItemStatus(t, idx, model.ItemStatusCanceled, actor)
+		}
+	}
+	m.setTaskStatus(t, model.TaskStatusCanceled, actor)
+	return m.store.UpdateTask(t)
+}
+
+// DeleteTask удаляет задачу и, при purgeFiles, ее файлы из DATA_DIR.
+// Если элемент задачи сейчас загружается, без force возвращается ErrTaskBusy,
+// а с force задача сначала отменяется.
+func (m *Manager) DeleteTask(id model.TaskID, purgeFiles, force bool) ([]string, error) {
+	if _, ok := m.store.GetTask(id); !ok {
+		return nil, ErrTaskNotFound
+	}
+	// Занятость определяется по обрабатываемым элементам, а не по блокировке задачи:
+	// ее ненадолго берут и планировщик, и очистка, и повтор после паузы
+	if m.taskActive(id) {
+		if !force {
+			return nil, ErrTaskBusy
+		}
+		if err := m.CancelTask(id, model.ActorAPI); err != nil {
+			return nil, err
+		}
+	}
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	t, err := m.removeTask(id)
+	lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	m.forgetTaskLock(id)
+	files, _ := m.cleanupTask(t, purgeFiles)
+	m.rescanUsage()
+	return files, nil
+}
@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+func TestDeletedTaskIsNotRequeuedAfterBackoff(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxRetryPerItem: 5, BaseBackoff: 300 * time.Millisecond})
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/file"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Элемент ждет повтора
+	waitFor(t, "first failed attempt", func() bool {
+		var inBackoff bool
+		withTask(m, id, func(t *model.Task) { inBackoff = t.Items[0].Status == model.ItemStatusError })
+		return inBackoff
+	})
+	if _, err := m.DeleteTask(id, true, false); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	before := hits.Load()
+	waitRetryBackoff(t, m)
+
+	if _, ok := m.GetTask(id); ok {
+		t.Fatal("deleted task is back after retry backoff")
+	}
+	if n := hits.Load(); n != before {
+		t.Fatalf("deleted task was downloaded again: %d requests after delete", n-before)
+	}
+	if len(m.store.GetHistory(id)) != 0 {
+		t.Fatal("history of deleted task is back")
+	}
+}
+
+func TestDeleteTaskIsBusyOnlyWhileDownloading(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	m := newTestManager(t, Config{})
+	running, err := m.CreateTask([]model.Source{{URL: srv.URL + "/big"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := m.DeleteTask(running, true, false); !errors.Is(err, ErrTaskBusy) {
+		t.Fatalf("DeleteTask of a downloading task: %v, want ErrTaskBusy", err)
+	}
+
+	// Блокировку простаивающей задачи ненадолго держит, например, планировщик
+	later := time.Now().Add(time.Hour)
+	idle, err := m.CreateTask([]model.Source{{URL: srv.URL + "/later"}}, model.TaskOptions{NotBefore: &later})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := m.getTaskLock(idle)
+	lock.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.DeleteTask(idle, true, false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		lock.Unlock()
+		t.Fatalf("DeleteTask of an idle task returned %v while its lock was held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	lock.Unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("DeleteTask of an idle task: %v", err)
+	}
+
+	if _, err := m.DeleteTask(running, true, true); err != nil {
+		t.Fatalf("forced DeleteTask: %v", err)
+	}
+	if _, ok := m.GetTask(running); ok {
+		t.Fatal("task is left after forced delete")
+	}
+}
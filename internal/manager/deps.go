@@ -0,0 +1,160 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Состояние зависимостей задачи
+type depState int
+
+const (
+	depsWaiting depState = iota // есть незавершенные зависимости
+	depsDone                    // все зависимости завершились успешно
+	depsFailed                  // зависимость завершилась неудачно или удалена
+)
+
+// Проверяет зависимости создаваемой задачи: задачи существуют, повторов нет,
+// и граф зависимостей не содержит циклов
+func (m *Manager) checkDependencies(opts *model.TaskOptions) error {
+	switch opts.DependencyPolicy {
+	case "":
+		if len(opts.DependsOn) > 0 {
+			opts.DependencyPolicy = model.DependencyFail
+		}
+	case model.DependencyFail, model.DependencyCancel:
+	default:
+		return fmt.Errorf("%w: unknown dependency_policy %q", ErrInvalidTask, opts.DependencyPolicy)
+	}
+	seen := make(map[model.TaskID]bool, len(opts.DependsOn))
+	for i, id := range opts.DependsOn {
+		if seen[id] {
+			return fmt.Errorf("%w: depends_on[%d]: duplicate task %s", ErrInvalidTask, i, id)
+		}
+		seen[id] = true
+		if _, ok := m.store.GetTask(id); !ok {
+			return fmt.Errorf("%w: depends_on[%d]: task %s not found", ErrInvalidTask, i, id)
+		}
+	}
+	// Новая задача еще не может быть чьей-либо зависимостью, но граф существующих задач
+	// мог быть загружен из импортированного состояния
+	state := make(map[model.TaskID]int) // 0 - не посещена, 1 - в обходе, 2 - проверена
+	var visit func(id model.TaskID) error
+	visit = func(id model.TaskID) error {
+		switch state[id] {
+		case 1:
+			return fmt.Errorf("%w: dependency cycle through task %s", ErrInvalidTask, id)
+		case 2:
+			return nil
+		}
+		state[id] = 1
+		if t, ok := m.store.GetTask(id); ok {
+			for _, dep := range t.Options.DependsOn {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[id] = 2
+		return nil
+	}
+	for _, id := range opts.DependsOn {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Определяет состояние зависимостей задачи по статусам задач из хранилища; для неудачи возвращает причину.
+// Статусы зависимостей не читаются из самих задач: их меняют воркеры под блокировкой задачи
+func dependencyState(t *model.Task, statuses map[model.TaskID]model.TaskStatus) (depState, string) {
+	state := depsDone
+	for _, id := range t.Options.DependsOn {
+		status, ok := statuses[id]
+		switch {
+		case !ok:
+			return depsFailed, fmt.Sprintf("dependency %s not found", id)
+		case status == model.TaskStatusFailed || status == model.TaskStatusCanceled:
+			return depsFailed, fmt.Sprintf("dependency %s %s", id, status)
+		case status != model.TaskStatusCompleted:
+			state = depsWaiting
+		}
+	}
+	return state, ""
+}
+
+// Проверяет задачи, ожидающие зависимостей: запускает готовые и завершает те,
+// чьи зависимости не удались, по политике dependency_policy.
+// unblockTask и dependencyFailed проверяют статус задачи повторно под ее блокировкой
+func (m *Manager) resolveBlocked(now time.Time) {
+	statuses := m.store.TaskStatuses()
+	for _, t := range m.store.ListTasks() {
+		if statuses[t.ID] != model.TaskStatusBlocked {
+			continue
+		}
+		switch state, reason := dependencyState(t, statuses); state {
+		case depsDone:
+			m.unblockTask(t.ID, now)
+		case depsFailed:
+			m.dependencyFailed(t.ID, reason)
+		}
+	}
+}
+
+// Снимает блокировку задачи: ставит элементы в очередь или, при будущем not_before, ждет времени запуска
+func (m *Manager) unblockTask(id model.TaskID, now time.Time) {
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	t, ok := m.store.GetTask(id)
+	if !ok || t.Status != model.TaskStatusBlocked {
+		lock.Unlock()
+		return
+	}
+	scheduled := isScheduled(t, now)
+	itemStatus, taskStatus := model.ItemStatusQueued, model.TaskStatusPending
+	if scheduled {
+		itemStatus, taskStatus = model.ItemStatusScheduled, model.TaskStatusScheduled
+	}
+	var queued []queueItem
+	for idx := range t.Items {
+		if t.Items[idx].Status == model.ItemStatusBlocked {
+			m.setItemStatus(t, idx, itemStatus, model.ActorScheduler)
+			queued = append(queued, queueItem{taskID: id, itemIdx: idx})
+		}
+	}
+	m.setTaskStatus(t, taskStatus, model.ActorScheduler)
+	_ = m.store.UpdateTask(t)
+	lock.Unlock()
+	if scheduled {
+		m.wakeScheduler()
+		return
+	}
+	// Как и при запуске отложенной задачи, элементы ставятся в очередь в фоне
+	m.enqueueAll(queued)
+}
+
+// Завершает заблокированную задачу, зависимость которой не удалась
+func (m *Manager) dependencyFailed(id model.TaskID, reason string) {
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+	t, ok := m.store.GetTask(id)
+	if !ok || t.Status != model.TaskStatusBlocked {
+		return
+	}
+	itemStatus, taskStatus := model.ItemStatusError, model.TaskStatusFailed
+	if t.Options.DependencyPolicy == model.DependencyCancel {
+		itemStatus, taskStatus = model.ItemStatusCanceled, model.TaskStatusCanceled
+	}
+	for idx := range t.Items {
+		if t.Items[idx].Status == model.ItemStatusBlocked {
+			t.Items[idx].ErrorMessage = reason
+			m.setItemStatus(t, idx, itemStatus, model.ActorScheduler)
+		}
+	}
+	m.setTaskStatus(t, taskStatus, model.ActorScheduler)
+	_ = m.store.UpdateTask(t)
+}
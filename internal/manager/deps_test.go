@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+func TestDependentTaskWaitsForDependency(t *testing.T) {
+	var release atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dep" && !release.Load() {
+			http.Error(w, "not yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxRetryPerItem: 50, BaseBackoff: 20 * time.Millisecond})
+	dep, err := m.CreateTask([]model.Source{{URL: srv.URL + "/dep"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/next"}}, model.TaskOptions{DependsOn: []model.TaskID{dep}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := taskStatus(m, id); s != model.TaskStatusBlocked {
+		t.Fatalf("dependent task status %s, want blocked", s)
+	}
+	release.Store(true)
+	waitFor(t, "dependent task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+}
+
+func TestDependencyFailurePolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{})
+	dep, err := m.CreateTask([]model.Source{{URL: srv.URL + "/missing"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failID, err := m.CreateTask([]model.Source{{URL: srv.URL + "/a"}}, model.TaskOptions{DependsOn: []model.TaskID{dep}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelID, err := m.CreateTask([]model.Source{{URL: srv.URL + "/b"}},
+		model.TaskOptions{DependsOn: []model.TaskID{dep}, DependencyPolicy: model.DependencyCancel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "dependency failure", func() bool {
+		return taskStatus(m, failID) == model.TaskStatusFailed && taskStatus(m, cancelID) == model.TaskStatusCanceled
+	})
+}
+
+func TestRestartDoesNotRecheckStartedTaskDependencies(t *testing.T) {
+	var serveNext atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/next" && !serveNext.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	st, err := storage.NewStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Store: st, DataDir: filepath.Join(dir, "data")}
+	m := newTestManager(t, cfg)
+	dep, err := m.CreateTask([]model.Source{{URL: srv.URL + "/dep"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/next"}}, model.TaskOptions{DependsOn: []model.TaskID{dep}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Задача запущена после завершения зависимости и завершилась ошибкой
+	waitFor(t, "dependent task failure", func() bool { return taskStatus(m, id) == model.TaskStatusFailed })
+	if _, err := m.DeleteTask(dep, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StopAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	// После перезапуска задача продолжается, хотя ее зависимости уже нет
+	serveNext.Store(true)
+	st, err = storage.NewStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	cfg.Store = st
+	m = newTestManager(t, cfg)
+	waitFor(t, "task completion after restart", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+}
+
+func TestUnblockDoesNotBlockOnFullQueue(t *testing.T) {
+	hold, held := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hold" {
+			close(held)
+			<-hold
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+	defer close(hold)
+
+	m := newTestManager(t, Config{WorkerCount: 1})
+	if _, err := m.CreateTask([]model.Source{{URL: srv.URL + "/hold"}}, model.TaskOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-held
+
+	far := time.Now().Add(time.Hour)
+	dep, err := m.CreateTask([]model.Source{{URL: srv.URL + "/dep"}}, model.TaskOptions{NotBefore: &far})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcs := make([]model.Source, cap(m.queue)+100)
+	for i := range srcs {
+		srcs[i] = model.Source{URL: fmt.Sprintf("%s/f%d", srv.URL, i)}
+	}
+	id, err := m.CreateTask(srcs, model.TaskOptions{DependsOn: []model.TaskID{dep}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.unblockTask(id, time.Now())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("unblocking a task blocked on a full queue")
+	}
+	if s := taskStatus(m, id); s != model.TaskStatusPending {
+		t.Fatalf("task status %s, want pending", s)
+	}
+}
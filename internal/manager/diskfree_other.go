@@ -0,0 +1,11 @@
+//go:build !unix
+
+package manager
+
+import "os"
+
+// Определение свободного места не поддерживается на этой платформе
+func diskFree(dir string) (int64, bool) { return 0, false }
+
+// Жесткие ссылки не различаются: каждый файл учитывается отдельно
+func fileKey(fi os.FileInfo) (interface{}, bool) { return nil, false }
@@ -0,0 +1,26 @@
+//go:build unix
+
+package manager
+
+import (
+	"os"
+	"syscall"
+)
+
+// Возвращает свободное для непривилегированного пользователя место на файловой системе dir
+func diskFree(dir string) (int64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return int64(st.Bavail) * int64(st.Bsize), true
+}
+
+// Возвращает ключ файла для учета жестких ссылок один раз
+func fileKey(fi os.FileInfo) (interface{}, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, false
+	}
+	return [2]uint64{uint64(st.Dev), uint64(st.Ino)}, true
+}
@@ -0,0 +1,33 @@
+package manager
+
+import "errors"
+
+var (
+	// ErrTaskNotFound возвращается, если задачи не существует
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskBusy возвращается при удалении задачи, элементы которой сейчас загружаются
+	ErrTaskBusy = errors.New("task has items downloading")
+	// ErrInvalidTask возвращается, если параметры создаваемой задачи некорректны
+	ErrInvalidTask = errors.New("invalid task")
+	// ErrScheduleNotFound возвращается, если расписания не существует
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrInvalidSchedule возвращается, если параметры расписания некорректны
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// Ошибка, повторная попытка после которой не имеет смысла
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Помечает ошибку как неповторяемую
+func permanent(err error) error { return &permanentError{err: err} }
+
+// Сообщает, что ошибка неповторяемая
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
@@ -0,0 +1,45 @@
+package manager
+
+import "errors"
+
+// Результат загрузки, разделяемый между воркерами, ожидающими один URL
+type flightResult struct {
+	sha  string
+	size int64
+	meta fetchMeta
+	// Источник подтвердил, что ранее полученное содержимое не изменилось
+	unchanged bool
+	err       error
+}
+
+// Незавершенная загрузка URL
+type flight struct {
+	done chan struct{}
+	flightResult
+}
+
+// Загрузка лидера прервана до получения результата
+var errFlightAborted = errors.New("shared download aborted")
+
+// Присоединяется к загрузке URL; leader=true означает, что загружать должен вызывающий
+func (m *Manager) joinFlight(url string) (*flight, bool) {
+	m.flightsMu.Lock()
+	defer m.flightsMu.Unlock()
+	if f, ok := m.flights[url]; ok {
+		return f, false
+	}
+	f := &flight{done: make(chan struct{})}
+	m.flights[url] = f
+	return f, true
+}
+
+// Публикует результат загрузки лидера и будит ожидающих
+func (m *Manager) leaveFlight(url string, f *flight, res flightResult) {
+	m.flightsMu.Lock()
+	if m.flights[url] == f {
+		delete(m.flights, url)
+	}
+	m.flightsMu.Unlock()
+	f.flightResult = res
+	close(f.done)
+}
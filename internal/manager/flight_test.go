@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+func TestConcurrentDownloadsOfOneURLAreShared(t *testing.T) {
+	var hits atomic.Int64
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Write([]byte("shared content"))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{WorkerCount: 2})
+	url := srv.URL + "/file.bin"
+	first, err := m.CreateTask([]model.Source{{URL: url}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	second, err := m.CreateTask([]model.Source{{URL: url}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Второй воркер должен присоединиться к загрузке первого
+	waitFor(t, "second download start", func() bool { return m.taskActive(second) })
+	close(release)
+
+	var shas []string
+	for _, id := range []model.TaskID{first, second} {
+		waitFor(t, "task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+		withTask(m, id, func(t *model.Task) { shas = append(shas, t.Items[0].SHA256) })
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("source requested %d times, want 1", n)
+	}
+	if shas[0] == "" || shas[0] != shas[1] || !m.cas.Has(shas[0]) {
+		t.Fatalf("item hashes %v, want one stored hash", shas)
+	}
+}
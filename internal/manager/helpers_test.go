@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"taskservice/internal/egress"
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+// Создает и запускает менеджер с хранилищем во временном каталоге.
+// Незаданные поля cfg заполняются значениями для тестов: локальные адреса разрешены, журнал отключен
+func newTestManager(t *testing.T, cfg Config) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	if cfg.Store == nil {
+		st, err := storage.NewStore(filepath.Join(dir, "state"))
+		if err != nil {
+			t.Fatalf("NewStore: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		cfg.Store = st
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = filepath.Join(dir, "data")
+	}
+	if cfg.WorkerCount == 0 {
+		cfg.WorkerCount = 2
+	}
+	if cfg.Egress == nil {
+		policy, err := egress.New(nil, nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Egress = policy
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = m.StopAndWait(ctx)
+	})
+	return m
+}
+
+// Ждет выполнения условия не дольше нескольких секунд
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Читает задачу под ее блокировкой; fn получает живой указатель
+func withTask(m *Manager, id model.TaskID, fn func(t *model.Task)) bool {
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+	t, ok := m.store.GetTask(id)
+	if ok {
+		fn(t)
+	}
+	return ok
+}
+
+// Статус задачи; пустая строка - задачи нет
+func taskStatus(m *Manager, id model.TaskID) model.TaskStatus {
+	var s model.TaskStatus
+	withTask(m, id, func(t *model.Task) { s = t.Status })
+	return s
+}
+
+// Ждет, пока истечет пауза перед повтором, начатая до вызова: создает задачу с недоступным
+// источником и ждет ее второй попытки, которая начинается после такой же паузы
+func waitRetryBackoff(t *testing.T, m *Manager) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/probe"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "second attempt of the probe task", func() bool {
+		var attempts int
+		withTask(m, id, func(t *model.Task) { attempts = t.Items[0].Attempts })
+		return attempts >= 2
+	})
+}
@@ -0,0 +1,296 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"taskservice/internal/model"
+	"taskservice/internal/sink"
+)
+
+// Отчет об одном проходе очистки
+type JanitorReport struct {
+	Tasks       []model.TaskID `json:"tasks"`
+	Files       []string       `json:"files"`
+	OrphanParts []string       `json:"orphan_parts"`
+	OrphanBlobs []string       `json:"orphan_blobs"`
+	BytesFreed  int64          `json:"bytes_freed"`
+}
+
+// Фоновая очистка устаревших задач и файлов
+func (m *Manager) janitor() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.cfg.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case now := <-ticker.C:
+			rep := m.RunJanitor(now)
+			if len(rep.Tasks) > 0 || len(rep.Files) > 0 || len(rep.OrphanParts) > 0 || len(rep.OrphanBlobs) > 0 {
+				m.log.Info("janitor cleanup", slog.Any("tasks", rep.Tasks), slog.Int("files", len(rep.Files)),
+					slog.Int("orphan_parts", len(rep.OrphanParts)), slog.Int("orphan_blobs", len(rep.OrphanBlobs)),
+					slog.Int64("bytes_freed", rep.BytesFreed))
+			}
+		}
+	}
+}
+
+// Выполняет один проход очистки: удаляет просроченные задачи и их файлы,
+// а также .part файлы и файлы хранилища по содержимому, не принадлежащие ни одной задаче
+func (m *Manager) RunJanitor(now time.Time) JanitorReport {
+	var rep JanitorReport
+
+	// Списки файлов снимаются до списка задач, чтобы не удалить файл задачи, созданной во время прохода
+	parts := m.listPartFiles()
+	blobs, err := m.cas.List()
+	if err != nil {
+		m.log.Error("janitor: list blobs", slog.String("error", err.Error()))
+	}
+
+	statuses := m.store.TaskStatuses()
+	for _, t := range m.store.ListTasks() {
+		if !m.taskExpired(t, statuses[t.ID], now) {
+			continue
+		}
+		// Задача с истекшим expires_at может еще обрабатываться. Сначала она отменяется: CancelTask
+		// дожидается завершения текущего элемента, а элементы, ждущие повтора, больше не ставятся в очередь.
+		// Для завершенной задачи отмена ничего не меняет
+		if err := m.CancelTask(t.ID, model.ActorJanitor); err != nil {
+			continue
+		}
+		lock := m.getTaskLock(t.ID)
+		lock.Lock()
+		removed, err := m.removeTask(t.ID)
+		lock.Unlock()
+		if err != nil {
+			m.log.Error("janitor: delete task", slog.String("task_id", string(t.ID)), slog.String("error", err.Error()))
+			continue
+		}
+		m.forgetTaskLock(t.ID)
+		files, freed := m.cleanupTask(removed, true)
+		rep.Tasks = append(rep.Tasks, t.ID)
+		rep.Files = append(rep.Files, files...)
+		rep.BytesFreed += freed
+	}
+
+	for _, name := range parts {
+		if m.partOwned(name) {
+			continue
+		}
+		if freed, ok := m.removeFile(filepath.Join(m.cfg.DataDir, name)); ok {
+			rep.OrphanParts = append(rep.OrphanParts, name)
+			rep.BytesFreed += freed
+		}
+	}
+	for _, sha := range blobs {
+		if m.store.HasBlob(sha) {
+			continue
+		}
+		if freed, err := m.cas.Remove(sha); err == nil {
+			rep.OrphanBlobs = append(rep.OrphanBlobs, sha)
+			rep.BytesFreed += freed
+		}
+	}
+	m.rescanUsage()
+	return rep
+}
+
+// Проверяет, истек ли срок хранения задачи со статусом status из хранилища. expires_at задается
+// при создании задачи и читается без блокировки. Остальное проверяется под блокировкой и только
+// для завершенных задач: блокировку загружаемой задачи до конца загрузки держит воркер
+func (m *Manager) taskExpired(t *model.Task, status model.TaskStatus, now time.Time) bool {
+	if exp := t.Options.ExpiresAt; exp != nil && !now.Before(*exp) {
+		return true
+	}
+	if !status.IsTerminal() || m.cfg.Retention[status] <= 0 {
+		return false
+	}
+	lock := m.getTaskLock(t.ID)
+	lock.Lock()
+	defer lock.Unlock()
+	return m.isExpired(t, now)
+}
+
+// Проверяет, истек ли срок хранения задачи; вызывается под блокировкой задачи
+func (m *Manager) isExpired(t *model.Task, now time.Time) bool {
+	if exp := t.Options.ExpiresAt; exp != nil && !now.Before(*exp) {
+		return true
+	}
+	if !t.Status.IsTerminal() {
+		return false
+	}
+	ttl := m.cfg.Retention[t.Status]
+	if ttl <= 0 {
+		return false
+	}
+	return now.Sub(finishedAt(t)) >= ttl
+}
+
+// Момент завершения задачи; для задач, сохраненных до появления FinishedAt, оценивается по элементам
+func finishedAt(t *model.Task) time.Time {
+	if t.FinishedAt != nil {
+		return *t.FinishedAt
+	}
+	at := t.CreatedAt
+	for i := range t.Items {
+		if c := t.Items[i].CompletedAt; c != nil && c.After(at) {
+			at = *c
+		}
+	}
+	return at
+}
+
+// Удаляет задачу из хранилища; вызывается под блокировкой задачи
+func (m *Manager) removeTask(id model.TaskID) (*model.Task, error) {
+	t, ok := m.store.GetTask(id)
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	if err := m.store.DeleteTask(id); err != nil {
+		return nil, err
+	}
+	// Задачи, зависящие от удаленной, завершаются по своей политике
+	m.wakeScheduler()
+	return t, nil
+}
+
+// Удаляет блокировку удаленной задачи
+func (m *Manager) forgetTaskLock(id model.TaskID) {
+	m.tasksMu.Lock()
+	delete(m.taskLocks, id)
+	m.tasksMu.Unlock()
+}
+
+// Освобождает файлы удаленной задачи: ссылки на хранилище по содержимому и незавершенные загрузки,
+// а при purgeFiles - файлы в приемнике задачи, если на них не ссылаются другие задачи.
+// Возвращает удаленные файлы (локальные - относительно DATA_DIR) и освобожденный объем.
+func (m *Manager) cleanupTask(t *model.Task, purgeFiles bool) ([]string, int64) {
+	var removed []string
+	var freed int64
+	remove := func(path string) {
+		if n, ok := m.removeFile(path); ok {
+			if rel, err := filepath.Rel(m.cfg.DataDir, path); err == nil {
+				path = rel
+			}
+			removed = append(removed, path)
+			freed += n
+		}
+	}
+
+	released, err := m.store.ReleaseBlobRefs(t.ID)
+	if err != nil {
+		m.log.Error("release blobs", slog.String("task_id", string(t.ID)), slog.String("error", err.Error()))
+	}
+	for _, sha := range released {
+		remove(m.cas.Path(sha))
+	}
+	for i := range t.Items {
+		remove(m.partPath(t.ID, i))
+	}
+	m.releaseNames(t)
+	if !purgeFiles {
+		return removed, freed
+	}
+	s, err := m.sinkFor(t)
+	if err != nil {
+		m.log.Error("purge files", slog.String("task_id", string(t.ID)), slog.String("error", err.Error()))
+		return removed, freed
+	}
+	refs := m.referencedFiles(sinkName(t))
+	for i := range t.Items {
+		for _, name := range itemFiles(&t.Items[i]) {
+			if refs[name] {
+				continue
+			}
+			refs[name] = true
+			n, err := s.Remove(context.Background(), name)
+			if err != nil {
+				if !errors.Is(err, sink.ErrNotFound) {
+					m.log.Error("remove file", slog.String("task_id", string(t.ID)), slog.String("location", s.Location(name)), slog.String("error", err.Error()))
+				}
+				continue
+			}
+			removed = append(removed, s.Location(name))
+			freed += n
+		}
+	}
+	return removed, freed
+}
+
+// Множество файлов приемника, на которые ссылаются существующие задачи
+func (m *Manager) referencedFiles(sinkID string) map[string]bool {
+	refs := make(map[string]bool)
+	for _, t := range m.store.ListTasks() {
+		if sinkName(t) != sinkID {
+			continue
+		}
+		for i := range t.Items {
+			for _, name := range itemFiles(&t.Items[i]) {
+				refs[name] = true
+			}
+		}
+	}
+	return refs
+}
+
+// Список .part файлов относительно DATA_DIR: незавершенные загрузки и файлы старого формата в корне
+func (m *Manager) listPartFiles() []string {
+	var out []string
+	for _, dir := range []string{m.cfg.DataDir, m.partialDir()} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".part") {
+				rel, _ := filepath.Rel(m.cfg.DataDir, filepath.Join(dir, e.Name()))
+				out = append(out, rel)
+			}
+		}
+	}
+	return out
+}
+
+// Принадлежит ли .part файл незавершенному элементу существующей задачи
+func (m *Manager) partOwned(rel string) bool {
+	dir, name := filepath.Split(rel)
+	if filepath.Clean(dir) != filepath.Base(m.partialDir()) {
+		return false
+	}
+	base := strings.TrimSuffix(name, ".part")
+	i := strings.LastIndexByte(base, '-')
+	if i < 0 {
+		return false
+	}
+	idx, err := strconv.Atoi(base[i+1:])
+	if err != nil {
+		return false
+	}
+	t, ok := m.store.GetTask(model.TaskID(base[:i]))
+	if !ok || idx < 0 || idx >= len(t.Items) {
+		return false
+	}
+	s := t.Items[idx].Status
+	return s != model.ItemStatusDone && s != model.ItemStatusCanceled
+}
+
+// Удаляет файл и возвращает его размер; отсутствующий файл не считается удаленным
+func (m *Manager) removeFile(path string) (int64, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	if err := os.Remove(path); err != nil {
+		m.log.Error("remove file", slog.String("path", path), slog.String("error", err.Error()))
+		return 0, false
+	}
+	return fi.Size(), true
+}
@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+func TestJanitorRemovesTaskExpiredMidRetry(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxRetryPerItem: 5, BaseBackoff: 300 * time.Millisecond})
+	expires := time.Now().Add(time.Hour)
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/a"}, {URL: srv.URL + "/b"}}, model.TaskOptions{ExpiresAt: &expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "item waiting for retry", func() bool {
+		var inBackoff bool
+		withTask(m, id, func(t *model.Task) { inBackoff = t.Items[0].Status == model.ItemStatusError })
+		return inBackoff
+	})
+
+	rep := m.RunJanitor(expires.Add(time.Minute))
+	if len(rep.Tasks) != 1 || rep.Tasks[0] != id {
+		t.Fatalf("janitor removed %v, want [%s]", rep.Tasks, id)
+	}
+	before := hits.Load()
+	waitRetryBackoff(t, m)
+	if _, ok := m.GetTask(id); ok {
+		t.Fatal("expired task is back after retry backoff")
+	}
+	if n := hits.Load(); n != before {
+		t.Fatalf("expired task was downloaded again: %d requests after removal", n-before)
+	}
+}
+
+func TestJanitorWaitsForRunningItem(t *testing.T) {
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxRetryPerItem: 5, BaseBackoff: 50 * time.Millisecond})
+	expires := time.Now().Add(time.Hour)
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/big"}}, model.TaskOptions{ExpiresAt: &expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	rep := m.RunJanitor(expires.Add(time.Minute))
+	if len(rep.Tasks) != 1 {
+		t.Fatalf("janitor removed %v, want the running task", rep.Tasks)
+	}
+	waitFor(t, "aborted item to stop", func() bool { return !m.taskActive(id) })
+	if _, ok := m.GetTask(id); ok {
+		t.Fatal("expired task is back after its download was aborted")
+	}
+	if parts, _ := filepath.Glob(filepath.Join(m.partialDir(), "*.part")); len(parts) > 0 {
+		t.Fatalf("part files of removed task are left: %v", parts)
+	}
+}
+
+func TestJanitorKeepsTasksWithinRetention(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{Retention: map[model.TaskStatus]time.Duration{model.TaskStatusCompleted: time.Hour}})
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/a.txt"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+
+	if rep := m.RunJanitor(time.Now()); len(rep.Tasks) != 0 {
+		t.Fatalf("janitor removed %v before retention expired", rep.Tasks)
+	}
+	rep := m.RunJanitor(time.Now().Add(2 * time.Hour))
+	if len(rep.Tasks) != 1 || len(rep.Files) == 0 {
+		t.Fatalf("janitor report %+v, want the task and its files", rep)
+	}
+	for _, f := range rep.Files {
+		if _, err := os.Stat(filepath.Join(m.cfg.DataDir, f)); !os.IsNotExist(err) {
+			t.Fatalf("file %s is left after removal: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"log/slog"
+	"net/url"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Журнал событий элемента: задача, индекс, хост текущего адреса, номер попытки и ее длительность
+func (m *Manager) itemLog(t *model.Task, idx int, attempt int) *slog.Logger {
+	it := &t.Items[idx]
+	var elapsed time.Duration
+	if it.StartedAt != nil {
+		elapsed = time.Since(*it.StartedAt)
+	}
+	return m.log.With(
+		slog.String("task_id", string(t.ID)),
+		slog.Int("item_idx", idx),
+		slog.String("host", urlHost(it.SourceURL())),
+		slog.Int("attempt", attempt),
+		slog.Duration("duration", elapsed),
+	)
+}
+
+// Хост URL для журнала; для адресов без хоста - схема
+func urlHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if u.Host != "" {
+		return u.Host
+	}
+	return u.Scheme
+}
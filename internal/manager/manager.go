@@ -1,17 +1,31 @@
 package manager
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"taskservice/internal/cas"
+	"taskservice/internal/checksum"
+	"taskservice/internal/download"
+	"taskservice/internal/egress"
+	"taskservice/internal/mimetype"
 	"taskservice/internal/model"
+	"taskservice/internal/naming"
+	"taskservice/internal/process"
+	"taskservice/internal/sink"
 	"taskservice/internal/storage"
 	"taskservice/internal/util"
 )
@@ -24,19 +38,69 @@ type Config struct {
 	MaxRetryPerItem int
 	BaseBackoff     time.Duration
 	SnapshotEveryN  int
+	// Срок хранения задач в конечном статусе; отсутствие статуса или 0 - хранить бессрочно
+	Retention       map[model.TaskStatus]time.Duration
+	JanitorInterval time.Duration
+	// Ограничения размера: элемента, всего DATA_DIR и минимум свободного места (0 - без ограничения)
+	MaxItemBytes    int64
+	DiskQuotaBytes  int64
+	MinFreeBytes    int64
+	ResumeFreeBytes int64
+	// Каталог, из которого разрешены file:// URL; пустое значение отключает схему file
+	FileRoot string
+	// Дополнительные приемники по именам; приемник "local" в DATA_DIR создается всегда
+	Sinks map[string]sink.Sink
+	// Приемник для задач, в которых он не указан
+	DefaultSink string
+	// Команды для шагов обработки по именам, ограничение их времени, объема и числа файлов результатов шага
+	ProcessCommands   map[string][]string
+	ProcessTimeout    time.Duration
+	ProcessMaxBytes   int64
+	ProcessMaxEntries int
+	// Максимальный размер манифеста; 0 - без ограничения
+	ManifestMaxBytes int64
+	// Политика исходящих соединений загрузчиков; nil - закрыты частные и локальные адреса
+	Egress *egress.Policy
+	// Транспорт загрузчика http: таймауты, прокси, TLS и переопределения по хостам
+	Transport download.TransportConfig
+	// Перенаправления http по умолчанию: максимальное число и переход с https на http
+	MaxRedirects           int
+	AllowRedirectDowngrade bool
+	// Ограничения попытки загрузки по умолчанию: время без данных, минимальная скорость (байт/с)
+	// за окно и общее время попытки; 0 - без ограничения
+	IdleTimeout      time.Duration
+	MinThroughput    int64
+	ThroughputWindow time.Duration
+	ItemDeadline     time.Duration
+	// Журнал событий; nil - slog.Default()
+	Logger *slog.Logger
 }
 
 // Менеджер
 type Manager struct {
 	cfg        Config
 	store      *storage.Store
+	log        *slog.Logger
+	cas        *cas.Store
+	fetchers   *download.Registry
+	sinks      map[string]sink.Sink
+	processor  *process.Runner
 	tasksMu    sync.RWMutex
 	taskLocks  map[model.TaskID]*sync.Mutex
+	active     map[model.TaskID]context.CancelFunc
+	flightsMu  sync.Mutex
+	flights    map[string]*flight
+	namesMu    sync.Mutex
+	names      map[string]itemRef
+	usage      atomic.Int64 // занятое место в DATA_DIR
+	diskPaused atomic.Bool
 	queue      chan queueItem
+	schedWake  chan struct{}
+	schedMu    sync.Mutex // сериализует срабатывания и изменения расписаний
 	wg         sync.WaitGroup
 	stopOnce   sync.Once
 	stopCh     chan struct{}
-	processedN int
+	processedN atomic.Int64
 }
 
 // Элемент очереди
@@ -53,42 +117,134 @@ func NewManager(cfg Config) (*Manager, error) {
 	if cfg.WorkerCount <= 0 {
 		cfg.WorkerCount = 4
 	}
+	blobs, err := cas.New(filepath.Join(cfg.DataDir, ".cas"))
+	if err != nil {
+		return nil, err
+	}
+	sinks := map[string]sink.Sink{sink.LocalName: sink.NewLocal(cfg.DataDir, blobs)}
+	for name, s := range cfg.Sinks {
+		if name != sink.LocalName {
+			sinks[name] = s
+		}
+	}
+	if cfg.DefaultSink == "" {
+		cfg.DefaultSink = sink.LocalName
+	}
+	if _, ok := sinks[cfg.DefaultSink]; !ok {
+		return nil, fmt.Errorf("default sink %q is not configured", cfg.DefaultSink)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Egress == nil {
+		if cfg.Egress, err = egress.New(nil, nil, false); err != nil {
+			return nil, err
+		}
+	}
+	fetchers, err := newFetchers(cfg)
+	if err != nil {
+		return nil, err
+	}
 	m := &Manager{
-		cfg:       cfg,
-		store:     cfg.Store,
+		cfg:      cfg,
+		store:    cfg.Store,
+		log:      cfg.Logger,
+		cas:      blobs,
+		fetchers: fetchers,
+		sinks:    sinks,
+		processor: process.New(process.Config{Commands: cfg.ProcessCommands, Timeout: cfg.ProcessTimeout,
+			MaxBytes: cfg.ProcessMaxBytes, MaxEntries: cfg.ProcessMaxEntries}),
+		flights:   make(map[string]*flight),
+		names:     make(map[string]itemRef),
 		taskLocks: make(map[model.TaskID]*sync.Mutex),
+		active:    make(map[model.TaskID]context.CancelFunc),
 		queue:     make(chan queueItem, 1024),
+		schedWake: make(chan struct{}, 1),
 		stopCh:    make(chan struct{}),
 	}
+	m.registerMetrics()
 	return m, nil
 }
 
+// Загрузчики по схемам URL. Все воркеры используют один транспорт http с общим пулом соединений;
+// сетевые соединения проверяются политикой при каждом подключении
+func newFetchers(cfg Config) (*download.Registry, error) {
+	tc := cfg.Transport
+	if tc.ConnectTimeout <= 0 {
+		tc.ConnectTimeout = 30 * time.Second
+	}
+	if tc.MaxIdleConnsPerHost <= 0 {
+		tc.MaxIdleConnsPerHost = cfg.WorkerCount
+	}
+	transport, err := download.NewTransport(tc, cfg.Egress)
+	if err != nil {
+		return nil, fmt.Errorf("http transport: %w", err)
+	}
+	r := download.NewRegistry()
+	r.Register(download.NewHTTP(&http.Client{Transport: transport}), "http", "https")
+	r.Register(download.NewFTP(cfg.Egress.Dialer(net.Dialer{Timeout: tc.ConnectTimeout})), "ftp")
+	r.Register(download.Data{}, "data")
+	if cfg.FileRoot != "" {
+		r.Register(download.NewFile(cfg.FileRoot), "file")
+	}
+	return r, nil
+}
+
 // Запускает Manager
 func (m *Manager) Start() error {
 	// Повторная очередь незавершенных элементов после перезапуска
 	tasks := m.store.ListTasks()
+	m.indexNames(tasks)
+	// Промежуточные файлы прерванной обработки не нужны: элементы обрабатываются заново
+	_ = os.RemoveAll(m.processingDir())
+	m.rescanUsage()
+	var requeue []queueItem
+	var manifests []model.TaskID
 	for _, t := range tasks {
+		// Манифест, не разобранный до остановки или из-за ошибки, загружается заново
+		if t.Manifest != "" && len(t.Items) == 0 && t.Status != model.TaskStatusCanceled {
+			manifests = append(manifests, t.ID)
+		}
+		// Отложенные задачи остаются в статусе scheduled до времени запуска, а задачи, ожидающие
+		// зависимостей, - в статусе blocked; их проверяет планировщик. Зависимости уже запущенной задачи
+		// не проверяются заново: удаление завершенной зависимости не должно ее останавливать
+		if t.Status == model.TaskStatusCanceled || t.Status == model.TaskStatusScheduled || t.Status == model.TaskStatusBlocked {
+			continue
+		}
+		// Задача сохраняется одной записью после изменения всех ее элементов
+		if t.Status == model.TaskStatusCompleted {
+			continue
+		}
 		for idx := range t.Items {
 			it := &t.Items[idx]
 			if it.Status != model.ItemStatusDone {
-				it.Status = model.ItemStatusQueued
+				m.setItemStatus(t, idx, model.ItemStatusQueued, model.ActorRecovery)
 				it.ErrorMessage = ""
 				it.StartedAt = nil
 				it.CompletedAt = nil
-				_ = m.store.UpdateTask(t)
-				m.queue <- queueItem{taskID: t.ID, itemIdx: idx}
+				requeue = append(requeue, queueItem{taskID: t.ID, itemIdx: idx})
 			}
 		}
-		if t.Status != model.TaskStatusCompleted {
-			t.Status = model.TaskStatusPending
-			_ = m.store.UpdateTask(t)
-		}
+		m.setTaskStatus(t, model.TaskStatusPending, model.ActorRecovery)
+		_ = m.store.UpdateTask(t)
 	}
 
 	for i := 0; i < m.cfg.WorkerCount; i++ {
 		m.wg.Add(1)
 		go m.worker()
 	}
+	// Элементов может быть больше емкости очереди, поэтому они отправляются уже работающим воркерам
+	m.enqueueAll(requeue)
+	for _, id := range manifests {
+		m.wg.Add(1)
+		go m.expandManifest(id)
+	}
+	m.wg.Add(1)
+	go m.scheduler()
+	if m.cfg.JanitorInterval > 0 {
+		m.wg.Add(1)
+		go m.janitor()
+	}
 	return nil
 }
 
@@ -96,8 +252,8 @@ func (m *Manager) Start() error {
 func (m *Manager) StopAndWait(ctx context.Context) error {
 	var err error
 	m.stopOnce.Do(func() {
+		// Очередь не закрывается: отправители выбирают между очередью и stopCh
 		close(m.stopCh)
-		close(m.queue)
 		done := make(chan struct{})
 		go func() {
 			m.wg.Wait()
@@ -113,55 +269,235 @@ func (m *Manager) StopAndWait(ctx context.Context) error {
 }
 
 // Публичный API, используемый HTTP-слоем
-func (m *Manager) CreateTask(urls []string) (model.TaskID, error) {
+func (m *Manager) CreateTask(srcs []model.Source, opts model.TaskOptions) (model.TaskID, error) {
+	return m.createTask(taskSpec{sources: srcs, actor: model.ActorAPI}, opts)
+}
+
+// CreateManifestTask создает задачу, элементы которой будут получены из манифеста по URL
+func (m *Manager) CreateManifestTask(manifestURL string, opts model.TaskOptions) (model.TaskID, error) {
+	return m.createTask(taskSpec{manifest: manifestURL, actor: model.ActorAPI}, opts)
+}
+
+// Параметры создаваемой задачи помимо TaskOptions
+type taskSpec struct {
+	sources    []model.Source
+	manifest   string // URL манифеста; элементы появляются после его загрузки
+	scheduleID model.ScheduleID
+	actor      model.Actor // источник создания для истории
+}
+
+// Проверяет параметры задачи и подставляет значения по умолчанию
+func (m *Manager) validateTask(srcs []model.Source, opts *model.TaskOptions) error {
+	if err := naming.Validate(naming.Strategy(opts.Naming), opts.NamingTemplate); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if err := mimetype.ValidatePatterns(opts.AllowedMIME); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if opts.Sink == "" {
+		opts.Sink = m.cfg.DefaultSink
+	}
+	if _, ok := m.sinks[opts.Sink]; !ok {
+		return fmt.Errorf("%w: unknown sink %q", ErrInvalidTask, opts.Sink)
+	}
+	if err := m.processor.Validate(opts.Process); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if opts.NotBefore != nil && opts.ExpiresAt != nil && !opts.ExpiresAt.After(*opts.NotBefore) {
+		return fmt.Errorf("%w: expires_at must be after not_before", ErrInvalidTask)
+	}
+	if opts.MaxRedirects != nil && *opts.MaxRedirects < 0 {
+		return fmt.Errorf("%w: max_redirects must not be negative", ErrInvalidTask)
+	}
+	if err := validateStallOptions(*opts); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	for i, src := range srcs {
+		if err := m.ValidateSource(src); err != nil {
+			return fmt.Errorf("%w: urls[%d]: %v", ErrInvalidTask, i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateSource проверяет адреса и контрольную сумму одного элемента создаваемой задачи
+func (m *Manager) ValidateSource(src model.Source) error {
+	if err := m.validateURL(src.URL); err != nil {
+		return err
+	}
+	if src.Checksum != "" {
+		if _, err := checksum.Parse(src.Checksum); err != nil {
+			return err
+		}
+	}
+	for j, u := range src.Mirrors {
+		if err := m.validateURL(u); err != nil {
+			return fmt.Errorf("mirrors[%d]: %v", j, err)
+		}
+	}
+	return nil
+}
+
+// Максимальная длина URL источника; на data: URL не распространяется
+const maxURLLength = 8192
+
+// Проверяет URL при создании: длину, схему и хост. Адреса сетевых источников, заданные IP или localhost,
+// сразу проверяются политикой исходящих соединений; имена проверяются при подключении
+func (m *Manager) validateURL(raw string) error {
+	if len(raw) > maxURLLength && !strings.HasPrefix(strings.ToLower(raw), "data:") {
+		return fmt.Errorf("%w: longer than %d bytes", download.ErrInvalidURL, maxURLLength)
+	}
+	if err := m.fetchers.Validate(raw); err != nil {
+		return err
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp":
+		return m.cfg.Egress.CheckHost(u.Hostname())
+	}
+	return nil
+}
+
+// Создает задачу из списка источников или манифеста
+func (m *Manager) createTask(spec taskSpec, opts model.TaskOptions) (model.TaskID, error) {
+	if err := m.validateTask(spec.sources, &opts); err != nil {
+		return "", err
+	}
+	if spec.manifest != "" {
+		if err := m.validateURL(spec.manifest); err != nil {
+			return "", fmt.Errorf("%w: manifest: %v", ErrInvalidTask, err)
+		}
+	}
+	if err := m.checkDependencies(&opts); err != nil {
+		return "", err
+	}
 	t := &model.Task{
-		ID:        model.TaskID(util.NewID()),
-		CreatedAt: time.Now(),
-		Status:    model.TaskStatusPending,
-	}
-	t.Items = make([]model.Item, 0, len(urls))
-	for _, u := range urls {
-		t.Items = append(t.Items, model.Item{
-			URL:      u,
-			FileName: model.DeriveDeterministicFileName(u),
-			Status:   model.ItemStatusQueued,
-		})
+		ID:         model.TaskID(util.NewID()),
+		CreatedAt:  time.Now(),
+		Status:     model.TaskStatusPending,
+		Options:    opts,
+		ScheduleID: spec.scheduleID,
+		Manifest:   spec.manifest,
 	}
+	// Задача с незавершенными зависимостями или будущим временем запуска ждет планировщика
+	itemStatus := model.ItemStatusQueued
+	if state, _ := dependencyState(t, m.store.TaskStatuses()); state != depsDone {
+		t.Status, itemStatus = model.TaskStatusBlocked, model.ItemStatusBlocked
+	} else if isScheduled(t, t.CreatedAt) {
+		t.Status, itemStatus = model.TaskStatusScheduled, model.ItemStatusScheduled
+	}
+	t.Items = m.newItems(t, spec.sources, itemStatus)
+	// Переход записывается в WAL вместе с задачей
+	_ = m.store.AppendHistory(t.ID, model.Transition{At: t.CreatedAt, Actor: spec.actor, To: string(t.Status)})
 	if err := m.store.UpsertTask(t); err != nil {
 		return "", err
 	}
-	for idx := range t.Items {
-		m.queue <- queueItem{taskID: t.ID, itemIdx: idx}
+	if t.Manifest != "" {
+		m.wg.Add(1)
+		go m.expandManifest(t.ID)
 	}
+	if t.Status != model.TaskStatusPending {
+		m.wakeScheduler()
+		return t.ID, nil
+	}
+	queued := make([]queueItem, len(t.Items))
+	for idx := range queued {
+		queued[idx] = queueItem{taskID: t.ID, itemIdx: idx}
+	}
+	m.enqueueAll(queued)
 	return t.ID, nil
 }
 
+// Создает элементы задачи из источников
+func (m *Manager) newItems(t *model.Task, srcs []model.Source, status model.ItemStatus) []model.Item {
+	items := make([]model.Item, 0, len(srcs))
+	for i, src := range srcs {
+		items = append(items, model.Item{
+			URL:      src.URL,
+			Mirrors:  src.Mirrors,
+			Checksum: src.Checksum,
+			FileName: naming.Name(naming.Strategy(t.Options.Naming), t.Options.NamingTemplate, naming.Input{TaskID: string(t.ID), Index: i, URL: src.URL}),
+			Status:   status,
+		})
+	}
+	return items
+}
+
 // Список задач
 func (m *Manager) ListTasks() []*model.Task                    { return m.store.ListTasks() }
 func (m *Manager) GetTask(id model.TaskID) (*model.Task, bool) { return m.store.GetTask(id) }
 
+// История переходов задачи
+func (m *Manager) GetTaskHistory(id model.TaskID) ([]model.Transition, bool) {
+	if _, ok := m.store.GetTask(id); !ok {
+		return nil, false
+	}
+	return m.store.GetHistory(id), true
+}
+
 // Воркер
 func (m *Manager) worker() {
 	defer m.wg.Done()
-	client := &http.Client{Timeout: 0}
-	for item := range m.queue {
+	for {
+		// При нехватке места на диске новые элементы не берутся из очереди
+		if !m.waitForDiskSpace() {
+			return
+		}
+		var item queueItem
+		select {
+		case <-m.stopCh:
+			return
+		case item = <-m.queue:
+		}
 		select {
 		case <-m.stopCh:
 			return
 		default:
 		}
 		// Сериализация обработки в рамках одной задачи, чтобы избежать одновременных записей в одну структуру Task
+		activeWorkers.Add(1)
 		lock := m.getTaskLock(item.taskID)
 		lock.Lock()
-		m.processQueueItem(client, item)
+		m.processQueueItem(item)
 		lock.Unlock()
-		m.processedN++
-		if m.cfg.SnapshotEveryN > 0 && m.processedN%m.cfg.SnapshotEveryN == 0 {
+		activeWorkers.Add(-1)
+		if n := m.processedN.Add(1); m.cfg.SnapshotEveryN > 0 && n%int64(m.cfg.SnapshotEveryN) == 0 {
 			_ = m.store.SaveSnapshot()
 		}
 	}
 }
 
+// Ставит элемент в очередь; возвращает false, если менеджер остановлен
+func (m *Manager) enqueue(qi queueItem) bool {
+	select {
+	case m.queue <- qi:
+		return true
+	case <-m.stopCh:
+		return false
+	}
+}
+
+// Ставит элементы в очередь в фоне: элементов может быть больше ее емкости, а вызывающий, например
+// планировщик, не должен ждать воркеров. После остановки элементы остаются в статусе queued
+// и ставятся в очередь при следующем запуске
+func (m *Manager) enqueueAll(items []queueItem) {
+	if len(items) == 0 {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for _, qi := range items {
+			if !m.enqueue(qi) {
+				return
+			}
+		}
+	}()
+}
+
 // Получение блокировки для задачи
 func (m *Manager) getTaskLock(id model.TaskID) *sync.Mutex {
 	m.tasksMu.Lock()
@@ -175,92 +511,388 @@ func (m *Manager) getTaskLock(id model.TaskID) *sync.Mutex {
 }
 
 // Обработка элемента очереди
-func (m *Manager) processQueueItem(client *http.Client, qi queueItem) {
+func (m *Manager) processQueueItem(qi queueItem) {
 	t, ok := m.store.GetTask(qi.taskID)
-	if !ok {
+	if !ok || t.Status == model.TaskStatusCanceled {
 		return
 	}
 	if qi.itemIdx < 0 || qi.itemIdx >= len(t.Items) {
 		return
 	}
+	ctx, cancel := m.beginItem(t.ID)
+	defer m.endItem(t.ID, cancel)
 	it := &t.Items[qi.itemIdx]
-	t.Status = model.TaskStatusRunning
+	// Ошибки после отмены задачи не считаются попытками; статусы выставляет CancelTask
+	retry := func(err error) {
+		if ctx.Err() == nil {
+			m.retryOrFail(t, it, err)
+		}
+	}
+	m.setTaskStatus(t, model.TaskStatusRunning, model.ActorWorker)
 	now := time.Now()
 	it.StartedAt = &now
-	it.Status = model.ItemStatusDownloading
-	_ = m.store.UpdateTask(t)
+	it.Unchanged = false
+	m.setItemStatus(t, qi.itemIdx, model.ItemStatusDownloading, model.ActorWorker)
+	_ = m.store.UpdateItem(t, qi.itemIdx)
+	m.itemLog(t, qi.itemIdx, it.Attempts+1).Debug("item started")
 
 	// Убеждаемся, что директории существуют
-	if err := os.MkdirAll(m.cfg.DataDir, 0o755); err != nil {
+	if err := os.MkdirAll(m.partialDir(), 0o755); err != nil {
 		m.failItem(t, it, fmt.Errorf("mkdir: %w", err))
 		return
 	}
-	dstPath := filepath.Join(m.cfg.DataDir, it.FileName)
-	tmpPath := dstPath + ".part"
 
-	// Поддержка возобновления, если сервер позволяет Range
-	var startOffset int64
-	if fi, err := os.Stat(tmpPath); err == nil {
-		startOffset = fi.Size()
+	// Содержимое, уже полученное по этому URL, используется повторно; если у него есть валидаторы,
+	// источник сначала проверяет, не изменилось ли оно
+	var cached *storage.URLRecord
+	if !t.Options.ForceRefresh {
+		// Содержимое, не совпадающее с контрольной суммой элемента, загружается заново
+		if rec, ok := m.store.LookupURL(it.URL); ok && m.cas.Has(rec.SHA256) && verifyChecksum(it, m.cas.Path(rec.SHA256), rec.SHA256) == nil &&
+			m.redirectsAllowed(t, rec.RedirectChain, rec.FinalURL) {
+			if rec.HasValidators() {
+				cached = &rec
+			} else {
+				if err := m.completeItem(ctx, t, qi.itemIdx, rec.SHA256, rec.Size, recordMeta(rec), true); err != nil {
+					retry(err)
+				}
+				return
+			}
+		}
+	}
+
+	// Один URL одновременно загружает только один воркер, остальные ждут результата
+	fl, leader := m.joinFlight(it.URL)
+	for !leader {
+		select {
+		case <-fl.done:
+		case <-ctx.Done():
+			return
+		}
+		if fl.err == nil && verifyChecksum(it, m.cas.Path(fl.sha), fl.sha) == nil &&
+			m.redirectsAllowed(t, fl.meta.RedirectChain, fl.meta.FinalURL) {
+			it.Unchanged = fl.unchanged
+			if err := m.completeItem(ctx, t, qi.itemIdx, fl.sha, fl.size, fl.meta, true); err != nil {
+				retry(err)
+			}
+			return
+		}
+		// Загрузка лидера не удалась или дала другое содержимое: пробуем сами
+		fl, leader = m.joinFlight(it.URL)
+	}
+	res := flightResult{err: errFlightAborted}
+	defer func() { m.leaveFlight(it.URL, fl, res) }()
+
+	partPath := m.partPath(t.ID, qi.itemIdx)
+	meta, err := m.download(ctx, t, it, partPath, cached)
+	if err != nil {
+		res.err = err
+		retry(err)
+		return
+	}
+	if meta.NotModified {
+		rec := *cached
+		rec.FetchedAt = time.Now()
+		if meta.ETag != "" {
+			rec.ETag = meta.ETag
+		}
+		if meta.LastModified != "" {
+			rec.LastModified = meta.LastModified
+		}
+		rec.FinalURL, rec.RedirectChain = meta.FinalURL, meta.RedirectChain
+		_ = m.store.IndexURL(it.URL, rec)
+		res = flightResult{sha: rec.SHA256, size: rec.Size, meta: recordMeta(rec), unchanged: true}
+		it.Unchanged = true
+		if err := m.completeItem(ctx, t, qi.itemIdx, rec.SHA256, rec.Size, res.meta, true); err != nil {
+			retry(err)
+		}
+		return
 	}
 
-	req, err := http.NewRequest("GET", it.URL, nil)
+	// Перемещение в хранилище по содержимому; ссылка добавляется до появления файла,
+	// чтобы очистка не сочла его потерянным
+	sha, size, err := cas.HashFile(partPath)
 	if err != nil {
-		m.retryOrFail(t, it, err)
+		res.err = err
+		retry(err)
+		return
+	}
+	// Поврежденный файл удаляется, следующая попытка загружает его заново, возможно с зеркала
+	if err := verifyChecksum(it, partPath, sha); err != nil {
+		if rerr := os.Remove(partPath); rerr == nil {
+			m.usage.Add(-size)
+		}
+		res.err = err
+		retry(err)
 		return
 	}
+	if err := m.store.AddBlobRef(sha, size, storage.BlobRef{TaskID: t.ID, ItemIdx: qi.itemIdx}); err != nil {
+		res.err = err
+		retry(err)
+		return
+	}
+	existed := m.cas.Has(sha)
+	if err := m.cas.Put(partPath, sha); err != nil {
+		res.err = err
+		retry(err)
+		return
+	}
+	if existed {
+		// Такое содержимое уже было: временный файл удален
+		m.usage.Add(-size)
+	}
+	_ = m.store.IndexURL(it.URL, storage.URLRecord{
+		SHA256:          sha,
+		Size:            size,
+		FetchedAt:       time.Now(),
+		DispositionName: meta.DispositionName,
+		ContentType:     meta.ContentType,
+		SniffedType:     meta.SniffedType,
+		ETag:            meta.ETag,
+		LastModified:    meta.LastModified,
+		FinalURL:        meta.FinalURL,
+		RedirectChain:   meta.RedirectChain,
+	})
+	res = flightResult{sha: sha, size: size, meta: meta}
+
+	if err := m.completeItem(ctx, t, qi.itemIdx, sha, size, meta, false); err != nil {
+		retry(err)
+	}
+}
+
+// Метаданные ответа, полученные при загрузке
+type fetchMeta struct {
+	DispositionName string // имя файла из Content-Disposition
+	ContentType     string // тип из заголовка Content-Type
+	SniffedType     string // тип, определенный по первым байтам
+	ETag            string // валидаторы для условных запросов
+	LastModified    string
+	NotModified     bool   // содержимое не изменилось, файл не загружался
+	FinalURL        string // адрес ответа после перенаправлений
+	RedirectChain   []model.Redirect
+}
+
+// Метаданные ранее полученного содержимого из индекса URL
+func recordMeta(rec storage.URLRecord) fetchMeta {
+	return fetchMeta{
+		DispositionName: rec.DispositionName,
+		ContentType:     rec.ContentType,
+		SniffedType:     rec.SniffedType,
+		FinalURL:        rec.FinalURL,
+		RedirectChain:   rec.RedirectChain,
+	}
+}
+
+// Загружает содержимое элемента во временный файл, продолжая с места остановки.
+// Для cached источнику передаются валидаторы, и при неизменном содержимом возвращается meta.NotModified
+func (m *Manager) download(ctx context.Context, t *model.Task, it *model.Item, partPath string, cached *storage.URLRecord) (meta fetchMeta, err error) {
+	// Загрузка продолжается с конца частичного файла, если источник это поддерживает
+	var startOffset, partBytes int64
+	if fi, err := os.Stat(partPath); err == nil {
+		startOffset, partBytes = fi.Size(), fi.Size()
+	}
+	// Часть, полученная с другого адреса, продолжается, только если источник подтвердит ее валидатор
+	src := it.SourceURL()
+	if startOffset > 0 && it.ServedBy != "" && it.ServedBy != src && it.Validator == "" {
+		startOffset = 0
+	}
+
+	defer func(start time.Time) {
+		result := "ok"
+		switch {
+		case err != nil:
+			result = "error"
+		case meta.NotModified:
+			result = "not_modified"
+		}
+		downloadSeconds.Observe(time.Since(start).Seconds(), result)
+	}(time.Now())
+
+	// Попытка прерывается, если передача остановилась или идет слишком медленно
+	watch := watchStall(ctx, m.stallLimits(t))
+	defer watch.stop()
+	ctx = watch.ctx
+
+	redirects := m.redirectPolicy(t)
+	req := download.Request{Offset: startOffset, Redirects: &redirects, Progress: func(read int64) {
+		it.SizeDownloaded = startOffset + read
+		watch.progress(read)
+	}}
 	if startOffset > 0 {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startOffset))
+		req.IfRange = it.Validator
+	}
+	if cached != nil {
+		req.ETag, req.LastModified = cached.ETag, cached.LastModified
 	}
-	resp, err := client.Do(req)
+	resp, err := m.fetchers.Fetch(ctx, src, req)
 	if err != nil {
-		m.retryOrFail(t, it, err)
-		return
+		if serr := watch.err(); serr != nil {
+			return meta, serr
+		}
+		if errors.Is(err, download.ErrInvalidURL) || errors.Is(err, download.ErrUnsupportedScheme) ||
+			errors.Is(err, egress.ErrBlocked) || errors.Is(err, download.ErrRedirect) {
+			return meta, permanent(err)
+		}
+		return meta, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		m.retryOrFail(t, it, fmt.Errorf("bad status: %s", resp.Status))
-		return
+	meta.ETag, meta.LastModified = resp.ETag, resp.LastModified
+	meta.FinalURL, meta.RedirectChain = resp.FinalURL, redirectChain(resp.Redirects)
+	if resp.NotModified {
+		meta.NotModified = true
+		return meta, nil
+	}
+	// Источник начал передачу с другого места, например отдает файл целиком
+	startOffset = resp.Offset
+	it.ServedBy = src
+	if startOffset == 0 {
+		it.Validator = rangeValidator(resp)
 	}
+	_ = m.store.UpdateItem(t, indexOfItem(t, it))
 
-	// Ожидаемый размер, если предоставлен
-	if resp.ContentLength > 0 {
-		it.SizeExpected = startOffset + resp.ContentLength
+	// Ожидаемый размер, если предоставлен; превышение ограничений и квоты видно до чтения тела ответа
+	limit := m.itemSizeLimit(t, indexOfItem(t, it))
+	if resp.Size >= 0 {
+		it.SizeExpected = resp.Size
+		if err := m.checkExpectedSize(limit, resp.Size, partBytes); err != nil {
+			if rerr := os.Remove(partPath); rerr == nil {
+				m.usage.Add(-partBytes)
+			}
+			return meta, err
+		}
+	}
+
+	meta.DispositionName = resp.DispositionName
+
+	// Тип содержимого определяется по первым байтам: при продолжении - из уже загруженной части
+	body := bufio.NewReaderSize(resp.Body, mimetype.SniffLen)
+	var head []byte
+	if startOffset > 0 {
+		head = readHead(partPath, mimetype.SniffLen)
+	} else {
+		head, _ = body.Peek(mimetype.SniffLen)
+	}
+	meta.ContentType = mimetype.Normalize(resp.ContentType)
+	meta.SniffedType = mimetype.Sniff(head)
+	it.ContentType, it.SniffedType = meta.ContentType, meta.SniffedType
+	if err := checkMIME(t, meta); err != nil {
+		_ = os.Remove(partPath)
+		return meta, err
 	}
 
 	// Открываем файл для добавления
-	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
-		m.retryOrFail(t, it, err)
-		return
+		return meta, err
+	}
+	if err := f.Truncate(startOffset); err != nil {
+		f.Close()
+		return meta, err
 	}
+	m.usage.Add(startOffset - partBytes)
 	if _, err := f.Seek(startOffset, 0); err != nil {
 		f.Close()
-		m.retryOrFail(t, it, err)
-		return
+		return meta, err
 	}
 
-	written, err := io.Copy(f, resp.Body)
+	written, err := io.Copy(&limitWriter{m: m, w: f, limit: limit, written: startOffset}, body)
 	if cerr := f.Close(); cerr != nil && err == nil {
 		err = cerr
 	}
 	it.SizeDownloaded = startOffset + written
+	downloadedBytes.Add(float64(written))
+	if serr := watch.err(); serr != nil && err != nil {
+		// Полученная часть остается, следующая попытка продолжит с нее
+		return meta, serr
+	}
+	if isPermanent(err) {
+		if rerr := os.Remove(partPath); rerr == nil {
+			m.usage.Add(-it.SizeDownloaded)
+		}
+	}
+	return meta, err
+}
+
+// Валидатор, по которому можно продолжить загрузку через If-Range: строгий ETag или Last-Modified
+func rangeValidator(resp *download.Response) string {
+	if resp.ETag != "" && !strings.HasPrefix(resp.ETag, "W/") {
+		return resp.ETag
+	}
+	return resp.LastModified
+}
+
+// Проверяет файл по контрольной сумме элемента; sha - уже известный sha256 файла
+func verifyChecksum(it *model.Item, path, sha string) error {
+	if it.Checksum == "" {
+		return nil
+	}
+	sum, err := checksum.Parse(it.Checksum)
 	if err != nil {
-		m.retryOrFail(t, it, err)
-		return
+		return permanent(err)
 	}
+	return sum.Verify(path, sha)
+}
 
-	// Атомарная переименование в окончательное имя
-	if err := os.Rename(tmpPath, dstPath); err != nil {
-		m.retryOrFail(t, it, err)
-		return
+// Проверяет тип содержимого по списку разрешенных типов задачи
+func checkMIME(t *model.Task, meta fetchMeta) error {
+	if len(t.Options.AllowedMIME) == 0 || mimetype.Allowed(t.Options.AllowedMIME, meta.ContentType, meta.SniffedType) {
+		return nil
+	}
+	return permanent(fmt.Errorf("content type %q (sniffed %q) is not allowed", meta.ContentType, meta.SniffedType))
+}
+
+// Читает первые n байт файла
+func readHead(path string, n int) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	k, _ := io.ReadFull(f, buf)
+	return buf[:k]
+}
+
+// Завершает элемент: записывает файл из хранилища по содержимому в приемник задачи под именем элемента
+func (m *Manager) completeItem(ctx context.Context, t *model.Task, idx int, sha string, size int64, meta fetchMeta, reused bool) error {
+	it := &t.Items[idx]
+	if err := checkMIME(t, meta); err != nil {
+		return err
+	}
+	if limit := m.itemSizeLimit(t, idx); limit.exceeded(size) {
+		return limit.err()
+	}
+	it.ContentType, it.SniffedType = meta.ContentType, meta.SniffedType
+	it.FinalURL, it.RedirectChain = meta.FinalURL, meta.RedirectChain
+	if err := m.store.AddBlobRef(sha, size, storage.BlobRef{TaskID: t.ID, ItemIdx: idx}); err != nil {
+		return err
+	}
+	if err := m.linkItemFile(ctx, t, idx, sha, size, meta); err != nil {
+		return err
+	}
+	it.SHA256 = sha
+	it.Reused = reused
+	it.Validator = ""
+	it.SizeDownloaded = size
+	if reused {
+		it.SizeExpected = size
+	}
+
+	// Шаги обработки; ошибка шага не повторяется, результаты уже записаны в элемент
+	if len(t.Options.Process) > 0 {
+		m.setItemStatus(t, idx, model.ItemStatusProcessing, model.ActorWorker)
+		_ = m.store.UpdateItem(t, idx)
+		if err := m.processItem(ctx, t, idx); err != nil {
+			return permanent(err)
+		}
 	}
 
 	done := time.Now()
 	it.CompletedAt = &done
-	it.Status = model.ItemStatusDone
 	it.ErrorMessage = ""
-	_ = m.store.UpdateTask(t)
+	m.setItemStatus(t, idx, model.ItemStatusDone, model.ActorWorker)
+	_ = m.store.UpdateItem(t, idx)
+	m.itemLog(t, idx, it.Attempts+1).Info("item completed",
+		slog.Int64("size", size), slog.Bool("reused", reused), slog.Bool("unchanged", it.Unchanged))
 
 	// Если все элементы завершены -> задача завершена
 	allDone := true
@@ -271,26 +903,45 @@ func (m *Manager) processQueueItem(client *http.Client, qi queueItem) {
 		}
 	}
 	if allDone {
-		t.Status = model.TaskStatusCompleted
-		_ = m.store.UpdateTask(t)
+		m.setTaskStatus(t, model.TaskStatusCompleted, model.ActorWorker)
+		_ = m.store.UpdateItem(t, idx)
 	}
+	return nil
+}
+
+// Директория незавершенных загрузок
+func (m *Manager) partialDir() string { return filepath.Join(m.cfg.DataDir, ".partial") }
+
+// Путь к временному файлу элемента; у каждого элемента свой файл, поэтому воркеры не пишут в один .part
+func (m *Manager) partPath(id model.TaskID, idx int) string {
+	return filepath.Join(m.partialDir(), fmt.Sprintf("%s-%d.part", id, idx))
 }
 
 // Повторная попытка или сбой
 func (m *Manager) retryOrFail(t *model.Task, it *model.Item, cause error) {
 	it.Attempts++
 	it.ErrorMessage = cause.Error()
-	it.Status = model.ItemStatusError
-	_ = m.store.UpdateTask(t)
-	if it.Attempts <= m.cfg.MaxRetryPerItem {
-		backoff := m.cfg.BaseBackoff * time.Duration(it.Attempts)
-		time.AfterFunc(backoff, func() {
-			it.Status = model.ItemStatusQueued
-			_ = m.store.UpdateTask(t)
-			m.queue <- queueItem{taskID: t.ID, itemIdx: indexOfItem(t, it)}
-		})
+	// Каждому адресу элемента достается MaxRetryPerItem+1 попыток; после сбоя используется следующий адрес
+	sources := 1 + len(it.Mirrors)
+	retry := it.Attempts < (m.cfg.MaxRetryPerItem+1)*sources && !isPermanent(cause)
+	if retry && sources > 1 {
+		it.Source = (it.Source + 1) % sources
+	}
+	m.setItemStatus(t, indexOfItem(t, it), model.ItemStatusError, model.ActorWorker)
+	_ = m.store.UpdateItem(t, indexOfItem(t, it))
+	if retry {
+		retriesTotal.Inc(errorClass(cause))
+		backoff := m.cfg.BaseBackoff * time.Duration((it.Attempts-1)/sources+1)
+		m.itemLog(t, indexOfItem(t, it), it.Attempts).Warn("item retry scheduled",
+			slog.String("error", cause.Error()), slog.String("class", errorClass(cause)),
+			slog.Duration("backoff", backoff), slog.String("next_host", urlHost(it.SourceURL())))
+		id, idx := t.ID, indexOfItem(t, it)
+		time.AfterFunc(backoff, func() { m.requeueItem(id, idx) })
 		return
 	}
+	itemFailuresTotal.Inc(errorClass(cause))
+	m.itemLog(t, indexOfItem(t, it), it.Attempts).Error("item failed",
+		slog.String("error", cause.Error()), slog.String("class", errorClass(cause)))
 	// Если любой элемент завершился с ошибкой после повторных попыток, отметить задачу как неудачную, когда нет запущенных или в очереди элементов
 	anyPending := false
 	for i := range t.Items {
@@ -301,17 +952,81 @@ func (m *Manager) retryOrFail(t *model.Task, it *model.Item, cause error) {
 		}
 	}
 	if !anyPending {
-		t.Status = model.TaskStatusFailed
-		_ = m.store.UpdateTask(t)
+		m.setTaskStatus(t, model.TaskStatusFailed, model.ActorWorker)
+		_ = m.store.UpdateItem(t, indexOfItem(t, it))
+	}
+}
+
+// Возвращает элемент в очередь после паузы перед повтором. Задача ищется заново под ее блокировкой:
+// за время паузы она могла быть отменена или удалена, и тогда элемент в очередь не ставится
+func (m *Manager) requeueItem(id model.TaskID, idx int) {
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	t, ok := m.store.GetTask(id)
+	if !ok {
+		lock.Unlock()
+		m.forgetTaskLock(id)
+		return
+	}
+	if t.Status == model.TaskStatusCanceled || idx < 0 || idx >= len(t.Items) || t.Items[idx].Status != model.ItemStatusError {
+		lock.Unlock()
+		return
+	}
+	m.setItemStatus(t, idx, model.ItemStatusQueued, model.ActorWorker)
+	err := m.store.UpdateItem(t, idx)
+	lock.Unlock()
+	// Очередь может быть заполнена, поэтому элемент ставится в нее без блокировки задачи
+	if err == nil {
+		m.enqueue(queueItem{taskID: id, itemIdx: idx})
 	}
 }
 
 // Сбой элемента
 func (m *Manager) failItem(t *model.Task, it *model.Item, cause error) {
+	itemFailuresTotal.Inc(errorClass(cause))
 	it.Attempts++
 	it.ErrorMessage = cause.Error()
-	it.Status = model.ItemStatusError
-	_ = m.store.UpdateTask(t)
+	m.itemLog(t, indexOfItem(t, it), it.Attempts).Error("item failed",
+		slog.String("error", cause.Error()), slog.String("class", errorClass(cause)))
+	m.setItemStatus(t, indexOfItem(t, it), model.ItemStatusError, model.ActorWorker)
+	_ = m.store.UpdateItem(t, indexOfItem(t, it))
+}
+
+// Меняет статус задачи и записывает переход в историю
+func (m *Manager) setTaskStatus(t *model.Task, s model.TaskStatus, actor model.Actor) {
+	if t.Status == s {
+		return
+	}
+	tr := model.Transition{At: time.Now(), Actor: actor, From: string(t.Status), To: string(s)}
+	t.Status = s
+	// Переход записывается до пробуждения планировщика: он видит статусы задач через хранилище
+	_ = m.store.AppendHistory(t.ID, tr)
+	if s.IsTerminal() {
+		t.FinishedAt = &tr.At
+		m.log.Info("task finished", slog.String("task_id", string(t.ID)), slog.String("status", string(s)),
+			slog.Int("items", len(t.Items)), slog.Duration("duration", tr.At.Sub(t.CreatedAt)))
+		// Завершение может разблокировать зависимые задачи
+		m.wakeScheduler()
+	} else {
+		t.FinishedAt = nil
+	}
+}
+
+// Меняет статус элемента и записывает переход в историю вместе с номером попытки и ошибкой
+func (m *Manager) setItemStatus(t *model.Task, idx int, s model.ItemStatus, actor model.Actor) {
+	if idx < 0 || idx >= len(t.Items) {
+		return
+	}
+	it := &t.Items[idx]
+	if it.Status == s {
+		return
+	}
+	tr := model.Transition{At: time.Now(), Actor: actor, ItemIdx: &idx, From: string(it.Status), To: string(s), Attempt: it.Attempts}
+	if s == model.ItemStatusError {
+		tr.Error = it.ErrorMessage
+	}
+	it.Status = s
+	_ = m.store.AppendHistory(t.ID, tr)
 }
 
 // Индекс элемента
@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"taskservice/internal/download"
+	"taskservice/internal/egress"
+	"taskservice/internal/manifest"
+	"taskservice/internal/model"
+)
+
+// Манифест больше MANIFEST_MAX_BYTES
+var errManifestTooLarge = errors.New("manifest is too large")
+
+// Загружает манифест задачи и создает из него элементы.
+// Сбой загрузки повторяется как у элемента; ошибка разбора или проверки завершает задачу статусом failed
+func (m *Manager) expandManifest(id model.TaskID) {
+	defer m.wg.Done()
+	t, ok := m.store.GetTask(id)
+	if !ok {
+		return
+	}
+	var (
+		srcs []model.Source
+		err  error
+	)
+	for attempt := 1; ; attempt++ {
+		srcs, err = m.fetchManifest(id, t.Manifest, m.redirectPolicy(t))
+		if err == nil || isPermanent(err) || attempt > m.cfg.MaxRetryPerItem {
+			break
+		}
+		select {
+		case <-time.After(m.cfg.BaseBackoff * time.Duration(attempt)):
+		case <-m.stopCh:
+			return
+		}
+	}
+	if err == nil {
+		if verr := m.validateTask(srcs, &t.Options); verr != nil {
+			err = verr
+		}
+	}
+
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	t, ok = m.store.GetTask(id)
+	// Задача могла быть отменена или удалена во время загрузки манифеста
+	if !ok || t.Status == model.TaskStatusCanceled || len(t.Items) > 0 {
+		lock.Unlock()
+		return
+	}
+	if err != nil {
+		select {
+		case <-m.stopCh:
+			// Загрузка прервана остановкой сервиса и повторится при запуске
+			lock.Unlock()
+			return
+		default:
+		}
+		t.Error = fmt.Sprintf("manifest: %v", err)
+		m.setTaskStatus(t, model.TaskStatusFailed, model.ActorWorker)
+		_ = m.store.UpdateTask(t)
+		lock.Unlock()
+		m.log.Error("manifest failed", slog.String("task_id", string(id)), slog.String("host", urlHost(t.Manifest)), slog.String("error", t.Error))
+		return
+	}
+	// Элементы получают статус задачи: она могла ждать зависимостей или времени запуска
+	status := model.ItemStatusQueued
+	switch t.Status {
+	case model.TaskStatusBlocked:
+		status = model.ItemStatusBlocked
+	case model.TaskStatusScheduled:
+		status = model.ItemStatusScheduled
+	default:
+		m.setTaskStatus(t, model.TaskStatusPending, model.ActorWorker)
+	}
+	t.Error = ""
+	t.Items = m.newItems(t, srcs, status)
+	_ = m.store.UpdateTask(t)
+	lock.Unlock()
+	if status != model.ItemStatusQueued {
+		return
+	}
+	for idx := range t.Items {
+		if !m.enqueue(queueItem{taskID: id, itemIdx: idx}) {
+			return
+		}
+	}
+}
+
+// Загружает и разбирает манифест; отмена задачи прерывает загрузку
+func (m *Manager) fetchManifest(id model.TaskID, rawURL string, redirects download.RedirectPolicy) ([]model.Source, error) {
+	ctx, cancel := m.beginItem(id)
+	defer m.endItem(id, cancel)
+	go func() {
+		select {
+		case <-m.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	resp, err := m.fetchers.Fetch(ctx, rawURL, download.Request{Redirects: &redirects})
+	if err != nil {
+		if errors.Is(err, download.ErrInvalidURL) || errors.Is(err, download.ErrUnsupportedScheme) ||
+			errors.Is(err, egress.ErrBlocked) || errors.Is(err, download.ErrRedirect) {
+			return nil, permanent(err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body := io.Reader(resp.Body)
+	if max := m.cfg.ManifestMaxBytes; max > 0 {
+		if resp.Size > max {
+			return nil, permanent(errManifestTooLarge)
+		}
+		body = io.LimitReader(body, max+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if max := m.cfg.ManifestMaxBytes; max > 0 && int64(len(data)) > max {
+		return nil, permanent(errManifestTooLarge)
+	}
+	srcs, err := manifest.Parse(data)
+	if err != nil {
+		return nil, permanent(err)
+	}
+	return srcs, nil
+}
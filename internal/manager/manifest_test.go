@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+func TestManifestTask(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list.json":
+			fmt.Fprintf(w, `["%s/a.txt", {"url": "%s/b.txt"}]`, srv.URL, srv.URL)
+		case "/big.txt":
+			w.Write([]byte(strings.Repeat(srv.URL+"/x.txt\n", 100)))
+		default:
+			w.Write([]byte("file " + r.URL.Path))
+		}
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{ManifestMaxBytes: 1024})
+	id, err := m.CreateManifestTask(srv.URL+"/list.json", model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+	withTask(m, id, func(task *model.Task) {
+		if len(task.Items) != 2 || task.Items[0].URL != srv.URL+"/a.txt" || task.Items[1].URL != srv.URL+"/b.txt" {
+			t.Fatalf("items %+v, want a.txt and b.txt from the manifest", task.Items)
+		}
+	})
+
+	// Манифест больше ManifestMaxBytes не разбирается
+	id, err = m.CreateManifestTask(srv.URL+"/big.txt", model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task failure", func() bool { return taskStatus(m, id) == model.TaskStatusFailed })
+	withTask(m, id, func(task *model.Task) {
+		if len(task.Items) != 0 || !strings.HasPrefix(task.Error, "manifest:") {
+			t.Fatalf("oversized manifest: items %d, error %q", len(task.Items), task.Error)
+		}
+	})
+}
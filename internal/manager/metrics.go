@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"taskservice/internal/checksum"
+	"taskservice/internal/download"
+	"taskservice/internal/egress"
+	"taskservice/internal/metrics"
+)
+
+// Метрики загрузок
+var (
+	activeWorkers = metrics.NewGauge("taskservice_active_workers",
+		"Workers currently processing a queued item.")
+	downloadedBytes = metrics.NewCounter("taskservice_downloaded_bytes_total",
+		"Bytes received from sources and written to part files.")
+	downloadSeconds = metrics.NewHistogram("taskservice_download_duration_seconds",
+		"Duration of item download attempts by result (ok, not_modified, error).",
+		metrics.ExponentialBuckets(0.05, 4, 9), "result")
+	retriesTotal = metrics.NewCounter("taskservice_retries_total",
+		"Item download attempts scheduled for retry by error class.", "class")
+	itemFailuresTotal = metrics.NewCounter("taskservice_item_failures_total",
+		"Items failed without further retries by error class.", "class")
+)
+
+// Регистрирует метрики, вычисляемые по состоянию менеджера при каждом запросе
+func (m *Manager) registerMetrics() {
+	metrics.NewGaugeFunc("taskservice_queue_depth", "Items waiting in the worker queue.", func() float64 {
+		return float64(len(m.queue))
+	})
+	metrics.NewGaugeVecFunc("taskservice_items", "Task items by status.", "status", func() map[string]float64 {
+		out := make(map[string]float64)
+		for _, t := range m.store.ListTasks() {
+			for i := range t.Items {
+				out[string(t.Items[i].Status)]++
+			}
+		}
+		return out
+	})
+	metrics.NewGaugeVecFunc("taskservice_tasks", "Tasks by status.", "status", func() map[string]float64 {
+		out := make(map[string]float64)
+		for _, t := range m.store.ListTasks() {
+			out[string(t.Status)]++
+		}
+		return out
+	})
+}
+
+// Класс ошибки загрузки для метрик
+func errorClass(err error) string {
+	var status *download.StatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errStalled):
+		return "stalled"
+	case errors.As(err, &status) && status.Code >= 500:
+		return "http_5xx"
+	case errors.As(err, &status):
+		return "http_4xx"
+	case errors.Is(err, checksum.ErrMismatch):
+		return "checksum"
+	case errors.Is(err, egress.ErrBlocked):
+		return "egress"
+	case errors.Is(err, download.ErrRedirect):
+		return "redirect"
+	case errors.Is(err, download.ErrInvalidURL), errors.Is(err, download.ErrUnsupportedScheme):
+		return "invalid_url"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	case isPermanent(err):
+		return "rejected"
+	}
+	return "other"
+}
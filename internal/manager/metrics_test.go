@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"taskservice/internal/checksum"
+	"taskservice/internal/download"
+	"taskservice/internal/egress"
+	"taskservice/internal/metrics"
+	"taskservice/internal/model"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: no data for 1m", errStalled), "stalled"},
+		{&download.StatusError{Code: 503, Status: "503 Service Unavailable"}, "http_5xx"},
+		{permanent(&download.StatusError{Code: 404, Status: "404 Not Found"}), "http_4xx"},
+		{permanent(fmt.Errorf("verify: %w", checksum.ErrMismatch)), "checksum"},
+		{fmt.Errorf("dial: %w", egress.ErrBlocked), "egress"},
+		{fmt.Errorf("%w: too many", download.ErrRedirect), "redirect"},
+		{download.ErrUnsupportedScheme, "invalid_url"},
+		{context.DeadlineExceeded, "timeout"},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, "timeout"},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset")}, "network"},
+		{permanent(errors.New("item size limit exceeded")), "rejected"},
+		{errors.New("disk full"), "other"},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("errorClass(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsReflectDownloads(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+	m := newTestManager(t, Config{MaxRetryPerItem: 1, BaseBackoff: 10 * time.Millisecond})
+	retriesBefore := scrape(t, `taskservice_retries_total{class="http_4xx"}`)
+	failuresBefore := scrape(t, `taskservice_item_failures_total{class="http_4xx"}`)
+
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/ok"}, {URL: srv.URL + "/missing"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task failure", func() bool { return taskStatus(m, id) == model.TaskStatusFailed })
+
+	if got := scrape(t, `taskservice_retries_total{class="http_4xx"}`); got != retriesBefore+1 {
+		t.Fatalf("http_4xx retries %v, want %v", got, retriesBefore+1)
+	}
+	if got := scrape(t, `taskservice_item_failures_total{class="http_4xx"}`); got != failuresBefore+1 {
+		t.Fatalf("http_4xx failures %v, want %v", got, failuresBefore+1)
+	}
+	if got := scrape(t, `taskservice_tasks{status="failed"}`); got != 1 {
+		t.Fatalf("failed tasks gauge %v, want 1", got)
+	}
+	if got := scrape(t, `taskservice_items{status="done"}`); got != 1 {
+		t.Fatalf("done items gauge %v, want 1", got)
+	}
+}
+
+// Значение серии в выводе метрик; отсутствующая серия - 0
+func scrape(t *testing.T, series string) float64 {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := metrics.Default.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, series+" "); ok {
+			var f float64
+			if _, err := fmt.Sscan(v, &f); err != nil {
+				t.Fatalf("series %s: %v", series, err)
+			}
+			return f
+		}
+	}
+	return 0
+}
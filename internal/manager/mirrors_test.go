@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+func TestMirrorFailover(t *testing.T) {
+	var primaryHits atomic.Int64
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from mirror"))
+	}))
+	defer mirror.Close()
+
+	m := newTestManager(t, Config{BaseBackoff: 10 * time.Millisecond})
+	src := model.Source{URL: primary.URL + "/f.txt", Mirrors: []string{mirror.URL + "/f.txt"}}
+	id, err := m.CreateTask([]model.Source{src}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+	withTask(m, id, func(task *model.Task) {
+		it := task.Items[0]
+		if it.ServedBy != src.Mirrors[0] || it.Attempts != 1 {
+			t.Fatalf("item served by %q after %d failed attempts, want mirror after 1", it.ServedBy, it.Attempts)
+		}
+	})
+	if n := primaryHits.Load(); n != 1 {
+		t.Fatalf("primary requested %d times, want 1", n)
+	}
+}
+
+func TestMirrorsAreValidated(t *testing.T) {
+	m := newTestManager(t, Config{})
+	_, err := m.CreateTask([]model.Source{{URL: "https://example.com/f", Mirrors: []string{"gopher://example.com/f"}}}, model.TaskOptions{})
+	if err == nil || !strings.Contains(err.Error(), "mirrors[0]") {
+		t.Fatalf("CreateTask with invalid mirror: %v", err)
+	}
+}
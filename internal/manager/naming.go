@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"taskservice/internal/mimetype"
+	"taskservice/internal/model"
+	"taskservice/internal/naming"
+	"taskservice/internal/sink"
+)
+
+// Максимальное число попыток подобрать свободное имя файла
+const maxNameSuffix = 10000
+
+// Элемент задачи, которому принадлежит имя файла
+type itemRef struct {
+	taskID model.TaskID
+	idx    int
+}
+
+// Использует ли задача стратегию хешированных имен, общих для одинаковых URL
+func hashedNaming(t *model.Task) bool {
+	s := naming.Strategy(t.Options.Naming)
+	return s == "" || s == naming.Hashed
+}
+
+// Строит индекс занятых имен по завершенным элементам сохраненных задач
+func (m *Manager) indexNames(tasks []*model.Task) {
+	m.namesMu.Lock()
+	defer m.namesMu.Unlock()
+	for _, t := range tasks {
+		if hashedNaming(t) {
+			continue
+		}
+		for i := range t.Items {
+			if t.Items[i].Status == model.ItemStatusDone {
+				for _, name := range itemFiles(&t.Items[i]) {
+					m.names[name] = itemRef{taskID: t.ID, idx: i}
+				}
+			}
+		}
+	}
+}
+
+// Освобождает имена файлов удаленной задачи
+func (m *Manager) releaseNames(t *model.Task) {
+	m.namesMu.Lock()
+	defer m.namesMu.Unlock()
+	for i := range t.Items {
+		for _, name := range itemFiles(&t.Items[i]) {
+			if owner, ok := m.names[name]; ok && owner.taskID == t.ID {
+				delete(m.names, name)
+			}
+		}
+	}
+}
+
+// Записывает файл элемента в приемник задачи по стратегии именования задачи.
+// Для хешированных имен файл общий для всех задач с тем же URL; для остальных стратегий
+// при конфликте к имени добавляется суффикс -N, а чужие файлы не перезаписываются.
+func (m *Manager) linkItemFile(ctx context.Context, t *model.Task, idx int, sha string, size int64, meta fetchMeta) error {
+	it := &t.Items[idx]
+	s, err := m.sinkFor(t)
+	if err != nil {
+		return err
+	}
+	contentType := mimetype.Effective(meta.ContentType, meta.SniffedType)
+	put := func(name string) error {
+		if err := s.Put(ctx, sink.Object{Key: name, SHA256: sha, Size: size, ContentType: contentType, Path: m.cas.Path(sha)}); err != nil {
+			return err
+		}
+		it.FileName = name
+		if loc := s.Location(name); loc != name {
+			it.Location = loc
+		}
+		return nil
+	}
+	if hashedNaming(t) {
+		return put(naming.Name(naming.Hashed, "", naming.Input{URL: it.URL, ContentType: contentType}))
+	}
+
+	self := itemRef{taskID: t.ID, idx: idx}
+	candidate := naming.Name(naming.Strategy(t.Options.Naming), t.Options.NamingTemplate, naming.Input{
+		TaskID:          string(t.ID),
+		Index:           idx,
+		URL:             it.URL,
+		DispositionName: meta.DispositionName,
+		ContentType:     contentType,
+	})
+
+	// Имя резервируется под блокировкой, а Stat и Put идут без нее: для внешних приемников
+	// это сетевые вызовы, и остальные загрузки не должны их ждать
+	for n := 0; n < maxNameSuffix; n++ {
+		name := naming.WithSuffix(candidate, n)
+		owned, ok := m.reserveName(name, self)
+		if !ok {
+			continue
+		}
+		exists, same, err := s.Stat(ctx, name, sha)
+		if err == nil && exists && !owned && !same {
+			// Файл, не принадлежащий ни одному элементу, не перезаписываем
+			m.releaseName(name, self)
+			continue
+		}
+		prev := it.FileName
+		if err == nil {
+			err = put(name)
+		}
+		if err != nil {
+			if !owned {
+				m.releaseName(name, self)
+			}
+			return err
+		}
+		if prev != name {
+			m.releaseName(prev, self)
+		}
+		return nil
+	}
+	return fmt.Errorf("no free file name for %q", candidate)
+}
+
+// Закрепляет имя за элементом, если оно свободно или уже принадлежит ему.
+// owned сообщает, что имя принадлежало элементу до вызова
+func (m *Manager) reserveName(name string, self itemRef) (owned, ok bool) {
+	m.namesMu.Lock()
+	defer m.namesMu.Unlock()
+	if owner, exists := m.names[name]; exists {
+		return owner == self, owner == self
+	}
+	m.names[name] = self
+	return false, true
+}
+
+// Освобождает имя, если оно закреплено за элементом
+func (m *Manager) releaseName(name string, self itemRef) {
+	m.namesMu.Lock()
+	defer m.namesMu.Unlock()
+	if owner, ok := m.names[name]; ok && owner == self {
+		delete(m.names, name)
+	}
+}
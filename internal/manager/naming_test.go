@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+	"taskservice/internal/sink"
+)
+
+func TestNameCollisionsGetSuffix(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{})
+	// Файл, не принадлежащий ни одной задаче, не перезаписывается
+	if err := os.MkdirAll(m.cfg.DataDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(m.cfg.DataDir, "report.txt"), []byte("foreign"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := model.TaskOptions{Naming: "basename"}
+	var names []string
+	for _, p := range []string{"/a/report.txt", "/b/report.txt"} {
+		id, err := m.CreateTask([]model.Source{{URL: srv.URL + p}}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+		withTask(m, id, func(t *model.Task) { names = append(names, t.Items[0].FileName) })
+	}
+	if names[0] != "report-1.txt" || names[1] != "report-2.txt" {
+		t.Fatalf("file names %v, want report-1.txt, report-2.txt", names)
+	}
+	for name, want := range map[string]string{
+		"report.txt":   "foreign",
+		"report-1.txt": "content of /a/report.txt",
+		"report-2.txt": "content of /b/report.txt",
+	} {
+		if b, err := os.ReadFile(filepath.Join(m.cfg.DataDir, name)); err != nil || string(b) != want {
+			t.Fatalf("%s = %q, %v; want %q", name, b, err, want)
+		}
+	}
+}
+
+// Запись в blockingSink, ожидающая результата от теста
+type pendingPut struct {
+	key    string
+	result chan error
+}
+
+// Приемник, запись в который ждет, пока тест не вернет ее результат
+type blockingSink struct {
+	puts chan pendingPut
+}
+
+func (s *blockingSink) Stat(ctx context.Context, key, sha string) (bool, bool, error) {
+	return false, false, nil
+}
+
+func (s *blockingSink) Put(ctx context.Context, obj sink.Object) error {
+	p := pendingPut{key: obj.Key, result: make(chan error)}
+	s.puts <- p
+	return <-p.result
+}
+
+func (s *blockingSink) Remove(ctx context.Context, key string) (int64, error) {
+	return 0, sink.ErrNotFound
+}
+
+func (s *blockingSink) Location(key string) string { return key }
+
+func TestLinkItemFileDoesNotBlockOtherUploads(t *testing.T) {
+	bs := &blockingSink{puts: make(chan pendingPut)}
+	m := newTestManager(t, Config{Sinks: map[string]sink.Sink{"slow": bs}})
+	link := func(id model.TaskID) (*model.Task, chan error) {
+		task := &model.Task{
+			ID:      id,
+			Options: model.TaskOptions{Naming: "basename", Sink: "slow"},
+			Items:   []model.Item{{URL: "https://example.com/report.txt"}},
+		}
+		done := make(chan error, 1)
+		go func() { done <- m.linkItemFile(context.Background(), task, 0, "sha", 1, fetchMeta{}) }()
+		return task, done
+	}
+	expectPut := func(want string) chan error {
+		t.Helper()
+		select {
+		case p := <-bs.puts:
+			if p.key != want {
+				t.Fatalf("put %q, want %q", p.key, want)
+			}
+			return p.result
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no put of %q while another upload is in progress", want)
+		}
+		return nil
+	}
+
+	_, failed := link("a")
+	first := expectPut("report.txt")
+	second, done := link("b")
+	expectPut("report-1.txt") <- nil
+	if err := <-done; err != nil || second.Items[0].FileName != "report-1.txt" {
+		t.Fatalf("second upload: %v, file %q", err, second.Items[0].FileName)
+	}
+
+	// Имя неудавшейся записи освобождается
+	first <- errors.New("upload failed")
+	if err := <-failed; err == nil {
+		t.Fatal("failed upload reported success")
+	}
+	third, done := link("c")
+	expectPut("report.txt") <- nil
+	if err := <-done; err != nil || third.Items[0].FileName != "report.txt" {
+		t.Fatalf("third upload: %v, file %q", err, third.Items[0].FileName)
+	}
+}
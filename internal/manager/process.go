@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"taskservice/internal/cas"
+	"taskservice/internal/model"
+	"taskservice/internal/naming"
+	"taskservice/internal/process"
+	"taskservice/internal/sink"
+	"taskservice/internal/storage"
+)
+
+// Каталог для промежуточных файлов обработки
+func (m *Manager) processingDir() string { return filepath.Join(m.cfg.DataDir, ".processing") }
+
+// Все файлы элемента в приемнике: сам файл и результаты шагов обработки
+func itemFiles(it *model.Item) []string {
+	var files []string
+	if it.FileName != "" {
+		files = append(files, it.FileName)
+	}
+	for _, s := range it.Steps {
+		files = append(files, s.Files...)
+	}
+	return files
+}
+
+// Выполняет шаги обработки загруженного файла элемента по порядку.
+// Результаты шагов записываются в приемник задачи рядом с файлом элемента; первая ошибка прерывает обработку.
+func (m *Manager) processItem(ctx context.Context, t *model.Task, idx int) error {
+	it := &t.Items[idx]
+	s, err := m.sinkFor(t)
+	if err != nil {
+		return err
+	}
+	workDir := filepath.Join(m.processingDir(), fmt.Sprintf("%s-%d", t.ID, idx))
+	_ = os.RemoveAll(workDir)
+	defer os.RemoveAll(workDir)
+
+	// Файлы прошлого запуска обработки остаются за элементом и могут быть перезаписаны
+	owned := make(map[string]bool)
+	for _, s := range it.Steps {
+		for _, f := range s.Files {
+			owned[f] = true
+		}
+	}
+	it.Steps = nil
+
+	input, name := m.cas.Path(it.SHA256), it.FileName
+	dir := path.Dir(it.FileName)
+	for i, step := range t.Options.Process {
+		sr := model.StepResult{Type: step.Type, Command: step.Command, StartedAt: time.Now()}
+		res, err := m.processor.Run(ctx, step, input, name, filepath.Join(workDir, strconv.Itoa(i)))
+		sr.Log = res.Log
+		var keys, shas []string
+		if err == nil {
+			keys, shas, err = m.publishOutputs(ctx, t, idx, s, dir, res, owned)
+		}
+		sr.FinishedAt = time.Now()
+		if err != nil {
+			sr.Status = model.StepStatusFailed
+			sr.Error = err.Error()
+			it.Steps = append(it.Steps, sr)
+			return fmt.Errorf("process step %d (%s): %w", i, step.Type, err)
+		}
+		sr.Status = model.StepStatusOK
+		sr.Files = keys
+		it.Steps = append(it.Steps, sr)
+		_ = m.store.UpdateItem(t, idx)
+		for j, o := range res.Outputs {
+			if res.Next != nil && o.Path == res.Next.Path {
+				input, name = m.cas.Path(shas[j]), keys[j]
+			}
+		}
+	}
+	return nil
+}
+
+// Записывает результаты шага в приемник рядом с файлом элемента. Имена подбираются как для файлов
+// элементов: при конфликте к подкаталогу результатов (или к имени файла) добавляется суффикс -N,
+// чужие файлы не перезаписываются. Возвращает имена и sha256 результатов.
+func (m *Manager) publishOutputs(ctx context.Context, t *model.Task, idx int, s sink.Sink, dir string, res process.Result, owned map[string]bool) ([]string, []string, error) {
+	shas := make([]string, len(res.Outputs))
+	sizes := make([]int64, len(res.Outputs))
+	for i, o := range res.Outputs {
+		sha, size, err := cas.HashFile(o.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		shas[i], sizes[i] = sha, size
+	}
+	keys, err := m.reserveOutputs(ctx, t, idx, s, dir, res, shas, owned)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, o := range res.Outputs {
+		if err := m.store.AddBlobRef(shas[i], sizes[i], storage.BlobRef{TaskID: t.ID, ItemIdx: idx}); err != nil {
+			return nil, nil, err
+		}
+		existed := m.cas.Has(shas[i])
+		if err := m.cas.Put(o.Path, shas[i]); err != nil {
+			return nil, nil, err
+		}
+		if !existed {
+			m.usage.Add(sizes[i])
+		}
+		if err := s.Put(ctx, sink.Object{Key: keys[i], SHA256: shas[i], Size: sizes[i], Path: m.cas.Path(shas[i])}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, shas, nil
+}
+
+// Подбирает и занимает свободные имена для результатов шага
+func (m *Manager) reserveOutputs(ctx context.Context, t *model.Task, idx int, s sink.Sink, dir string, res process.Result, shas []string, owned map[string]bool) ([]string, error) {
+	self := itemRef{taskID: t.ID, idx: idx}
+	place := func(n int) []string {
+		keys := make([]string, len(res.Outputs))
+		for i, o := range res.Outputs {
+			if res.Folder != "" {
+				keys[i] = path.Join(dir, naming.WithSuffix(res.Folder, n), o.Name)
+			} else {
+				keys[i] = path.Join(dir, naming.WithSuffix(o.Name, n))
+			}
+		}
+		return keys
+	}
+
+	m.namesMu.Lock()
+	defer m.namesMu.Unlock()
+	for n := 0; n < maxNameSuffix; n++ {
+		keys := place(n)
+		free := true
+		for i, key := range keys {
+			if owner, ok := m.names[key]; ok && owner != self {
+				free = false
+				break
+			} else if ok || owned[key] {
+				continue
+			}
+			exists, same, err := s.Stat(ctx, key, shas[i])
+			if err != nil {
+				return nil, err
+			}
+			if exists && !same {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+		if !hashedNaming(t) {
+			for _, key := range keys {
+				m.names[key] = self
+			}
+		}
+		return keys, nil
+	}
+	return nil, fmt.Errorf("no free name for outputs of %q", res.Folder)
+}
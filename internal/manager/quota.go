@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Период проверки свободного места во время паузы
+const diskPollInterval = 5 * time.Second
+
+// Ограничение размера загружаемого элемента
+type sizeLimit struct {
+	max    int64 // отрицательное значение - без ограничения
+	reason string
+}
+
+// Превышает ли размер ограничение
+func (l sizeLimit) exceeded(n int64) bool { return l.max >= 0 && n > l.max }
+
+// Ошибка превышения ограничения
+func (l sizeLimit) err() error {
+	return permanent(fmt.Errorf("%s exceeded (%d bytes)", l.reason, l.max))
+}
+
+// Ошибка превышения квоты DATA_DIR
+func quotaErr(q int64) error {
+	return permanent(fmt.Errorf("disk quota exceeded (%d bytes)", q))
+}
+
+// Проверяет до начала записи, что элемент ожидаемого размера size укладывается в ограничение
+// и в квоту DATA_DIR; onDisk байт элемента уже записаны и учтены в занятом месте
+func (m *Manager) checkExpectedSize(limit sizeLimit, size, onDisk int64) error {
+	if limit.exceeded(size) {
+		return limit.err()
+	}
+	if q := m.cfg.DiskQuotaBytes; q > 0 && m.usage.Load()+size-onDisk > q {
+		return quotaErr(q)
+	}
+	return nil
+}
+
+// Вычисляет ограничение размера элемента: общий MAX_ITEM_BYTES и остаток бюджета задачи
+func (m *Manager) itemSizeLimit(t *model.Task, idx int) sizeLimit {
+	lim := sizeLimit{max: -1}
+	if m.cfg.MaxItemBytes > 0 {
+		lim = sizeLimit{max: m.cfg.MaxItemBytes, reason: "item size limit"}
+	}
+	if budget := t.Options.MaxBytes; budget > 0 {
+		rem := budget
+		for i := range t.Items {
+			if i != idx && t.Items[i].Status == model.ItemStatusDone {
+				rem -= t.Items[i].SizeDownloaded
+			}
+		}
+		if rem < 0 {
+			rem = 0
+		}
+		if lim.max < 0 || rem < lim.max {
+			lim = sizeLimit{max: rem, reason: "task byte budget"}
+		}
+	}
+	return lim
+}
+
+// Пишет в файл, считая байты и прерывая запись при превышении ограничений элемента и квоты DATA_DIR
+type limitWriter struct {
+	m       *Manager
+	w       io.Writer
+	limit   sizeLimit
+	written int64 // уже записано в файл элемента, включая продолженную часть
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.limit.exceeded(lw.written + int64(len(p))) {
+		return 0, lw.limit.err()
+	}
+	if q := lw.m.cfg.DiskQuotaBytes; q > 0 && lw.m.usage.Add(int64(len(p))) > q {
+		lw.m.usage.Add(-int64(len(p)))
+		return 0, quotaErr(q)
+	} else if q <= 0 {
+		lw.m.usage.Add(int64(len(p)))
+	}
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+	if n < len(p) {
+		lw.m.usage.Add(-int64(len(p) - n))
+	}
+	return n, err
+}
+
+// Пересчитывает занятое место в DATA_DIR; жесткие ссылки на один файл учитываются один раз
+func (m *Manager) rescanUsage() {
+	seen := make(map[interface{}]bool)
+	var total int64
+	_ = filepath.WalkDir(m.cfg.DataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if key, ok := fileKey(fi); ok {
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+		total += fi.Size()
+		return nil
+	})
+	m.usage.Store(total)
+}
+
+// Приостанавливает воркер, пока свободного места меньше MIN_FREE_BYTES.
+// Загрузка возобновляется, когда свободно не меньше RESUME_FREE_BYTES. Возвращает false при остановке менеджера.
+func (m *Manager) waitForDiskSpace() bool {
+	if m.cfg.MinFreeBytes <= 0 {
+		return true
+	}
+	free, ok := diskFree(m.cfg.DataDir)
+	if !ok || free >= m.cfg.MinFreeBytes && !m.diskPaused.Load() {
+		return true
+	}
+	resume := m.cfg.ResumeFreeBytes
+	if resume < m.cfg.MinFreeBytes {
+		resume = m.cfg.MinFreeBytes
+	}
+	if m.diskPaused.CompareAndSwap(false, true) {
+		m.log.Warn("low disk space, pausing downloads", slog.Int64("free_bytes", free), slog.Int64("min_free_bytes", m.cfg.MinFreeBytes))
+	}
+	ticker := time.NewTicker(diskPollInterval)
+	defer ticker.Stop()
+	for {
+		if free, ok := diskFree(m.cfg.DataDir); !ok || free >= resume {
+			if m.diskPaused.CompareAndSwap(true, false) {
+				m.log.Info("disk space available, resuming downloads", slog.Int64("free_bytes", free))
+			}
+			return true
+		}
+		if !m.diskPaused.Load() {
+			// Другой воркер уже возобновил загрузки, но места снова мало
+			m.diskPaused.Store(true)
+		}
+		select {
+		case <-m.stopCh:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
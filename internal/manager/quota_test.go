@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+// Сервер объявляет большой Content-Length, отдает несколько байт и ждет, пока клиент закроет соединение.
+// Загрузка завершается, только если размер отклонен до чтения тела
+func oversizedServer(t *testing.T, size int64) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Write([]byte("head"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOversizedResponseIsRejectedBeforeDownload(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		opts model.TaskOptions
+		want string
+	}{
+		{"disk quota", Config{DiskQuotaBytes: 1 << 20}, model.TaskOptions{}, "disk quota exceeded"},
+		{"task budget", Config{}, model.TaskOptions{MaxBytes: 1 << 20}, "task byte budget exceeded"},
+		{"item limit", Config{MaxItemBytes: 1 << 20}, model.TaskOptions{}, "item size limit exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := oversizedServer(t, 10<<20)
+			m := newTestManager(t, tt.cfg)
+			id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/big"}}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "task failure", func() bool { return taskStatus(m, id) == model.TaskStatusFailed })
+			withTask(m, id, func(task *model.Task) {
+				if msg := task.Items[0].ErrorMessage; !strings.Contains(msg, tt.want) {
+					t.Fatalf("item error %q, want %q", msg, tt.want)
+				}
+			})
+			if n := m.usage.Load(); n != 0 {
+				t.Fatalf("disk usage %d after rejected download, want 0", n)
+			}
+			parts, _ := filepath.Glob(filepath.Join(m.cfg.DataDir, "*", "*.part"))
+			for _, p := range parts {
+				if fi, err := os.Stat(p); err == nil && fi.Size() > 0 {
+					t.Fatalf("part file %s has %d bytes", p, fi.Size())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"net/url"
+	"strings"
+
+	"taskservice/internal/download"
+	"taskservice/internal/model"
+)
+
+// Политика перенаправлений задачи: параметры задачи поверх значений по умолчанию
+func (m *Manager) redirectPolicy(t *model.Task) download.RedirectPolicy {
+	p := download.RedirectPolicy{Max: m.cfg.MaxRedirects, AllowDowngrade: m.cfg.AllowRedirectDowngrade}
+	if t.Options.MaxRedirects != nil {
+		p.Max = *t.Options.MaxRedirects
+	}
+	if t.Options.AllowDowngrade != nil {
+		p.AllowDowngrade = *t.Options.AllowDowngrade
+	}
+	return p
+}
+
+// Проверяет, что содержимое, полученное через chain, допустимо для задачи:
+// ранее загруженное по более мягкой политике содержимое повторно не используется
+func (m *Manager) redirectsAllowed(t *model.Task, chain []model.Redirect, finalURL string) bool {
+	p := m.redirectPolicy(t)
+	if len(chain) > p.Max {
+		return false
+	}
+	if p.AllowDowngrade {
+		return true
+	}
+	for i, r := range chain {
+		next := finalURL
+		if i+1 < len(chain) {
+			next = chain[i+1].URL
+		}
+		if schemeOf(r.URL) == "https" && schemeOf(next) == "http" {
+			return false
+		}
+	}
+	return true
+}
+
+// Перенаправления загрузчика в представлении элемента
+func redirectChain(in []download.Redirect) []model.Redirect {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]model.Redirect, len(in))
+	for i, r := range in {
+		out[i] = model.Redirect{URL: r.URL, Status: r.Status}
+	}
+	return out
+}
+
+func schemeOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
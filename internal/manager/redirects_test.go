@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+func TestRedirectsAllowed(t *testing.T) {
+	m := newTestManager(t, Config{MaxRedirects: 2})
+	one, yes := 1, true
+	chain := []model.Redirect{{URL: "https://a.example/1", Status: 302}, {URL: "https://b.example/2", Status: 301}}
+	downgrade := []model.Redirect{{URL: "https://a.example/1", Status: 302}}
+	tests := []struct {
+		name  string
+		opts  model.TaskOptions
+		chain []model.Redirect
+		final string
+		want  bool
+	}{
+		{"no redirects", model.TaskOptions{}, nil, "https://a.example/1", true},
+		{"within service limit", model.TaskOptions{}, chain, "https://c.example/3", true},
+		{"over task limit", model.TaskOptions{MaxRedirects: &one}, chain, "https://c.example/3", false},
+		{"downgrade", model.TaskOptions{}, downgrade, "http://a.example/1", false},
+		{"downgrade allowed by task", model.TaskOptions{AllowDowngrade: &yes}, downgrade, "http://a.example/1", true},
+		{"downgrade in the middle", model.TaskOptions{}, []model.Redirect{
+			{URL: "https://a.example/1", Status: 302}, {URL: "http://b.example/2", Status: 302},
+		}, "https://c.example/3", false},
+	}
+	for _, tt := range tests {
+		task := &model.Task{Options: tt.opts}
+		if got := m.redirectsAllowed(task, tt.chain, tt.final); got != tt.want {
+			t.Errorf("%s: redirectsAllowed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+// Источник с ETag, содержимое которого можно поменять; запоминает условные запросы
+type etagSource struct {
+	mu          sync.Mutex
+	body, etag  string
+	conditional []string
+	full        int
+}
+
+func (s *etagSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		s.conditional = append(s.conditional, inm)
+		if inm == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	s.full++
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.body))
+}
+
+func (s *etagSource) set(body, etag string) {
+	s.mu.Lock()
+	s.body, s.etag = body, etag
+	s.mu.Unlock()
+}
+
+func (s *etagSource) counts() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.full, append([]string(nil), s.conditional...)
+}
+
+func runItem(t *testing.T, m *Manager, url string, opts model.TaskOptions) model.Item {
+	t.Helper()
+	id, err := m.CreateTask([]model.Source{{URL: url}}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+	var it model.Item
+	withTask(m, id, func(t *model.Task) { it = t.Items[0] })
+	return it
+}
+
+func TestRevalidation(t *testing.T) {
+	src := &etagSource{body: "v1", etag: `"1"`}
+	srv := httptest.NewServer(src)
+	defer srv.Close()
+	m := newTestManager(t, Config{})
+	url := srv.URL + "/data.txt"
+
+	first := runItem(t, m, url, model.TaskOptions{})
+	if first.Reused || first.Unchanged {
+		t.Fatalf("first download %+v is marked reused", first)
+	}
+
+	// Источник подтверждает, что содержимое не изменилось: тело не передается
+	second := runItem(t, m, url, model.TaskOptions{})
+	full, cond := src.counts()
+	if full != 1 || len(cond) != 1 || cond[0] != `"1"` {
+		t.Fatalf("full responses %d, conditional requests %v; want 1 and [\"1\"]", full, cond)
+	}
+	if !second.Reused || !second.Unchanged || second.SHA256 != first.SHA256 {
+		t.Fatalf("revalidated item %+v, want reused unchanged %s", second, first.SHA256)
+	}
+
+	// Содержимое изменилось: загружается заново
+	src.set("v2", `"2"`)
+	third := runItem(t, m, url, model.TaskOptions{})
+	if full, _ := src.counts(); full != 2 {
+		t.Fatalf("full responses %d after change, want 2", full)
+	}
+	if third.Unchanged || third.SHA256 == first.SHA256 {
+		t.Fatalf("item after change %+v, want new content", third)
+	}
+
+	// ForceRefresh загружает без условного запроса
+	_, before := src.counts()
+	runItem(t, m, url, model.TaskOptions{ForceRefresh: true})
+	full, cond = src.counts()
+	if full != 3 || len(cond) != len(before) {
+		t.Fatalf("force refresh: full responses %d, conditional requests %v", full, cond)
+	}
+}
@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Максимальный интервал сна планировщика без задач
+const schedulerIdle = time.Hour
+
+// Сообщает, что задача ждет времени запуска
+func isScheduled(t *model.Task, now time.Time) bool {
+	return t.Options.NotBefore != nil && t.Options.NotBefore.After(now)
+}
+
+// Будит планировщик после появления новой отложенной задачи
+func (m *Manager) wakeScheduler() {
+	select {
+	case m.schedWake <- struct{}{}:
+	default:
+	}
+}
+
+// Планировщик: ставит в очередь отложенные задачи, время запуска которых наступило,
+// и создает задачи по расписаниям
+func (m *Manager) scheduler() {
+	defer m.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-m.schedWake:
+		case <-timer.C:
+		}
+		next := m.releaseDue(time.Now())
+		wait := schedulerIdle
+		if !next.IsZero() {
+			if d := time.Until(next); d < wait {
+				wait = d
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// Разрешает зависимости, ставит в очередь элементы задач, время запуска которых наступило,
+// запускает расписания и возвращает ближайшее время следующего запуска (нулевое, если его нет)
+func (m *Manager) releaseDue(now time.Time) time.Time {
+	m.resolveBlocked(now)
+	next := m.fireSchedules(now)
+	// Поля задач меняются под их блокировкой, поэтому статус берется из хранилища;
+	// releaseTask проверяет его повторно под блокировкой
+	statuses := m.store.TaskStatuses()
+	for _, t := range m.store.ListTasks() {
+		if statuses[t.ID] != model.TaskStatusScheduled {
+			continue
+		}
+		if isScheduled(t, now) {
+			if next.IsZero() || t.Options.NotBefore.Before(next) {
+				next = *t.Options.NotBefore
+			}
+			continue
+		}
+		m.releaseTask(t.ID)
+	}
+	return next
+}
+
+// Переводит отложенную задачу в очередь
+func (m *Manager) releaseTask(id model.TaskID) {
+	lock := m.getTaskLock(id)
+	lock.Lock()
+	t, ok := m.store.GetTask(id)
+	if !ok || t.Status != model.TaskStatusScheduled {
+		lock.Unlock()
+		return
+	}
+	var queued []queueItem
+	for idx := range t.Items {
+		if t.Items[idx].Status == model.ItemStatusScheduled {
+			m.setItemStatus(t, idx, model.ItemStatusQueued, model.ActorScheduler)
+			queued = append(queued, queueItem{taskID: id, itemIdx: idx})
+		}
+	}
+	m.setTaskStatus(t, model.TaskStatusPending, model.ActorScheduler)
+	_ = m.store.UpdateTask(t)
+	lock.Unlock()
+	// Очередь может быть заполнена: элементы ставятся в фоне, чтобы не задерживать планировщик
+	m.enqueueAll(queued)
+}
@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+func TestScheduledTaskIsReleasedAtNotBefore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{})
+	at := time.Now().Add(300 * time.Millisecond)
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/a"}}, model.TaskOptions{NotBefore: &at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := taskStatus(m, id); s != model.TaskStatusScheduled {
+		t.Fatalf("status %s, want scheduled", s)
+	}
+	waitFor(t, "scheduled task completion", func() bool { return taskStatus(m, id) == model.TaskStatusCompleted })
+	if time.Now().Before(at) {
+		t.Fatal("task completed before not_before")
+	}
+}
+
+func TestReleaseDoesNotBlockOnFullQueue(t *testing.T) {
+	hold, held := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hold" {
+			close(held)
+			<-hold
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+	defer close(hold)
+
+	m := newTestManager(t, Config{WorkerCount: 1})
+	// Единственный воркер занят, очередь не разбирается
+	if _, err := m.CreateTask([]model.Source{{URL: srv.URL + "/hold"}}, model.TaskOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-held
+
+	far := time.Now().Add(time.Hour)
+	srcs := make([]model.Source, cap(m.queue)+100)
+	for i := range srcs {
+		srcs[i] = model.Source{URL: fmt.Sprintf("%s/f%d", srv.URL, i)}
+	}
+	big, err := m.CreateTask(srcs, model.TaskOptions{NotBefore: &far})
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := m.CreateTask([]model.Source{{URL: srv.URL + "/small"}}, model.TaskOptions{NotBefore: &far})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.releaseDue(far.Add(time.Minute))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("releasing scheduled tasks blocked on a full queue")
+	}
+	for _, id := range []model.TaskID{big, small} {
+		if s := taskStatus(m, id); s != model.TaskStatusPending {
+			t.Fatalf("task %s status %s, want pending", id, s)
+		}
+	}
+}
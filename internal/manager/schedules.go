@@ -0,0 +1,244 @@
+package manager
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"taskservice/internal/cron"
+	"taskservice/internal/model"
+	"taskservice/internal/util"
+)
+
+const (
+	// Опоздание срабатывания, при котором оно не считается пропущенным
+	scheduleGrace = time.Minute
+	// Максимум задач, создаваемых за один раз при политике catch_up=all
+	maxCatchUpRuns = 100
+)
+
+// Проверяет расписание, подставляет значения по умолчанию и возвращает разобранное выражение
+func (m *Manager) validateSchedule(sc *model.Schedule) (*cron.Schedule, *time.Location, error) {
+	if (len(sc.URLs) == 0) == (sc.Manifest == "") {
+		return nil, nil, fmt.Errorf("%w: exactly one of urls and manifest is required", ErrInvalidSchedule)
+	}
+	if sc.Options.NotBefore != nil || sc.Options.ExpiresAt != nil || len(sc.Options.DependsOn) > 0 {
+		return nil, nil, fmt.Errorf("%w: not_before, expires_at and depends_on are not allowed in schedule options", ErrInvalidSchedule)
+	}
+	if sc.Retain < 0 {
+		return nil, nil, fmt.Errorf("%w: retain must not be negative", ErrInvalidSchedule)
+	}
+	switch sc.CatchUp {
+	case "":
+		sc.CatchUp = model.CatchUpOnce
+	case model.CatchUpSkip, model.CatchUpOnce, model.CatchUpAll:
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown catch_up %q", ErrInvalidSchedule, sc.CatchUp)
+	}
+	expr, err := cron.Parse(sc.Cron)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	loc := time.UTC
+	if sc.Timezone != "" {
+		if loc, err = time.LoadLocation(sc.Timezone); err != nil {
+			return nil, nil, fmt.Errorf("%w: timezone: %v", ErrInvalidSchedule, err)
+		}
+	}
+	if expr.Next(time.Now().In(loc)).IsZero() {
+		return nil, nil, fmt.Errorf("%w: cron expression never fires", ErrInvalidSchedule)
+	}
+	// Ошибки параметров задачи возвращаются как ErrInvalidTask
+	if err := m.validateTask(sc.URLs, &sc.Options); err != nil {
+		return nil, nil, err
+	}
+	// Адрес манифеста проверяется политикой исходящих соединений сразу, как при создании задачи,
+	// а не только при срабатывании
+	if sc.Manifest != "" {
+		if err := m.validateURL(sc.Manifest); err != nil {
+			return nil, nil, fmt.Errorf("%w: manifest: %v", ErrInvalidSchedule, err)
+		}
+	}
+	return expr, loc, nil
+}
+
+// Вычисляет следующее срабатывание расписания после t
+func nextRun(expr *cron.Schedule, loc *time.Location, t time.Time) *time.Time {
+	next := expr.Next(t.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// Создает расписание
+func (m *Manager) CreateSchedule(sc model.Schedule) (*model.Schedule, error) {
+	expr, loc, err := m.validateSchedule(&sc)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	sc.ID = model.ScheduleID(util.NewID())
+	sc.CreatedAt, sc.UpdatedAt = now, now
+	sc.LastRunAt, sc.LastError = nil, ""
+	sc.NextRunAt = nextRun(expr, loc, now)
+	m.schedMu.Lock()
+	err = m.store.UpsertSchedule(&sc)
+	m.schedMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	m.wakeScheduler()
+	return &sc, nil
+}
+
+// Заменяет параметры расписания; время следующего срабатывания вычисляется заново
+func (m *Manager) UpdateSchedule(id model.ScheduleID, sc model.Schedule) (*model.Schedule, error) {
+	expr, loc, err := m.validateSchedule(&sc)
+	if err != nil {
+		return nil, err
+	}
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	old, ok := m.store.GetSchedule(id)
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	now := time.Now().UTC()
+	sc.ID, sc.CreatedAt, sc.UpdatedAt = id, old.CreatedAt, now
+	sc.LastRunAt, sc.LastError = old.LastRunAt, old.LastError
+	sc.NextRunAt = nextRun(expr, loc, now)
+	if err := m.store.UpsertSchedule(&sc); err != nil {
+		return nil, err
+	}
+	m.wakeScheduler()
+	return &sc, nil
+}
+
+// Удаляет расписание; созданные им задачи сохраняются
+func (m *Manager) DeleteSchedule(id model.ScheduleID) error {
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	if _, ok := m.store.GetSchedule(id); !ok {
+		return ErrScheduleNotFound
+	}
+	return m.store.DeleteSchedule(id)
+}
+
+// Список расписаний
+func (m *Manager) ListSchedules() []*model.Schedule { return m.store.ListSchedules() }
+
+// Возвращает расписание
+func (m *Manager) GetSchedule(id model.ScheduleID) (*model.Schedule, bool) {
+	return m.store.GetSchedule(id)
+}
+
+// Создает задачи по наступившим срабатываниям расписаний
+// и возвращает ближайшее будущее срабатывание (нулевое, если его нет)
+func (m *Manager) fireSchedules(now time.Time) time.Time {
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	var next time.Time
+	for _, sc := range m.store.ListSchedules() {
+		if sc.Paused || sc.NextRunAt == nil {
+			continue
+		}
+		if sc.NextRunAt.After(now) {
+			if next.IsZero() || sc.NextRunAt.Before(next) {
+				next = *sc.NextRunAt
+			}
+			continue
+		}
+		if n := m.fireSchedule(sc, now); n != nil && (next.IsZero() || n.Before(next)) {
+			next = *n
+		}
+	}
+	return next
+}
+
+// Выполняет наступившие срабатывания расписания с учетом политики catch_up
+// и возвращает время следующего срабатывания
+func (m *Manager) fireSchedule(sc *model.Schedule, now time.Time) *time.Time {
+	expr, err := cron.Parse(sc.Cron)
+	if err != nil {
+		// Выражение проверялось при сохранении; сюда попадают только поврежденные записи
+		m.log.Error("schedule: invalid cron", slog.String("schedule_id", string(sc.ID)), slog.String("error", err.Error()))
+		return nil
+	}
+	loc := time.UTC
+	if sc.Timezone != "" {
+		if l, err := time.LoadLocation(sc.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	// Срабатывания, наступившие с момента NextRunAt
+	missed := []time.Time{*sc.NextRunAt}
+	for len(missed) < maxCatchUpRuns {
+		n := nextRun(expr, loc, missed[len(missed)-1])
+		if n == nil || n.After(now) {
+			break
+		}
+		missed = append(missed, *n)
+	}
+	runs := 0
+	switch sc.CatchUp {
+	case model.CatchUpAll:
+		runs = len(missed)
+	case model.CatchUpSkip:
+		// Выполняется только текущее срабатывание, если оно не опоздало
+		if now.Sub(missed[len(missed)-1]) <= scheduleGrace {
+			runs = 1
+		}
+	default:
+		runs = 1
+	}
+
+	sc.LastError = ""
+	for i := 0; i < runs; i++ {
+		spec := taskSpec{sources: sc.URLs, manifest: sc.Manifest, scheduleID: sc.ID, actor: model.ActorScheduler}
+		if _, err := m.createTask(spec, sc.Options); err != nil {
+			sc.LastError = err.Error()
+			m.log.Error("schedule: create task", slog.String("schedule_id", string(sc.ID)), slog.String("error", err.Error()))
+			break
+		}
+	}
+	if runs > 0 {
+		at := now.UTC()
+		sc.LastRunAt = &at
+	}
+	sc.NextRunAt = nextRun(expr, loc, now)
+	if err := m.store.UpsertSchedule(sc); err != nil {
+		m.log.Error("schedule: save", slog.String("schedule_id", string(sc.ID)), slog.String("error", err.Error()))
+	}
+	if sc.Retain > 0 {
+		m.pruneScheduleTasks(sc)
+	}
+	return sc.NextRunAt
+}
+
+// Удаляет самые старые завершенные задачи расписания сверх лимита retain
+func (m *Manager) pruneScheduleTasks(sc *model.Schedule) {
+	var tasks []*model.Task
+	for _, t := range m.store.ListTasks() {
+		if t.ScheduleID == sc.ID {
+			tasks = append(tasks, t)
+		}
+	}
+	if len(tasks) <= sc.Retain {
+		return
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].CreatedAt.After(tasks[j].CreatedAt) })
+	// Статус берется из хранилища: поля задач меняются под их блокировкой
+	statuses := m.store.TaskStatuses()
+	for _, t := range tasks[sc.Retain:] {
+		if !statuses[t.ID].IsTerminal() {
+			continue
+		}
+		if _, err := m.DeleteTask(t.ID, true, false); err != nil {
+			m.log.Error("schedule: prune task", slog.String("schedule_id", string(sc.ID)), slog.String("task_id", string(t.ID)), slog.String("error", err.Error()))
+		}
+	}
+}
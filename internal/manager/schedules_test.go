@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"taskservice/internal/egress"
+	"taskservice/internal/model"
+)
+
+func TestCreateScheduleChecksManifestEgress(t *testing.T) {
+	policy, err := egress.New(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestManager(t, Config{Egress: policy})
+	for _, manifest := range []string{"http://127.0.0.1/list.txt", "http://169.254.169.254/latest", "http://localhost/list.txt"} {
+		_, err := m.CreateSchedule(model.Schedule{Manifest: manifest, Cron: "@hourly"})
+		if !errors.Is(err, ErrInvalidSchedule) || !strings.Contains(err.Error(), egress.ErrBlocked.Error()) {
+			t.Errorf("manifest %s: error %v, want ErrInvalidSchedule blocked by egress", manifest, err)
+		}
+	}
+	if len(m.store.ListSchedules()) != 0 {
+		t.Fatal("schedule with a blocked manifest was saved")
+	}
+	if _, err := m.CreateSchedule(model.Schedule{Manifest: "https://example.com/list.txt", Cron: "@hourly"}); err != nil {
+		t.Fatalf("public manifest: %v", err)
+	}
+}
+
+func TestCreateScheduleValidation(t *testing.T) {
+	m := newTestManager(t, Config{})
+	tests := map[string]model.Schedule{
+		"bad cron":              {URLs: []model.Source{{URL: "http://example.com/a"}}, Cron: "* * *"},
+		"never fires":           {URLs: []model.Source{{URL: "http://example.com/a"}}, Cron: "0 0 30 2 *"},
+		"bad timezone":          {URLs: []model.Source{{URL: "http://example.com/a"}}, Cron: "@daily", Timezone: "Mars/Olympus"},
+		"urls+manifest":         {URLs: []model.Source{{URL: "http://example.com/a"}}, Manifest: "http://example.com/m", Cron: "@daily"},
+		"bad catch_up":          {URLs: []model.Source{{URL: "http://example.com/a"}}, Cron: "@daily", CatchUp: "sometimes"},
+		"no sources":            {Cron: "@daily"},
+		"manifest without host": {Manifest: "http://", Cron: "@daily"},
+	}
+	for name, sc := range tests {
+		if _, err := m.CreateSchedule(sc); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s: error %v, want ErrInvalidSchedule", name, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package manager
+
+import (
+	"fmt"
+
+	"taskservice/internal/model"
+	"taskservice/internal/sink"
+)
+
+// Имя приемника задачи; задачи без приемника записаны локально
+func sinkName(t *model.Task) string {
+	if t.Options.Sink == "" {
+		return sink.LocalName
+	}
+	return t.Options.Sink
+}
+
+// Приемник задачи
+func (m *Manager) sinkFor(t *model.Task) (sink.Sink, error) {
+	s, ok := m.sinks[sinkName(t)]
+	if !ok {
+		return nil, permanent(fmt.Errorf("sink %q is not configured", sinkName(t)))
+	}
+	return s, nil
+}
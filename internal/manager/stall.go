@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Передача прервана из-за отсутствия данных, низкой скорости или истечения времени попытки.
+// Ошибка не постоянная: следующая попытка продолжает загрузку с места остановки
+var errStalled = errors.New("transfer stalled")
+
+// Ограничения одной попытки загрузки; нулевые значения отключают проверку
+type stallLimits struct {
+	idle     time.Duration
+	minRate  int64
+	window   time.Duration
+	deadline time.Duration
+}
+
+// Ограничения для элементов задачи: параметры задачи поверх значений по умолчанию
+func (m *Manager) stallLimits(t *model.Task) stallLimits {
+	l := stallLimits{idle: m.cfg.IdleTimeout, minRate: m.cfg.MinThroughput, window: m.cfg.ThroughputWindow, deadline: m.cfg.ItemDeadline}
+	o := t.Options
+	if o.IdleTimeout != nil {
+		l.idle = time.Duration(*o.IdleTimeout)
+	}
+	if o.MinThroughput != nil {
+		l.minRate = *o.MinThroughput
+	}
+	if o.ThroughputWindow != nil {
+		l.window = time.Duration(*o.ThroughputWindow)
+	}
+	if o.ItemDeadline != nil {
+		l.deadline = time.Duration(*o.ItemDeadline)
+	}
+	if l.minRate > 0 && l.window <= 0 {
+		l.window = defaultThroughputWindow
+	}
+	return l
+}
+
+// Окно оценки скорости, если минимальная скорость задана без окна
+const defaultThroughputWindow = 30 * time.Second
+
+// Проверяет ограничения попытки
+func validateStallOptions(o model.TaskOptions) error {
+	durations := []struct {
+		name string
+		d    *model.Duration
+	}{{"idle_timeout", o.IdleTimeout}, {"throughput_window", o.ThroughputWindow}, {"item_deadline", o.ItemDeadline}}
+	for _, v := range durations {
+		if v.d != nil && *v.d < 0 {
+			return fmt.Errorf("%s must not be negative", v.name)
+		}
+	}
+	if o.MinThroughput != nil && *o.MinThroughput < 0 {
+		return errors.New("min_throughput must not be negative")
+	}
+	return nil
+}
+
+// Наблюдение за передачей: прерывает контекст попытки, если данные не поступают,
+// скорость за окно ниже минимальной или истекло время попытки
+type stallWatch struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	limits stallLimits
+	read   atomic.Int64
+	last   atomic.Int64 // время последнего получения данных, UnixNano
+	done   chan struct{}
+}
+
+// Запускает наблюдение; контекст попытки - w.ctx
+func watchStall(parent context.Context, l stallLimits) *stallWatch {
+	ctx, cancel := context.WithCancelCause(parent)
+	w := &stallWatch{ctx: ctx, cancel: cancel, limits: l, done: make(chan struct{})}
+	w.last.Store(time.Now().UnixNano())
+	if l.deadline > 0 {
+		deadline := time.AfterFunc(l.deadline, func() {
+			cancel(fmt.Errorf("%w: item deadline %s exceeded", errStalled, l.deadline))
+		})
+		context.AfterFunc(ctx, func() { deadline.Stop() })
+	}
+	if l.idle > 0 || l.minRate > 0 {
+		go w.run()
+	} else {
+		close(w.done)
+	}
+	return w
+}
+
+// Сообщает о полученных данных; read - всего прочитано в этой попытке
+func (w *stallWatch) progress(read int64) {
+	if w.read.Swap(read) != read {
+		w.last.Store(time.Now().UnixNano())
+	}
+}
+
+// Останавливает наблюдение
+func (w *stallWatch) stop() {
+	w.cancel(nil)
+	<-w.done
+}
+
+// Причина прерывания попытки, если она прервана наблюдением
+func (w *stallWatch) err() error {
+	if cause := context.Cause(w.ctx); errors.Is(cause, errStalled) {
+		return cause
+	}
+	return nil
+}
+
+// Отметка о прочитанном объеме для оценки скорости
+type rateSample struct {
+	at   time.Time
+	read int64
+}
+
+func (w *stallWatch) run() {
+	defer close(w.done)
+	l := w.limits
+	tick := time.Second
+	for _, d := range []time.Duration{l.idle / 4, l.window / 4} {
+		if d > 0 && d < tick {
+			tick = d
+		}
+	}
+	if tick < 50*time.Millisecond {
+		tick = 50 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	samples := []rateSample{{at: time.Now()}}
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if l.idle > 0 {
+				if idle := now.Sub(time.Unix(0, w.last.Load())); idle >= l.idle {
+					w.cancel(fmt.Errorf("%w: no data for %s", errStalled, idle.Round(time.Millisecond)))
+					return
+				}
+			}
+			if l.minRate <= 0 {
+				continue
+			}
+			read := w.read.Load()
+			samples = append(samples, rateSample{at: now, read: read})
+			// Начало окна - последняя отметка, сделанная не позже now-window
+			for len(samples) > 1 && !samples[1].at.After(now.Add(-l.window)) {
+				samples = samples[1:]
+			}
+			if elapsed := now.Sub(samples[0].at); elapsed >= l.window {
+				rate := float64(read-samples[0].read) / elapsed.Seconds()
+				if rate < float64(l.minRate) {
+					w.cancel(fmt.Errorf("%w: %.0f B/s over %s, minimum %d B/s", errStalled, rate, l.window, l.minRate))
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+func waitStalled(t *testing.T, w *stallWatch, within time.Duration) error {
+	t.Helper()
+	select {
+	case <-w.ctx.Done():
+		return w.err()
+	case <-time.After(within):
+		t.Fatalf("attempt is not interrupted within %s", within)
+		return nil
+	}
+}
+
+func TestStallWatch(t *testing.T) {
+	t.Run("idle", func(t *testing.T) {
+		w := watchStall(context.Background(), stallLimits{idle: 150 * time.Millisecond})
+		defer w.stop()
+		if err := waitStalled(t, w, 2*time.Second); !errors.Is(err, errStalled) || !strings.Contains(err.Error(), "no data") {
+			t.Fatalf("err %v, want idle stall", err)
+		}
+	})
+	t.Run("progress keeps attempt alive", func(t *testing.T) {
+		w := watchStall(context.Background(), stallLimits{idle: 200 * time.Millisecond})
+		for i := int64(1); i <= 8; i++ {
+			time.Sleep(50 * time.Millisecond)
+			w.progress(i)
+		}
+		if w.ctx.Err() != nil {
+			t.Fatalf("attempt with steady progress interrupted: %v", w.err())
+		}
+		w.stop()
+		if err := w.err(); err != nil {
+			t.Fatalf("err after stop %v, want nil", err)
+		}
+	})
+	t.Run("slow", func(t *testing.T) {
+		w := watchStall(context.Background(), stallLimits{minRate: 1 << 20, window: 200 * time.Millisecond})
+		defer w.stop()
+		go func() {
+			for i := int64(1); w.ctx.Err() == nil; i++ {
+				w.progress(i)
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		if err := waitStalled(t, w, 2*time.Second); !errors.Is(err, errStalled) || !strings.Contains(err.Error(), "B/s") {
+			t.Fatalf("err %v, want throughput stall", err)
+		}
+	})
+	t.Run("deadline", func(t *testing.T) {
+		w := watchStall(context.Background(), stallLimits{deadline: 100 * time.Millisecond})
+		defer w.stop()
+		if err := waitStalled(t, w, 2*time.Second); !errors.Is(err, errStalled) || !strings.Contains(err.Error(), "deadline") {
+			t.Fatalf("err %v, want deadline", err)
+		}
+	})
+	t.Run("parent canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := watchStall(ctx, stallLimits{idle: time.Minute})
+		cancel()
+		<-w.ctx.Done()
+		w.stop()
+		if err := w.err(); err != nil {
+			t.Fatalf("err %v, want nil for canceled parent", err)
+		}
+	})
+}
+
+func TestStalledDownloadResumes(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	var attempts atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if n := attempts.Add(1); n == 1 {
+			// Первая попытка: половина файла, затем тишина
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content[:10]))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		if r.Header.Get("Range") != "bytes=10-" {
+			http.Error(w, "expected resume from byte 10, got "+r.Header.Get("Range"), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[10:]))
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{IdleTimeout: 200 * time.Millisecond, BaseBackoff: 10 * time.Millisecond, MaxRetryPerItem: 1})
+	id, err := m.CreateTask([]model.Source{{URL: srv.URL + "/f.txt"}}, model.TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "task completion", func() bool {
+		s := taskStatus(m, id)
+		return s == model.TaskStatusCompleted || s == model.TaskStatusFailed
+	})
+	withTask(m, id, func(task *model.Task) {
+		it := task.Items[0]
+		sum := sha256.Sum256([]byte(content))
+		if task.Status != model.TaskStatusCompleted || it.Attempts != 1 || it.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Fatalf("task %s, item %+v; want completed after one stalled attempt", task.Status, it)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"taskservice/internal/checksum"
+	"taskservice/internal/model"
+)
+
+// ErrInvalid возвращается для манифеста, который не удалось разобрать
+var ErrInvalid = errors.New("invalid manifest")
+
+// Формат манифеста
+type Format string
+
+const (
+	// FormatLines - URL по одному в строке
+	FormatLines Format = "lines"
+	// FormatJSON - JSON массив строк или объектов {"url", "mirrors", "checksum"}
+	FormatJSON Format = "json"
+	// FormatMetalink - Metalink 4 (.meta4) или Metalink 3 (.metalink)
+	FormatMetalink Format = "metalink"
+)
+
+// Detect определяет формат по первому значимому символу
+func Detect(data []byte) Format {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case len(data) > 0 && data[0] == '[':
+		return FormatJSON
+	case len(data) > 0 && data[0] == '<':
+		return FormatMetalink
+	}
+	return FormatLines
+}
+
+// Parse разбирает манифест любого поддерживаемого формата
+func Parse(data []byte) ([]model.Source, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var (
+		srcs []model.Source
+		err  error
+	)
+	switch Detect(data) {
+	case FormatJSON:
+		srcs, err = parseJSON(data)
+	case FormatMetalink:
+		srcs, err = parseMetalink(data)
+	default:
+		srcs, err = parseLines(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("%w: no entries", ErrInvalid)
+	}
+	return srcs, nil
+}
+
+// Максимальная длина строки списка
+const maxLineLen = 1 << 20
+
+// ParseLine разбирает строку вида "URL [зеркало ...] [algo:hex]";
+// ok=false для пустых строк и комментариев, начинающихся с #
+func ParseLine(line string) (src model.Source, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return src, false, nil
+	}
+	fields := strings.Fields(line)
+	src.URL = fields[0]
+	for _, f := range fields[1:] {
+		if algo, _, found := strings.Cut(f, ":"); found && checksum.Supported(algo) {
+			if _, err := checksum.Parse(f); err != nil {
+				return src, true, err
+			}
+			src.Checksum = f
+			continue
+		}
+		src.Mirrors = append(src.Mirrors, f)
+	}
+	return src, true, nil
+}
+
+// ScanLines читает список строк из потока, не загружая его целиком, и вызывает fn для каждой
+// значимой строки с ее номером (с 1) и ошибкой разбора; ошибка fn прекращает чтение
+func ScanLines(r io.Reader, fn func(line int, src model.Source, err error) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxLineLen)
+	for n := 1; sc.Scan(); n++ {
+		src, ok, err := ParseLine(sc.Text())
+		if !ok {
+			continue
+		}
+		if err := fn(n, src, err); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return nil
+}
+
+// Строки по одному URL; ошибка любой строки делает манифест некорректным
+func parseLines(data []byte) ([]model.Source, error) {
+	var out []model.Source
+	err := ScanLines(bytes.NewReader(data), func(n int, src model.Source, err error) error {
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalid, n, err)
+		}
+		out = append(out, src)
+		return nil
+	})
+	return out, err
+}
+
+// JSON массив строк URL или объектов model.Source
+func parseJSON(data []byte) ([]model.Source, error) {
+	var out []model.Source
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return out, nil
+}
+
+// Файл в Metalink; поля версий 4 и 3 разбираются одной структурой, пространства имен не проверяются
+type metalinkFile struct {
+	Name     string         `xml:"name,attr"`
+	Hashes   []metalinkHash `xml:"hash"`
+	V3Hashes []metalinkHash `xml:"verification>hash"`
+	URLs     []metalinkURL  `xml:"url"`
+	V3URLs   []metalinkURL  `xml:"resources>url"`
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type metalinkURL struct {
+	Priority   int    `xml:"priority,attr"`   // Metalink 4: меньше - предпочтительнее
+	Preference int    `xml:"preference,attr"` // Metalink 3: больше - предпочтительнее
+	Value      string `xml:",chardata"`
+}
+
+type metalinkDoc struct {
+	Files   []metalinkFile `xml:"file"`
+	V3Files []metalinkFile `xml:"files>file"`
+}
+
+// Предпочтительные алгоритмы контрольных сумм: sha256 проверяется без повторного чтения файла
+var hashPreference = []string{"sha256", "sha512", "sha1", "md5"}
+
+// Metalink: адреса по приоритету становятся URL и зеркалами, выбирается лучшая поддерживаемая сумма
+func parseMetalink(data []byte) ([]model.Source, error) {
+	var doc metalinkDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	files := append(doc.Files, doc.V3Files...)
+	out := make([]model.Source, 0, len(files))
+	for i, f := range files {
+		urls := append(f.URLs, f.V3URLs...)
+		sort.SliceStable(urls, func(a, b int) bool {
+			if urls[a].Priority != urls[b].Priority {
+				return priority(urls[a].Priority) < priority(urls[b].Priority)
+			}
+			return urls[a].Preference > urls[b].Preference
+		})
+		var src model.Source
+		for _, u := range urls {
+			v := strings.TrimSpace(u.Value)
+			if v == "" {
+				continue
+			}
+			if src.URL == "" {
+				src.URL = v
+			} else {
+				src.Mirrors = append(src.Mirrors, v)
+			}
+		}
+		if src.URL == "" {
+			return nil, fmt.Errorf("%w: file %d (%s) has no urls", ErrInvalid, i, f.Name)
+		}
+		sums := make(map[string]string)
+		for _, h := range append(f.Hashes, f.V3Hashes...) {
+			sums[checksum.NormalizeAlgo(h.Type)] = strings.TrimSpace(h.Value)
+		}
+		for _, algo := range hashPreference {
+			if v, ok := sums[algo]; ok {
+				sum, err := checksum.Parse(algo + ":" + v)
+				if err != nil {
+					return nil, fmt.Errorf("%w: file %d (%s): %v", ErrInvalid, i, f.Name, err)
+				}
+				src.Checksum = sum.String()
+				break
+			}
+		}
+		out = append(out, src)
+	}
+	return out, nil
+}
+
+// Адреса без приоритета идут после адресов с приоритетом
+func priority(p int) int {
+	if p <= 0 {
+		return 1 << 30
+	}
+	return p
+}
@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+var (
+	sha256Hex = strings.Repeat("ab", 32)
+	md5Hex    = strings.Repeat("cd", 16)
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		in     string
+		want   []model.Source
+	}{
+		{"lines", FormatLines,
+			"\xef\xbb\xbf# список\nhttps://a.example/1\n\n  https://b.example/2 https://m.example/2 sha256:" + sha256Hex + "\n",
+			[]model.Source{
+				{URL: "https://a.example/1"},
+				{URL: "https://b.example/2", Mirrors: []string{"https://m.example/2"}, Checksum: "sha256:" + sha256Hex},
+			}},
+		{"json", FormatJSON,
+			` ["https://a.example/1", {"url": "https://b.example/2", "mirrors": ["https://m.example/2"]}]`,
+			[]model.Source{
+				{URL: "https://a.example/1"},
+				{URL: "https://b.example/2", Mirrors: []string{"https://m.example/2"}},
+			}},
+		{"metalink 4", FormatMetalink, `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="a.iso">
+    <hash type="md5">` + md5Hex + `</hash>
+    <hash type="sha-256">` + strings.ToUpper(sha256Hex) + `</hash>
+    <url>https://noprio.example/a.iso</url>
+    <url priority="2">https://second.example/a.iso</url>
+    <url priority="1">https://first.example/a.iso</url>
+  </file>
+  <file name="b.iso">
+    <hash type="md5">` + md5Hex + `</hash>
+    <url>https://b.example/b.iso</url>
+  </file>
+</metalink>`,
+			[]model.Source{
+				{URL: "https://first.example/a.iso", Mirrors: []string{"https://second.example/a.iso", "https://noprio.example/a.iso"}, Checksum: "sha256:" + sha256Hex},
+				{URL: "https://b.example/b.iso", Checksum: "md5:" + md5Hex},
+			}},
+		{"metalink 3", FormatMetalink, `<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="c.tar">
+      <verification><hash type="sha256">` + sha256Hex + `</hash></verification>
+      <resources>
+        <url type="http" preference="10">https://low.example/c.tar</url>
+        <url type="http" preference="90">https://high.example/c.tar</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`,
+			[]model.Source{
+				{URL: "https://high.example/c.tar", Mirrors: []string{"https://low.example/c.tar"}, Checksum: "sha256:" + sha256Hex},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if f := Detect([]byte(tt.in)); f != tt.format {
+				t.Fatalf("Detect = %s, want %s", f, tt.format)
+			}
+			got, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"empty":             "",
+		"only comments":     "# nothing\n\n",
+		"bad checksum":      "https://a.example/1 sha256:xyz",
+		"bad json":          `["https://a.example/1",`,
+		"bad xml":           `<metalink><file>`,
+		"file without urls": `<metalink><file name="x"></file></metalink>`,
+		"bad metalink hash": `<metalink><file name="x"><hash type="sha-256">zz</hash><url>https://a.example/x</url></file></metalink>`,
+	} {
+		if _, err := Parse([]byte(in)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestScanLinesReportsLineNumbers(t *testing.T) {
+	in := "https://a.example/1\n# comment\nhttps://b.example/2 md5:zz\nhttps://c.example/3\n"
+	var lines []int
+	var failed []int
+	err := ScanLines(strings.NewReader(in), func(n int, src model.Source, err error) error {
+		lines = append(lines, n)
+		if err != nil {
+			failed = append(failed, n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, []int{1, 3, 4}) || !reflect.DeepEqual(failed, []int{3}) {
+		t.Fatalf("lines %v, failed %v; want [1 3 4] and [3]", lines, failed)
+	}
+}
@@ -0,0 +1,334 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Набор метрик, выводимый в текстовом формате Prometheus
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// Метрика с именем, описанием и типом, которая умеет выводить свои серии
+type metric interface {
+	desc() (name, help, typ string)
+	write(w *bufio.Writer)
+}
+
+// Создает пустой набор метрик
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Набор по умолчанию, в котором регистрируются метрики пакетов сервиса
+var Default = NewRegistry()
+
+// Регистрирует метрику; метрика с тем же именем заменяется.
+// Метрики без меток выводятся сразу с нулевым значением
+func (r *Registry) register(m metric) {
+	name, _, _ := m.desc()
+	r.mu.Lock()
+	r.metrics[name] = m
+	r.mu.Unlock()
+}
+
+// Выводит все метрики в текстовом формате Prometheus, упорядоченные по имени
+func (r *Registry) Write(out io.Writer) error {
+	r.mu.Lock()
+	list := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		list = append(list, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		a, _, _ := list[i].desc()
+		b, _, _ := list[j].desc()
+		return a < b
+	})
+	w := bufio.NewWriter(out)
+	for _, m := range list {
+		name, help, typ := m.desc()
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+		m.write(w)
+	}
+	return w.Flush()
+}
+
+// Обработчик HTTP, отдающий метрики набора
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// Общая часть метрик: имя, описание и имена меток
+type meta struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// Серии метрики по значениям меток
+type series[T any] struct {
+	mu     sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+}
+
+func (s *series[T]) get(m *meta, labelValues []string) *T {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	if s.values == nil {
+		s.values = make(map[string]*T)
+		s.keys = make(map[string][]string)
+	}
+	v := new(T)
+	s.values[key] = v
+	s.keys[key] = append([]string(nil), labelValues...)
+	return v
+}
+
+// Обходит серии в порядке значений меток
+func (s *series[T]) each(fn func(labelValues []string, v *T)) {
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fn(s.keys[k], s.values[k])
+	}
+}
+
+// Счетчик: монотонно растущее значение
+type Counter struct {
+	meta
+	s series[float64]
+}
+
+// Регистрирует счетчик в наборе по умолчанию
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{meta: meta{name: name, help: help, labels: labels}}
+	if len(labels) == 0 {
+		c.Add(0)
+	}
+	Default.register(c)
+	return c
+}
+
+// Увеличивает счетчик на 1
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Увеличивает счетчик на v
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.s.mu.Lock()
+	*c.s.get(&c.meta, labelValues) += v
+	c.s.mu.Unlock()
+}
+
+func (c *Counter) desc() (string, string, string) { return c.name, c.help, "counter" }
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.each(func(lv []string, v *float64) { writeSample(w, c.name, c.labels, lv, "", "", *v) })
+}
+
+// Измеритель: значение, которое может расти и уменьшаться
+type Gauge struct {
+	meta
+	s series[float64]
+}
+
+// Регистрирует измеритель в наборе по умолчанию
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{meta: meta{name: name, help: help, labels: labels}}
+	if len(labels) == 0 {
+		g.Add(0)
+	}
+	Default.register(g)
+	return g
+}
+
+// Устанавливает значение
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.s.mu.Lock()
+	*g.s.get(&g.meta, labelValues) = v
+	g.s.mu.Unlock()
+}
+
+// Изменяет значение на v
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.s.mu.Lock()
+	*g.s.get(&g.meta, labelValues) += v
+	g.s.mu.Unlock()
+}
+
+func (g *Gauge) desc() (string, string, string) { return g.name, g.help, "gauge" }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	g.s.each(func(lv []string, v *float64) { writeSample(w, g.name, g.labels, lv, "", "", *v) })
+}
+
+// Измеритель, значения которого вычисляются при каждом выводе метрик
+type GaugeFunc struct {
+	meta
+	fn func() map[string]float64
+}
+
+// Регистрирует вычисляемый измеритель без меток
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.register(&GaugeFunc{meta: meta{name: name, help: help}, fn: func() map[string]float64 {
+		return map[string]float64{"": fn()}
+	}})
+}
+
+// Регистрирует вычисляемый измеритель с одной меткой: fn возвращает значения по значению метки
+func NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	Default.register(&GaugeFunc{meta: meta{name: name, help: help, labels: []string{label}}, fn: fn})
+}
+
+func (g *GaugeFunc) desc() (string, string, string) { return g.name, g.help, "gauge" }
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	values := g.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var lv []string
+		if len(g.labels) > 0 {
+			lv = []string{k}
+		}
+		writeSample(w, g.name, g.labels, lv, "", "", values[k])
+	}
+}
+
+// Гистограмма: распределение наблюдений по корзинам с верхними границами
+type Histogram struct {
+	meta
+	buckets []float64
+	s       series[histogramValue]
+}
+
+type histogramValue struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Регистрирует гистограмму с возрастающими границами корзин в наборе по умолчанию
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{meta: meta{name: name, help: help, labels: labels}, buckets: append([]float64(nil), buckets...)}
+	sort.Float64s(h.buckets)
+	if len(labels) == 0 {
+		h.s.get(&h.meta, nil).counts = make([]uint64, len(h.buckets))
+	}
+	Default.register(h)
+	return h
+}
+
+// Добавляет наблюдение
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.s.mu.Lock()
+	defer h.s.mu.Unlock()
+	hv := h.s.get(&h.meta, labelValues)
+	if hv.counts == nil {
+		hv.counts = make([]uint64, len(h.buckets))
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *Histogram) desc() (string, string, string) { return h.name, h.help, "histogram" }
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.s.mu.Lock()
+	defer h.s.mu.Unlock()
+	h.s.each(func(lv []string, hv *histogramValue) {
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += hv.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, lv, "le", formatFloat(b), float64(cum))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, lv, "le", "+Inf", float64(hv.count))
+		writeSample(w, h.name+"_sum", h.labels, lv, "", "", hv.sum)
+		writeSample(w, h.name+"_count", h.labels, lv, "", "", float64(hv.count))
+	})
+}
+
+// Границы корзин: count значений от start, каждое следующее в factor раз больше
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	out := make([]float64, count)
+	for i := range out {
+		out[i] = start
+		start *= factor
+	}
+	return out
+}
+
+// Выводит одну строку серии; extraName и extraValue - дополнительная метка, например le
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	Default = NewRegistry()
+	requests := NewCounter("test_requests_total", "Requests by code.", "code")
+	requests.Inc("200")
+	requests.Add(2, "200")
+	requests.Inc("500")
+	NewCounter("test_errors_total", "Errors.")
+	inflight := NewGauge("test_inflight", "In-flight\nrequests.")
+	inflight.Add(3)
+	inflight.Add(-1)
+	latency := NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+	NewGaugeVecFunc("test_items", "Items by status.", "status", func() map[string]float64 {
+		return map[string]float64{"queued": 2, `we"ird`: 1}
+	})
+
+	var buf bytes.Buffer
+	if err := Default.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_errors_total Errors.
+# TYPE test_errors_total counter
+test_errors_total 0
+# HELP test_inflight In-flight\nrequests.
+# TYPE test_inflight gauge
+test_inflight 2
+# HELP test_items Items by status.
+# TYPE test_items gauge
+test_items{status="queued"} 2
+test_items{status="we\"ird"} 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 5.55
+test_latency_seconds_count 3
+# HELP test_requests_total Requests by code.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 3
+test_requests_total{code="500"} 1
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	Default.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") || rec.Body.String() != want {
+		t.Fatalf("handler content type %q, body %q", ct, rec.Body.String())
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	Default = NewRegistry()
+	c := NewCounter("test_panics_total", "Counter with a label.", "kind")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for missing label value")
+		}
+	}()
+	c.Inc()
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	got := ExponentialBuckets(0.05, 4, 3)
+	if len(got) != 3 || got[0] != 0.05 || got[1] != 0.2 || got[2] != 0.8 {
+		t.Fatalf("ExponentialBuckets = %v", got)
+	}
+}
@@ -0,0 +1,202 @@
+package mimetype
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Число первых байт, по которым определяется тип содержимого
+const SniffLen = 512
+
+// Тип без уточнения, по которому нельзя выбрать расширение
+const OctetStream = "application/octet-stream"
+
+// Предпочтительные расширения для распространенных типов; для остальных используется пакет mime
+var preferredExt = map[string]string{
+	"application/gzip":             ".gz",
+	"application/x-gzip":           ".gz",
+	"application/json":             ".json",
+	"application/pdf":              ".pdf",
+	"application/zip":              ".zip",
+	"application/x-tar":            ".tar",
+	"application/xml":              ".xml",
+	"application/vnd.rar":          ".rar",
+	"application/x-rar-compressed": ".rar",
+	"application/x-7z-compressed":  ".7z",
+	"audio/mpeg":                   ".mp3",
+	"audio/wave":                   ".wav",
+	"image/bmp":                    ".bmp",
+	"image/gif":                    ".gif",
+	"image/jpeg":                   ".jpg",
+	"image/png":                    ".png",
+	"image/svg+xml":                ".svg",
+	"image/webp":                   ".webp",
+	"text/csv":                     ".csv",
+	"text/html":                    ".html",
+	"text/plain":                   ".txt",
+	"text/xml":                     ".xml",
+	"video/mp4":                    ".mp4",
+	"video/webm":                   ".webm",
+}
+
+// Возвращает тип без параметров в нижнем регистре
+func Normalize(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(mt)
+}
+
+// Определяет тип по первым байтам содержимого
+func Sniff(head []byte) string {
+	if len(head) == 0 {
+		return ""
+	}
+	return Normalize(http.DetectContentType(head))
+}
+
+// Выбирает наиболее точный тип: заявленный сервером, если он конкретнее octet-stream, иначе определенный по содержимому
+func Effective(declared, sniffed string) string {
+	if declared != "" && declared != OctetStream {
+		return declared
+	}
+	return sniffed
+}
+
+// Возвращает расширение с точкой для типа или пустую строку
+func Extension(mediaType string) string {
+	mediaType = Normalize(mediaType)
+	if mediaType == "" || mediaType == OctetStream {
+		return ""
+	}
+	if ext, ok := preferredExt[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// Проверяет шаблоны списка разрешенных типов: type/subtype, type/* или */*
+func ValidatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		typ, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(p)), "/")
+		if !ok || typ == "" || sub == "" || (typ == "*" && sub != "*") {
+			return fmt.Errorf("invalid mime pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// Двоичные форматы, которые определяются по сигнатуре содержимого. Если сервер заявляет такой тип,
+// а по содержимому он не определился, заявленному типу верить нельзя
+var signatureTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/ogg":              true,
+	"application/pdf":              true,
+	"application/postscript":       true,
+	"application/vnd.rar":          true,
+	"application/wasm":             true,
+	"application/x-rar-compressed": true,
+	"application/zip":              true,
+	"audio/aiff":                   true,
+	"audio/basic":                  true,
+	"audio/midi":                   true,
+	"audio/mpeg":                   true,
+	"audio/wav":                    true,
+	"audio/wave":                   true,
+	"font/collection":              true,
+	"font/otf":                     true,
+	"font/ttf":                     true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+	"image/bmp":                    true,
+	"image/gif":                    true,
+	"image/jpeg":                   true,
+	"image/png":                    true,
+	"image/webp":                   true,
+	"image/x-icon":                 true,
+	"video/avi":                    true,
+	"video/mp4":                    true,
+	"video/webm":                   true,
+}
+
+// Синонимы: тип, который определяется по содержимому, и тип, который обычно заявляют серверы
+var aliases = map[string]string{
+	"application/x-gzip":           "application/gzip",
+	"application/x-rar-compressed": "application/vnd.rar",
+	"audio/wave":                   "audio/wav",
+	"audio/x-wav":                  "audio/wav",
+	"text/xml":                     "application/xml",
+}
+
+// Тип содержимого, по которому проверяется список разрешенных типов. Используется тип,
+// определенный по первым байтам; заявленный сервером тип принимается, только если содержимое
+// не дает конкретного типа и не противоречит заявленному: двоичный формат с сигнатурой должен
+// определиться по содержимому, а текстовый тип не может быть у двоичного содержимого
+func Verified(declared, sniffed string) string {
+	declared, sniffed = Normalize(declared), Normalize(sniffed)
+	switch {
+	case declared == "" || declared == OctetStream:
+		return sniffed
+	case sniffed == "":
+		// Пустое содержимое: проверить заявленный тип нечем
+		return declared
+	case signatureTypes[declared]:
+		return sniffed
+	case isText(declared):
+		// Определение текстовых форматов неточное: JSON или SVG определяются как text/plain или text/xml
+		if strings.HasPrefix(sniffed, "text/") {
+			return declared
+		}
+	case sniffed == OctetStream || sniffed == "text/plain":
+		return declared
+	}
+	return sniffed
+}
+
+// Текстовые типы: text/*, JSON, XML и JavaScript
+func isText(t string) bool {
+	return strings.HasPrefix(t, "text/") || strings.HasSuffix(t, "+json") || strings.HasSuffix(t, "+xml") ||
+		t == "application/json" || t == "application/xml" || t == "application/javascript"
+}
+
+// Сообщает, подходит ли тип под один из шаблонов; синонимы, например application/x-gzip
+// и application/gzip, считаются одним типом
+func Match(patterns []string, t string) bool {
+	t = Normalize(t)
+	if t == "" {
+		return false
+	}
+	types := []string{t}
+	if a, ok := aliases[t]; ok {
+		types = append(types, a)
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if a, ok := aliases[p]; ok {
+			p = a
+		}
+		pt, ps, _ := strings.Cut(p, "/")
+		for _, t := range types {
+			typ, sub, _ := strings.Cut(t, "/")
+			if (pt == "*" || pt == typ) && (ps == "*" || ps == sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Сообщает, разрешено ли содержимое списком шаблонов: проверяется тип, подтвержденный содержимым
+func Allowed(patterns []string, declared, sniffed string) bool {
+	return Match(patterns, Verified(declared, sniffed))
+}
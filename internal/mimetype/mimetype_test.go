@@ -0,0 +1,70 @@
+package mimetype
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+var (
+	pngHead = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	elfHead = []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00>\x00")
+	peHead  = append([]byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00"), make([]byte, 48)...)
+)
+
+func gzipHead(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		declared string
+		body     []byte
+		want     bool
+	}{
+		{"png", []string{"image/*"}, "image/png", pngHead, true},
+		{"spoofed png header on ELF", []string{"image/*"}, "image/png", elfHead, false},
+		{"spoofed png header on PE", []string{"image/png"}, "image/png", peHead, false},
+		{"spoofed text header on executable", []string{"text/*"}, "text/plain", elfHead, false},
+		{"spoofed json header on executable", []string{"application/json"}, "application/json", peHead, false},
+		{"png without header", []string{"image/png"}, "", pngHead, true},
+		{"png declared as octet-stream", []string{"image/png"}, "application/octet-stream", pngHead, true},
+		{"json sniffed as text", []string{"application/json"}, "application/json; charset=utf-8", []byte(`{"a": 1}`), true},
+		{"svg sniffed as xml", []string{"image/svg+xml"}, "image/svg+xml", []byte(`<?xml version="1.0"?><svg/>`), true},
+		{"gzip alias", []string{"application/gzip"}, "application/gzip", gzipHead(t), true},
+		{"gzip declared as x-gzip", []string{"application/x-gzip"}, "application/x-gzip", gzipHead(t), true},
+		{"binary without signature", []string{"application/x-msdownload"}, "application/x-msdownload", peHead, true},
+		{"binary not in allowlist", []string{"image/*"}, "application/x-msdownload", peHead, false},
+		{"empty body", []string{"text/csv"}, "text/csv", nil, true},
+		{"html claimed as image", []string{"image/*"}, "image/jpeg", []byte("<html><body>x</body></html>"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sniffed := Sniff(tt.body)
+			if got := Allowed(tt.patterns, tt.declared, sniffed); got != tt.want {
+				t.Fatalf("Allowed(%v, %q, sniffed %q) = %v, want %v (verified %q)",
+					tt.patterns, tt.declared, sniffed, got, tt.want, Verified(tt.declared, sniffed))
+			}
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := map[string]string{
+		"image/png":                 ".png",
+		"text/plain; charset=utf-8": ".txt",
+		"application/octet-stream":  "",
+		"":                          "",
+	}
+	for mt, want := range tests {
+		if got := Extension(mt); got != want {
+			t.Errorf("Extension(%q) = %q, want %q", mt, got, want)
+		}
+	}
+}
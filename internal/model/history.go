@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Actor представляет источник изменения состояния
+type Actor string
+
+const (
+	// ActorAPI представляет изменение через HTTP API
+	ActorAPI Actor = "api"
+	// ActorWorker представляет изменение воркером при обработке элемента
+	ActorWorker Actor = "worker"
+	// ActorRecovery представляет изменение при восстановлении после перезапуска
+	ActorRecovery Actor = "recovery"
+	// ActorScheduler представляет изменение планировщиком по наступлении времени запуска или по расписанию
+	ActorScheduler Actor = "scheduler"
+	// ActorJanitor представляет изменение при очистке задач с истекшим сроком
+	ActorJanitor Actor = "janitor"
+)
+
+// Transition представляет запись истории изменения статуса задачи или элемента
+type Transition struct {
+	Seq     int64     `json:"seq"`
+	At      time.Time `json:"at"`
+	Actor   Actor     `json:"actor"`
+	ItemIdx *int      `json:"item_idx,omitempty"` // nil для переходов самой задачи
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to"`
+	Attempt int       `json:"attempt,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
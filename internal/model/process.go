@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// StepType представляет тип шага обработки загруженного файла
+type StepType string
+
+const (
+	// StepExtract распаковывает zip, tar или tar.gz в подкаталог рядом с файлом
+	StepExtract StepType = "extract"
+	// StepGunzip распаковывает gzip; следующие шаги работают с распакованным файлом
+	StepGunzip StepType = "gunzip"
+	// StepCommand запускает настроенную локальную команду с путем к файлу
+	StepCommand StepType = "command"
+)
+
+// StepStatus представляет результат шага обработки
+type StepStatus string
+
+const (
+	// StepStatusOK представляет успешно выполненный шаг
+	StepStatusOK StepStatus = "ok"
+	// StepStatusFailed представляет шаг, завершившийся ошибкой
+	StepStatusFailed StepStatus = "failed"
+)
+
+// ProcessStep представляет шаг обработки, заданный при создании задачи
+type ProcessStep struct {
+	Type    StepType `json:"type"`
+	Command string   `json:"command,omitempty"` // имя команды из PROCESS_COMMANDS для шага command
+}
+
+// StepResult представляет результат выполнения шага обработки элемента
+type StepResult struct {
+	Type       StepType   `json:"type"`
+	Command    string     `json:"command,omitempty"`
+	Status     StepStatus `json:"status"`
+	Files      []string   `json:"files,omitempty"` // созданные файлы в приемнике задачи
+	Log        string     `json:"log,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at"`
+}
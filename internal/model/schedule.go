@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// ScheduleID представляет идентификатор расписания
+type ScheduleID string
+
+// CatchUpPolicy определяет, что делать со срабатываниями, пропущенными во время простоя
+type CatchUpPolicy string
+
+const (
+	// CatchUpSkip пропускает просроченные срабатывания
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpOnce создает одну задачу за все просроченные срабатывания
+	CatchUpOnce CatchUpPolicy = "once"
+	// CatchUpAll создает задачу на каждое просроченное срабатывание
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+// Schedule представляет расписание регулярных загрузок
+type Schedule struct {
+	ID       ScheduleID  `json:"id"`
+	URLs     []Source    `json:"urls,omitempty"`
+	Manifest string      `json:"manifest,omitempty"` // URL манифеста вместо списка urls
+	Cron     string      `json:"cron"`
+	Timezone string      `json:"timezone,omitempty"` // имя зоны IANA, по умолчанию UTC
+	Options  TaskOptions `json:"options"`
+	// Retain - сколько последних задач расписания хранить; 0 - все
+	Retain    int           `json:"retain,omitempty"`
+	CatchUp   CatchUpPolicy `json:"catch_up,omitempty"`
+	Paused    bool          `json:"paused,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	LastRunAt *time.Time    `json:"last_run_at,omitempty"`
+	NextRunAt *time.Time    `json:"next_run_at,omitempty"`
+	LastError string        `json:"last_error,omitempty"`
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Source описывает элемент создаваемой задачи: основной URL, равноценные зеркала и контрольную сумму.
+// В JSON задается строкой URL или объектом {"url": ..., "mirrors": [...], "checksum": "sha256:..."}
+type Source struct {
+	URL      string   `json:"url"`
+	Mirrors  []string `json:"mirrors,omitempty"`
+	Checksum string   `json:"checksum,omitempty"`
+}
+
+// Представление Source в виде объекта
+type sourceObject Source
+
+func (s *Source) UnmarshalJSON(data []byte) error {
+	if b := bytes.TrimSpace(data); len(b) > 0 && b[0] == '"' {
+		*s = Source{}
+		return json.Unmarshal(b, &s.URL)
+	}
+	return json.Unmarshal(data, (*sourceObject)(s))
+}
+
+// Источник без зеркал и контрольной суммы сериализуется строкой
+func (s Source) MarshalJSON() ([]byte, error) {
+	if len(s.Mirrors) == 0 && s.Checksum == "" {
+		return json.Marshal(s.URL)
+	}
+	return json.Marshal(sourceObject(s))
+}
+
+// Sources создает список источников без зеркал
+func Sources(urls ...string) []Source {
+	out := make([]Source, len(urls))
+	for i, u := range urls {
+		out[i] = Source{URL: u}
+	}
+	return out
+}
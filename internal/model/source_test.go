@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSourceJSON(t *testing.T) {
+	var got []Source
+	in := `["https://a.example/x", {"url": "https://b.example/y", "mirrors": ["https://m.example/y"], "checksum": "sha256:ab"}]`
+	if err := json.Unmarshal([]byte(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []Source{
+		{URL: "https://a.example/x"},
+		{URL: "https://b.example/y", Mirrors: []string{"https://m.example/y"}, Checksum: "sha256:ab"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unmarshal = %+v, want %+v", got, want)
+	}
+	out, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `["https://a.example/x",{"url":"https://b.example/y","mirrors":["https://m.example/y"],"checksum":"sha256:ab"}]` {
+		t.Fatalf("Marshal = %s", out)
+	}
+}
+
+func TestItemSourceURL(t *testing.T) {
+	it := Item{URL: "u", Mirrors: []string{"m1", "m2"}}
+	for source, want := range map[int]string{0: "u", 1: "m1", 2: "m2", 3: "u", -1: "u"} {
+		it.Source = source
+		if got := it.SourceURL(); got != want {
+			t.Errorf("SourceURL with Source %d = %q, want %q", source, got, want)
+		}
+	}
+}
@@ -16,6 +16,10 @@ type TaskID string
 type TaskStatus string
 
 const (
+	// TaskStatusScheduled представляет статус задачи, ожидающей времени запуска
+	TaskStatusScheduled TaskStatus = "scheduled"
+	// TaskStatusBlocked представляет статус задачи, ожидающей завершения задач из depends_on
+	TaskStatusBlocked TaskStatus = "blocked"
 	// TaskStatusPending представляет статус задачи в ожидании
 	TaskStatusPending TaskStatus = "pending"
 	TaskStatusRunning TaskStatus = "running"
@@ -23,40 +27,134 @@ const (
 	TaskStatusCompleted TaskStatus = "completed"
 	// TaskStatusFailed представляет статус задачи в состоянии ошибки
 	TaskStatusFailed TaskStatus = "failed"
+	// TaskStatusCanceled представляет статус отмененной задачи
+	TaskStatusCanceled TaskStatus = "canceled"
 )
 
+// IsTerminal сообщает, что задача больше не будет обрабатываться
+func (s TaskStatus) IsTerminal() bool {
+	return s == TaskStatusCompleted || s == TaskStatusFailed || s == TaskStatusCanceled
+}
+
 type ItemStatus string
 
 const (
+	// ItemStatusScheduled представляет статус элемента задачи, время запуска которой не наступило
+	ItemStatusScheduled ItemStatus = "scheduled"
+	// ItemStatusBlocked представляет статус элемента задачи, ожидающей зависимостей
+	ItemStatusBlocked ItemStatus = "blocked"
 	// ItemStatusQueued представляет статус элемента в ожидании
 	ItemStatusQueued ItemStatus = "queued"
 	// ItemStatusDownloading представляет статус элемента в процессе загрузки
 	ItemStatusDownloading ItemStatus = "downloading"
+	// ItemStatusProcessing представляет статус элемента, файл которого загружен и обрабатывается
+	ItemStatusProcessing ItemStatus = "processing"
 	// ItemStatusDone представляет статус элемента в завершенном состоянии
 	ItemStatusDone ItemStatus = "done"
 	// ItemStatusError представляет статус элемента в состоянии ошибки
 	ItemStatusError ItemStatus = "error"
+	// ItemStatusCanceled представляет статус элемента отмененной задачи
+	ItemStatusCanceled ItemStatus = "canceled"
 )
 
 // Task представляет задачу
 type Task struct {
-	ID        TaskID     `json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	Status    TaskStatus `json:"status"`
-	Items     []Item     `json:"items"`
+	ID         TaskID      `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Status     TaskStatus  `json:"status"`
+	Options    TaskOptions `json:"options"`
+	ScheduleID ScheduleID  `json:"schedule_id,omitempty"` // расписание, создавшее задачу
+	Manifest   string      `json:"manifest,omitempty"`    // URL манифеста, из которого получены элементы
+	Error      string      `json:"error,omitempty"`       // ошибка задачи, не относящаяся к элементам
+	Items      []Item      `json:"items"`
+}
+
+// TaskOptions представляет параметры задачи, заданные при создании
+type TaskOptions struct {
+	// NotBefore - момент, раньше которого загрузка не начинается
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// ExpiresAt - момент, после которого задача и ее файлы удаляются независимо от статуса
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ForceRefresh - загружать файлы заново, даже если содержимое по этим URL уже было получено
+	ForceRefresh bool `json:"force_refresh,omitempty"`
+	// Naming - стратегия именования файлов: hashed (по умолчанию), basename, content_disposition, template
+	Naming         string `json:"naming,omitempty"`
+	NamingTemplate string `json:"naming_template,omitempty"`
+	// AllowedMIME - разрешенные типы содержимого (type/subtype, type/*); пустой список разрешает все
+	AllowedMIME []string `json:"allowed_mime,omitempty"`
+	// MaxBytes - общий бюджет байт на все файлы задачи; 0 - без ограничения
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// Sink - имя приемника файлов; пустое значение - локальный DATA_DIR
+	Sink string `json:"sink,omitempty"`
+	// Process - шаги обработки каждого файла после загрузки
+	Process []ProcessStep `json:"process,omitempty"`
+	// DependsOn - задачи, которые должны завершиться успешно до начала загрузки
+	DependsOn []TaskID `json:"depends_on,omitempty"`
+	// DependencyPolicy - что делать, если зависимость не завершилась успешно: fail (по умолчанию) или cancel
+	DependencyPolicy DependencyPolicy `json:"dependency_policy,omitempty"`
+	// MaxRedirects - максимальное число перенаправлений (0 - не следовать); nil - REDIRECT_MAX
+	MaxRedirects *int `json:"max_redirects,omitempty"`
+	// AllowDowngrade - разрешить перенаправление с https на http; nil - REDIRECT_ALLOW_DOWNGRADE
+	AllowDowngrade *bool `json:"allow_downgrade,omitempty"`
+	// Ограничения одной попытки загрузки элемента; nil - значения по умолчанию, 0 - без ограничения.
+	// IdleTimeout - время без новых данных, MinThroughput - минимальная скорость (байт/с)
+	// за ThroughputWindow, ItemDeadline - общее время попытки
+	IdleTimeout      *Duration `json:"idle_timeout,omitempty"`
+	MinThroughput    *int64    `json:"min_throughput,omitempty"`
+	ThroughputWindow *Duration `json:"throughput_window,omitempty"`
+	ItemDeadline     *Duration `json:"item_deadline,omitempty"`
 }
 
+// DependencyPolicy определяет судьбу задачи при неудаче ее зависимости
+type DependencyPolicy string
+
+const (
+	// DependencyFail завершает задачу статусом failed
+	DependencyFail DependencyPolicy = "fail"
+	// DependencyCancel отменяет задачу
+	DependencyCancel DependencyPolicy = "cancel"
+)
+
 // Item представляет элемент задачи
 type Item struct {
-	URL            string     `json:"url"`
-	FileName       string     `json:"file_name"`
-	Status         ItemStatus `json:"status"`
-	Attempts       int        `json:"attempts"`
-	ErrorMessage   string     `json:"error_message,omitempty"`
-	SizeExpected   int64      `json:"size_expected,omitempty"`
-	SizeDownloaded int64      `json:"size_downloaded"`
-	StartedAt      *time.Time `json:"started_at,omitempty"`
-	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	URL            string       `json:"url"`
+	Mirrors        []string     `json:"mirrors,omitempty"`        // равноценные адреса на случай сбоев URL
+	Source         int          `json:"source,omitempty"`         // текущий адрес: 0 - URL, n - Mirrors[n-1]
+	ServedBy       string       `json:"served_by,omitempty"`      // адрес, с которого получено содержимое
+	FinalURL       string       `json:"final_url,omitempty"`      // адрес ответа после перенаправлений
+	RedirectChain  []Redirect   `json:"redirect_chain,omitempty"` // перенаправления от адреса источника к FinalURL
+	Validator      string       `json:"validator,omitempty"`      // ETag или Last-Modified незавершенной части
+	Checksum       string       `json:"checksum,omitempty"`       // ожидаемая контрольная сумма: "sha256:<hex>"
+	FileName       string       `json:"file_name"`
+	Status         ItemStatus   `json:"status"`
+	Attempts       int          `json:"attempts"`
+	ErrorMessage   string       `json:"error_message,omitempty"`
+	SizeExpected   int64        `json:"size_expected,omitempty"`
+	SizeDownloaded int64        `json:"size_downloaded"`
+	ContentType    string       `json:"content_type,omitempty"` // тип из заголовка Content-Type
+	SniffedType    string       `json:"sniffed_type,omitempty"` // тип, определенный по первым байтам
+	SHA256         string       `json:"sha256,omitempty"`
+	Reused         bool         `json:"reused,omitempty"`    // содержимое взято из ранее загруженного файла
+	Unchanged      bool         `json:"unchanged,omitempty"` // источник подтвердил, что содержимое не изменилось
+	Location       string       `json:"location,omitempty"`  // адрес файла во внешнем приемнике
+	Steps          []StepResult `json:"steps,omitempty"`     // результаты шагов обработки
+	StartedAt      *time.Time   `json:"started_at,omitempty"`
+	CompletedAt    *time.Time   `json:"completed_at,omitempty"`
+}
+
+// Redirect - перенаправление на пути к содержимому: адрес и код его ответа
+type Redirect struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// SourceURL возвращает адрес, с которого загружается элемент
+func (it *Item) SourceURL() string {
+	if it.Source > 0 && it.Source <= len(it.Mirrors) {
+		return it.Mirrors[it.Source-1]
+	}
+	return it.URL
 }
 
 // Возвращает детерминированное имя файла из URL, сохраняя расширение, если возможно
@@ -0,0 +1,202 @@
+package naming
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"taskservice/internal/mimetype"
+	"taskservice/internal/model"
+)
+
+// Strategy определяет, как формируется имя файла в DATA_DIR
+type Strategy string
+
+const (
+	// Hashed - хеш URL с расширением (поведение по умолчанию)
+	Hashed Strategy = "hashed"
+	// Basename - последний сегмент пути URL
+	Basename Strategy = "basename"
+	// ContentDisposition - имя из заголовка Content-Disposition, иначе последний сегмент пути URL
+	ContentDisposition Strategy = "content_disposition"
+	// Template - шаблон с подстановками, например {task_id}/{host}/{path}
+	Template Strategy = "template"
+)
+
+// Максимальная длина одного сегмента пути в байтах
+const maxSegment = 200
+
+// Данные для формирования имени
+type Input struct {
+	TaskID          string
+	Index           int
+	URL             string
+	DispositionName string // имя из Content-Disposition, если известно
+	ContentType     string // тип содержимого для выбора расширения, если в имени его нет
+}
+
+// Проверяет стратегию и шаблон
+func Validate(s Strategy, template string) error {
+	switch s {
+	case "", Hashed, Basename, ContentDisposition:
+		return nil
+	case Template:
+		if strings.TrimSpace(template) == "" {
+			return fmt.Errorf("naming template is required")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown naming strategy %q", s)
+	}
+}
+
+// Возвращает безопасное относительное имя файла для стратегии
+func Name(s Strategy, template string, in Input) string {
+	hashed := withExtension(model.DeriveDeterministicFileName(in.URL), in.ContentType)
+	var name string
+	switch s {
+	case Basename:
+		name = urlBase(in.URL)
+	case ContentDisposition:
+		name = path.Base("/" + in.DispositionName)
+		if in.DispositionName == "" {
+			name = urlBase(in.URL)
+		}
+	case Template:
+		name = expand(template, in, hashed)
+	default:
+		return hashed
+	}
+	if name = Sanitize(name); name == "" {
+		return hashed
+	}
+	return withExtension(name, in.ContentType)
+}
+
+// Добавляет расширение по типу содержимого, если у имени его нет
+func withExtension(name, contentType string) string {
+	if path.Ext(path.Base(name)) != "" {
+		return name
+	}
+	return name + mimetype.Extension(contentType)
+}
+
+// Извлекает имя файла из заголовка Content-Disposition
+func DispositionFileName(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
+
+// Приводит путь к безопасному относительному виду: убирает "..", абсолютные пути,
+// управляющие и зарезервированные символы, ограничивает длину сегментов
+func Sanitize(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	var segs []string
+	for _, seg := range strings.Split(p, "/") {
+		seg = sanitizeSegment(seg)
+		if seg == "" || seg == "." || seg == ".." {
+			continue
+		}
+		segs = append(segs, seg)
+	}
+	return strings.Join(segs, "/")
+}
+
+// Добавляет к имени суффикс -n перед расширением для разрешения конфликтов
+func WithSuffix(name string, n int) string {
+	if n == 0 {
+		return name
+	}
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	return dir + strings.TrimSuffix(base, ext) + "-" + strconv.Itoa(n) + ext
+}
+
+// Последний сегмент пути URL
+func urlBase(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	base := path.Base(u.Path)
+	if base == "/" || base == "." {
+		return ""
+	}
+	return base
+}
+
+// Подставляет значения в шаблон
+func expand(template string, in Input, hashed string) string {
+	var host, p string
+	if u, err := url.Parse(in.URL); err == nil {
+		host = u.Hostname()
+		p = u.Path
+	}
+	base := urlBase(in.URL)
+	ext := path.Ext(base)
+	r := strings.NewReplacer(
+		"{task_id}", in.TaskID,
+		"{index}", strconv.Itoa(in.Index),
+		"{host}", host,
+		"{path}", p,
+		"{basename}", base,
+		"{name}", strings.TrimSuffix(base, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{hash}", hashed,
+	)
+	return r.Replace(template)
+}
+
+// Очищает один сегмент пути
+func sanitizeSegment(seg string) string {
+	var b strings.Builder
+	for _, r := range seg {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			continue
+		case strings.ContainsRune(`<>:"|?*`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s := strings.TrimSpace(b.String())
+	// Имена, оканчивающиеся точкой или пробелом, и скрытые служебные имена недопустимы
+	s = strings.TrimRight(s, ". ")
+	if strings.HasPrefix(s, ".") {
+		s = "_" + s[1:]
+	}
+	if len(s) > maxSegment {
+		s = truncate(s, maxSegment)
+	}
+	return s
+}
+
+// Обрезает строку до n байт, не разрывая UTF-8 символ и сохраняя расширение
+func truncate(s string, n int) string {
+	ext := path.Ext(s)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	keep := n - len(ext)
+	cut := 0
+	for i := range s {
+		if i > keep {
+			break
+		}
+		cut = i
+	}
+	return s[:cut] + ext
+}
@@ -0,0 +1,124 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		template string
+		in       Input
+		want     string
+	}{
+		{"basename", Basename, "", Input{URL: "https://example.com/files/report.pdf?x=1"}, "report.pdf"},
+		{"basename adds extension", Basename, "", Input{URL: "https://example.com/download", ContentType: "application/pdf"}, "download.pdf"},
+		{"disposition", ContentDisposition, "", Input{URL: "https://example.com/get?id=1", DispositionName: "Q3 report.xlsx"}, "Q3 report.xlsx"},
+		{"disposition with path", ContentDisposition, "", Input{URL: "https://example.com/get", DispositionName: "../../etc/passwd"}, "passwd"},
+		{"disposition falls back to url", ContentDisposition, "", Input{URL: "https://example.com/a/b.txt"}, "b.txt"},
+		{"template", Template, "{task_id}/{host}/{index}-{name}.{ext}", Input{TaskID: "t1", Index: 2, URL: "https://example.com/dir/data.csv"}, "t1/example.com/2-data.csv"},
+		{"template path", Template, "{host}{path}", Input{URL: "https://example.com/a/../../b/c.txt"}, "example.com/a/b/c.txt"},
+		{"reserved characters", Basename, "", Input{URL: "https://example.com/a%3Cb%3E%3A%7C.txt"}, "a_b___.txt"},
+		{"hidden name", Basename, "", Input{URL: "https://example.com/.htaccess"}, "_htaccess"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Name(tt.strategy, tt.template, tt.in); got != tt.want {
+				t.Fatalf("Name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameFallsBackToHashed(t *testing.T) {
+	in := Input{URL: "https://example.com/", ContentType: "application/json"}
+	hashed := Name(Hashed, "", in)
+	if len(hashed) != 32+len(".json") || !strings.HasSuffix(hashed, ".json") {
+		t.Fatalf("hashed name %q", hashed)
+	}
+	for _, s := range []Strategy{Basename, ContentDisposition} {
+		if got := Name(s, "", in); got != hashed {
+			t.Errorf("%s for URL without file name = %q, want hashed %q", s, got, hashed)
+		}
+	}
+	if got := Name(Template, "../..", in); got != hashed {
+		t.Errorf("template expanding to an empty path = %q, want hashed %q", got, hashed)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"a/b/c.txt":         "a/b/c.txt",
+		"/etc/passwd":       "etc/passwd",
+		"../../x":           "x",
+		`..\..\win\x.exe`:   "win/x.exe",
+		"a/./b//c":          "a/b/c",
+		"name. ":            "name",
+		"tab\there\x00.txt": "tabhere.txt",
+		"C:/x":              "C_/x",
+	}
+	for in, want := range tests {
+		if got := Sanitize(in); got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSanitizeTruncatesLongSegments(t *testing.T) {
+	long := strings.Repeat("я", 150) + ".txt" // 304 байта
+	got := Sanitize(long)
+	if len(got) > maxSegment || !strings.HasSuffix(got, ".txt") || !utf8.ValidString(got) {
+		t.Fatalf("Sanitize of a long name = %q (%d bytes)", got, len(got))
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{"report.pdf", 0, "report.pdf"},
+		{"report.pdf", 1, "report-1.pdf"},
+		{"dir/archive.tar", 12, "dir/archive-12.tar"},
+		{"README", 2, "README-2"},
+		{"dir.v2/file", 3, "dir.v2/file-3"},
+	}
+	for _, tt := range tests {
+		if got := WithSuffix(tt.name, tt.n); got != tt.want {
+			t.Errorf("WithSuffix(%q, %d) = %q, want %q", tt.name, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDispositionFileName(t *testing.T) {
+	tests := map[string]string{
+		`attachment; filename="a b.txt"`:                                  "a b.txt",
+		`attachment; filename*=UTF-8''%D0%BE%D1%82%D1%87%D0%B5%D1%82.pdf`: "отчет.pdf",
+		"inline":                             "",
+		"":                                   "",
+		`attachment; filename="unterminated`: "",
+	}
+	for header, want := range tests {
+		if got := DispositionFileName(header); got != want {
+			t.Errorf("DispositionFileName(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(Template, " "); err == nil {
+		t.Error("template strategy without template accepted")
+	}
+	if err := Validate("random", ""); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	for _, s := range []Strategy{"", Hashed, Basename, ContentDisposition} {
+		if err := Validate(s, ""); err != nil {
+			t.Errorf("Validate(%q) = %v", s, err)
+		}
+	}
+}
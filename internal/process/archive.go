@@ -0,0 +1,223 @@
+package process
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"taskservice/internal/naming"
+)
+
+// ErrUnsupportedArchive возвращается, если формат файла не поддерживается шагом
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// Распаковывает zip, tar или tar.gz в подкаталог с именем архива
+func (r *Runner) extract(ctx context.Context, input, name, workDir string) (Result, error) {
+	var res Result
+	f, err := os.Open(input)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return res, err
+	}
+
+	res.Folder = Stem(name)
+	ex := &extractor{ctx: ctx, dir: filepath.Join(workDir, "out"), limit: r.cfg.MaxBytes, remaining: r.cfg.MaxBytes,
+		maxEntries: r.cfg.MaxEntries}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		fi, err := f.Stat()
+		if err != nil {
+			return res, err
+		}
+		err = ex.zip(f, fi.Size())
+		res.Outputs = ex.outputs
+		return res, err
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return res, err
+		}
+		defer gz.Close()
+		err = ex.tar(gz)
+		res.Outputs = ex.outputs
+		return res, err
+	case len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		err = ex.tar(f)
+		res.Outputs = ex.outputs
+		return res, err
+	default:
+		return res, ErrUnsupportedArchive
+	}
+}
+
+// Распаковывает gzip в файл без расширения .gz; следующие шаги работают с ним
+func (r *Runner) gunzip(input, name, workDir string) (Result, error) {
+	var res Result
+	f, err := os.Open(input)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		if errors.Is(err, gzip.ErrHeader) {
+			return res, fmt.Errorf("%w: not a gzip file", ErrUnsupportedArchive)
+		}
+		return res, err
+	}
+	defer gz.Close()
+	outName := gunzipName(name)
+	out := Output{Path: filepath.Join(workDir, outName), Name: outName}
+	dst, err := os.Create(out.Path)
+	if err != nil {
+		return res, err
+	}
+	remaining := r.cfg.MaxBytes
+	err = copyLimited(dst, gz, &remaining, r.cfg.MaxBytes)
+	if cerr := dst.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return res, err
+	}
+	res.Outputs = []Output{out}
+	res.Next = &out
+	return res, nil
+}
+
+// Имя распакованного gzip файла: без .gz, .tgz становится .tar
+func gunzipName(name string) string {
+	base := filepath.Base(name)
+	ext := filepath.Ext(base)
+	switch {
+	case strings.EqualFold(ext, ".gz") && len(base) > len(ext):
+		return strings.TrimSuffix(base, ext)
+	case strings.EqualFold(ext, ".tgz") && len(base) > len(ext):
+		return strings.TrimSuffix(base, ext) + ".tar"
+	default:
+		return base + ".out"
+	}
+}
+
+// Распаковка архива в каталог
+type extractor struct {
+	ctx       context.Context
+	dir       string
+	limit     int64
+	remaining int64
+	// Число просмотренных элементов и его ограничение; считаются и пропускаемые элементы,
+	// чтобы архив из множества пустых каталогов тоже был ограничен
+	entries    int
+	maxEntries int
+	outputs    []Output
+}
+
+// Учитывает очередной элемент архива
+func (e *extractor) next() error {
+	if e.entries++; e.maxEntries > 0 && e.entries > e.maxEntries {
+		return fmt.Errorf("%w (%d)", ErrTooManyEntries, e.maxEntries)
+	}
+	return nil
+}
+
+func (e *extractor) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		if err := e.next(); err != nil {
+			return err
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		// Заявленный размер элемента уже больше остатка: не распаковываем его
+		if e.limit > 0 && zf.UncompressedSize64 > uint64(e.remaining) {
+			return fmt.Errorf("extract %s: %w (%d bytes)", zf.Name, ErrTooLarge, e.limit)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = e.file(zf.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.next(); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if e.limit > 0 && hdr.Size > e.remaining {
+			return fmt.Errorf("extract %s: %w (%d bytes)", hdr.Name, ErrTooLarge, e.limit)
+		}
+		if err := e.file(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Записывает элемент архива; пути очищаются, чтобы файлы не выходили за каталог распаковки
+func (e *extractor) file(entry string, r io.Reader) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	rel := naming.Sanitize(entry)
+	if rel == "" {
+		return nil
+	}
+	p := filepath.Join(e.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	err = copyLimited(f, r, &e.remaining, e.limit)
+	if cerr := f.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("extract %s: %w", entry, err)
+	}
+	for i := range e.outputs {
+		if e.outputs[i].Path == p {
+			return nil
+		}
+	}
+	e.outputs = append(e.outputs, Output{Path: p, Name: rel})
+	return nil
+}
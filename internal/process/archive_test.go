@@ -0,0 +1,178 @@
+package process
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+// Записывает архив во временный файл и возвращает путь
+func writeArchive(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tar.gz с одним файлом
+func tarGz(t *testing.T, name string, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(body)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return gzipData(t, buf.Bytes())
+}
+
+func runStep(t *testing.T, cfg Config, step model.StepType, input, name string) (Result, string, error) {
+	t.Helper()
+	work := filepath.Join(t.TempDir(), "work")
+	res, err := New(cfg).Run(context.Background(), model.ProcessStep{Type: step}, input, name, work)
+	return res, work, err
+}
+
+// Суммарный размер файлов в каталоге
+func dirSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	var total int64
+	filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+func TestExtractZipBomb(t *testing.T) {
+	const limit = 1 << 20
+	input := writeArchive(t, "bomb.zip", zipArchive(t, map[string][]byte{"zeros.bin": make([]byte, 64<<20)}))
+	_, work, err := runStep(t, Config{MaxBytes: limit}, model.StepExtract, input, "bomb.zip")
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("extract error %v, want ErrTooLarge", err)
+	}
+	if n := dirSize(t, work); n > limit {
+		t.Fatalf("%d bytes written, limit %d", n, limit)
+	}
+}
+
+func TestExtractTarGzBomb(t *testing.T) {
+	const limit = 1 << 20
+	input := writeArchive(t, "bomb.tar.gz", tarGz(t, "zeros.bin", make([]byte, 16<<20)))
+	_, work, err := runStep(t, Config{MaxBytes: limit}, model.StepExtract, input, "bomb.tar.gz")
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("extract error %v, want ErrTooLarge", err)
+	}
+	if n := dirSize(t, work); n > limit {
+		t.Fatalf("%d bytes written, limit %d", n, limit)
+	}
+}
+
+func TestGunzipBomb(t *testing.T) {
+	const limit = 1 << 20
+	input := writeArchive(t, "bomb.gz", gzipData(t, make([]byte, 64<<20)))
+	_, work, err := runStep(t, Config{MaxBytes: limit}, model.StepGunzip, input, "bomb.gz")
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("gunzip error %v, want ErrTooLarge", err)
+	}
+	// Распаковка останавливается сразу после превышения лимита
+	if n := dirSize(t, work); n > limit+1 {
+		t.Fatalf("%d bytes written, limit %d", n, limit)
+	}
+}
+
+func TestExtractTooManyEntries(t *testing.T) {
+	files := make(map[string][]byte)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%d.txt", i)] = []byte("x")
+	}
+	input := writeArchive(t, "many.zip", zipArchive(t, files))
+	if _, _, err := runStep(t, Config{MaxEntries: 10}, model.StepExtract, input, "many.zip"); !errors.Is(err, ErrTooManyEntries) {
+		t.Fatalf("extract error %v, want ErrTooManyEntries", err)
+	}
+	if _, _, err := runStep(t, Config{MaxEntries: 20}, model.StepExtract, input, "many.zip"); err != nil {
+		t.Fatalf("extract within the limit: %v", err)
+	}
+}
+
+func TestExtractPathTraversal(t *testing.T) {
+	input := writeArchive(t, "evil.zip", zipArchive(t, map[string][]byte{
+		"../../escape.txt": []byte("a"),
+		"/etc/abs.txt":     []byte("b"),
+		"dir/../../up.txt": []byte("c"),
+		`..\win.txt`:       []byte("d"),
+	}))
+	res, work, err := runStep(t, Config{}, model.StepExtract, input, "evil.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := filepath.Abs(filepath.Join(work, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Outputs) != 4 {
+		t.Fatalf("outputs %+v, want 4 files", res.Outputs)
+	}
+	for _, o := range res.Outputs {
+		p, err := filepath.Abs(o.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rel, err := filepath.Rel(out, p); err != nil || strings.HasPrefix(rel, "..") {
+			t.Fatalf("output %s is outside of %s", p, out)
+		}
+	}
+	// Ничего не записано рядом с рабочим каталогом
+	entries, err := os.ReadDir(filepath.Dir(work))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("files next to the work dir: %v", entries)
+	}
+}
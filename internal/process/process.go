@@ -0,0 +1,252 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"taskservice/internal/model"
+	"taskservice/internal/naming"
+)
+
+// Максимальный размер сохраняемого журнала шага
+const maxLogBytes = 16 << 10
+
+var (
+	// ErrUnknownStep возвращается для шага неизвестного типа
+	ErrUnknownStep = errors.New("unknown process step")
+	// ErrUnknownCommand возвращается для команды, которой нет в конфигурации
+	ErrUnknownCommand = errors.New("unknown process command")
+	// ErrTooLarge возвращается, если шаг создает больше данных, чем разрешено
+	ErrTooLarge = errors.New("process output too large")
+	// ErrTooManyEntries возвращается, если в архиве больше элементов, чем разрешено
+	ErrTooManyEntries = errors.New("too many archive entries")
+)
+
+// Параметры выполнения шагов
+type Config struct {
+	// Разрешенные команды: имя -> программа и аргументы; путь к файлу добавляется последним аргументом
+	Commands map[string][]string
+	// Ограничение времени выполнения команды; 0 - без ограничения
+	Timeout time.Duration
+	// Ограничение суммарного размера файлов, создаваемых одним шагом; 0 - без ограничения
+	MaxBytes int64
+	// Ограничение числа элементов распаковываемого архива; 0 - без ограничения
+	MaxEntries int
+}
+
+// Выполняет шаги обработки файлов
+type Runner struct {
+	cfg Config
+}
+
+// Создает Runner
+func New(cfg Config) *Runner {
+	return &Runner{cfg: cfg}
+}
+
+// Созданный шагом файл
+type Output struct {
+	Path string // путь в рабочем каталоге шага
+	Name string // имя относительно Folder, через "/"
+}
+
+// Результат шага
+type Result struct {
+	// Folder - подкаталог для результатов рядом с исходным файлом; пустой - результаты лежат рядом с ним
+	Folder  string
+	Outputs []Output
+	// Next - файл, с которым работают следующие шаги; пустой, если шаг не меняет входной файл
+	Next *Output
+	Log  string
+}
+
+// Проверяет шаги задачи
+func (r *Runner) Validate(steps []model.ProcessStep) error {
+	for i, s := range steps {
+		switch s.Type {
+		case model.StepExtract, model.StepGunzip:
+		case model.StepCommand:
+			if _, ok := r.cfg.Commands[s.Command]; !ok {
+				return fmt.Errorf("step %d: %w %q", i, ErrUnknownCommand, s.Command)
+			}
+		default:
+			return fmt.Errorf("step %d: %w %q", i, ErrUnknownStep, s.Type)
+		}
+	}
+	return nil
+}
+
+// Выполняет шаг над файлом input с именем name; результаты пишутся в каталог workDir
+func (r *Runner) Run(ctx context.Context, step model.ProcessStep, input, name, workDir string) (Result, error) {
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		return Result{}, err
+	}
+	switch step.Type {
+	case model.StepExtract:
+		return r.extract(ctx, input, name, workDir)
+	case model.StepGunzip:
+		return r.gunzip(input, name, workDir)
+	case model.StepCommand:
+		return r.command(ctx, step.Command, input, name, workDir)
+	default:
+		return Result{}, fmt.Errorf("%w %q", ErrUnknownStep, step.Type)
+	}
+}
+
+// Имя файла без расширения архива: подкаталог для результатов шага
+func Stem(name string) string {
+	base := path.Base(name)
+	lower := strings.ToLower(base)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip", ".gz"} {
+		if strings.HasSuffix(lower, ext) && len(base) > len(ext) {
+			return base[:len(base)-len(ext)]
+		}
+	}
+	if ext := path.Ext(base); ext != base {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return base
+}
+
+// Запускает настроенную команду с путем к копии файла под его именем, чтобы команда не могла
+// повредить хранилище. Файлы, созданные командой в каталоге OUTPUT_DIR, становятся результатами
+// шага в подкаталоге с именем исходного файла.
+func (r *Runner) command(ctx context.Context, name, input, fileName, workDir string) (Result, error) {
+	var res Result
+	argv, ok := r.cfg.Commands[name]
+	if !ok || len(argv) == 0 {
+		return res, fmt.Errorf("%w %q", ErrUnknownCommand, name)
+	}
+	outDir := filepath.Join(workDir, "out")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return res, err
+	}
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+	inDir := filepath.Join(workDir, "in")
+	if err := os.MkdirAll(inDir, 0o755); err != nil {
+		return res, err
+	}
+	abs, err := filepath.Abs(filepath.Join(inDir, path.Base(fileName)))
+	if err != nil {
+		return res, err
+	}
+	if err := copyFile(input, abs); err != nil {
+		return res, err
+	}
+	absOut, err := filepath.Abs(outDir)
+	if err != nil {
+		return res, err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], append(append([]string(nil), argv[1:]...), abs)...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), "OUTPUT_DIR="+absOut, "INPUT_NAME="+path.Base(fileName))
+	var log tailBuffer
+	cmd.Stdout = &log
+	cmd.Stderr = &log
+	err = cmd.Run()
+	res.Log = log.String()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return res, fmt.Errorf("command %q timed out after %s", name, r.cfg.Timeout)
+		}
+		return res, fmt.Errorf("command %q: %w", name, err)
+	}
+	res.Folder = Stem(fileName)
+	var total int64
+	err = filepath.WalkDir(outDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if total += fi.Size(); r.cfg.MaxBytes > 0 && total > r.cfg.MaxBytes {
+			return fmt.Errorf("%w (%d bytes)", ErrTooLarge, r.cfg.MaxBytes)
+		}
+		rel, err := filepath.Rel(outDir, p)
+		if err != nil {
+			return err
+		}
+		if rel = naming.Sanitize(filepath.ToSlash(rel)); rel != "" {
+			res.Outputs = append(res.Outputs, Output{Path: p, Name: rel})
+		}
+		return nil
+	})
+	return res, err
+}
+
+// Копирует файл
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Хранит последние maxLogBytes байт вывода
+type tailBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	t.buf.Write(p)
+	if extra := t.buf.Len() - maxLogBytes; extra > 0 {
+		t.buf.Next(extra)
+		rest := append([]byte(nil), t.buf.Bytes()...)
+		t.buf.Reset()
+		t.buf.Write(rest)
+		t.truncated = true
+	}
+	return n, nil
+}
+
+func (t *tailBuffer) String() string {
+	if t.truncated {
+		return "...\n" + t.buf.String()
+	}
+	return t.buf.String()
+}
+
+// Копирует не больше оставшегося лимита байт
+func copyLimited(dst io.Writer, src io.Reader, remaining *int64, limit int64) error {
+	if limit <= 0 {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	n, err := io.Copy(dst, io.LimitReader(src, *remaining+1))
+	*remaining -= n
+	if err != nil {
+		return err
+	}
+	if *remaining < 0 {
+		return fmt.Errorf("%w (%d bytes)", ErrTooLarge, limit)
+	}
+	return nil
+}
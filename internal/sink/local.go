@@ -0,0 +1,91 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"taskservice/internal/cas"
+)
+
+// Приемник в локальном каталоге: файлы - жесткие ссылки на хранилище по содержимому
+type Local struct {
+	root  string
+	blobs *cas.Store
+}
+
+// Создает локальный приемник в каталоге root
+func NewLocal(root string, blobs *cas.Store) *Local {
+	return &Local{root: filepath.Clean(root), blobs: blobs}
+}
+
+// Путь файла объекта
+func (l *Local) path(key string) string { return filepath.Join(l.root, filepath.FromSlash(key)) }
+
+func (l *Local) Stat(ctx context.Context, key, sha string) (bool, bool, error) {
+	fi, err := os.Lstat(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, false, nil
+	}
+	if errors.Is(err, syscall.ENOTDIR) {
+		// Путь занят файлом на месте одного из каталогов
+		return true, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, sameContent(l.blobs, sha, l.path(key), fi), nil
+}
+
+func (l *Local) Put(ctx context.Context, obj Object) error {
+	return l.blobs.Link(obj.SHA256, l.path(obj.Key))
+}
+
+func (l *Local) Remove(ctx context.Context, key string) (int64, error) {
+	p := l.path(key)
+	fi, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(p); err != nil {
+		return 0, err
+	}
+	l.removeEmptyDirs(filepath.Dir(p))
+	return fi.Size(), nil
+}
+
+// Для локальных файлов адрес - путь относительно корня приемника
+func (l *Local) Location(key string) string { return key }
+
+// Удаляет пустые директории от dir вверх до корня приемника
+func (l *Local) removeEmptyDirs(dir string) {
+	for dir = filepath.Clean(dir); dir != l.root && strings.HasPrefix(dir, l.root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+// Совпадает ли существующий файл с содержимым sha
+func sameContent(store *cas.Store, sha, path string, fi os.FileInfo) bool {
+	if !fi.Mode().IsRegular() {
+		return false
+	}
+	if blob, err := os.Stat(store.Path(sha)); err == nil {
+		if os.SameFile(blob, fi) {
+			return true
+		}
+		if blob.Size() != fi.Size() {
+			return false
+		}
+	}
+	got, _, err := cas.HashFile(path)
+	return err == nil && got == sha
+}
@@ -0,0 +1,405 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"taskservice/internal/storage"
+)
+
+// Минимальный размер части составной загрузки S3 (кроме последней)
+const minPartSize = 5 << 20
+
+// Хранилище состояния незавершенных составных загрузок
+type UploadState interface {
+	SaveUpload(key string, u storage.Upload) error
+	GetUpload(key string) (storage.Upload, bool)
+	DeleteUpload(key string) error
+}
+
+// Параметры S3-совместимого хранилища
+type S3Config struct {
+	Endpoint  string // например https://s3.eu-central-1.amazonaws.com или http://minio:9000
+	Region    string
+	Bucket    string
+	Prefix    string // префикс ключей объектов
+	AccessKey string
+	SecretKey string
+	PathStyle bool  // адрес вида endpoint/bucket/key вместо bucket.endpoint/key
+	PartSize  int64 // размер части составной загрузки; файлы не больше части загружаются одним PUT
+}
+
+// Приемник в S3-совместимом хранилище.
+// Большие файлы загружаются частями; идентификатор загрузки и загруженные части сохраняются,
+// и после перезапуска загрузка продолжается с первой отсутствующей части.
+// Объект отправляется после завершения загрузки элемента: файл заново читается из хранилища
+// по содержимому, а не передается в S3 потоком во время загрузки. Поэтому на время отправки
+// файл занимает место на локальном диске, а сама отправка добавляет к времени элемента еще одно чтение файла.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	state    UploadState
+
+	keysMu sync.Mutex
+	keys   map[string]*sync.Mutex
+}
+
+// Ошибка ответа S3
+type S3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.Status)
+	}
+	return fmt.Sprintf("s3: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Создает приемник S3
+func NewS3(cfg S3Config, state UploadState) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3.amazonaws.com"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize < minPartSize {
+		cfg.PartSize = minPartSize
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+	return &S3{
+		cfg:      cfg,
+		endpoint: u,
+		client:   &http.Client{},
+		state:    state,
+		keys:     make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Ключ объекта с префиксом
+func (s *S3) objectKey(key string) string { return s.cfg.Prefix + strings.TrimPrefix(key, "/") }
+
+// Ключ состояния загрузки в хранилище
+func (s *S3) stateKey(objectKey string) string { return "s3:" + s.cfg.Bucket + "/" + objectKey }
+
+func (s *S3) Location(key string) string { return "s3://" + s.cfg.Bucket + "/" + s.objectKey(key) }
+
+// Блокировка ключа: один объект одновременно загружается только одним воркером
+func (s *S3) lockKey(key string) func() {
+	s.keysMu.Lock()
+	mu, ok := s.keys[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		s.keys[key] = mu
+	}
+	s.keysMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (s *S3) Stat(ctx context.Context, key, sha string) (bool, bool, error) {
+	h, err := s.head(ctx, s.objectKey(key))
+	if err != nil || h == nil {
+		return false, false, err
+	}
+	return true, h.Get("X-Amz-Meta-Sha256") == sha, nil
+}
+
+func (s *S3) Put(ctx context.Context, obj Object) error {
+	key := s.objectKey(obj.Key)
+	defer s.lockKey(key)()
+	// Объект с тем же содержимым уже загружен
+	if h, err := s.head(ctx, key); err != nil {
+		return err
+	} else if h != nil && h.Get("X-Amz-Meta-Sha256") == obj.SHA256 {
+		return s.state.DeleteUpload(s.stateKey(key))
+	}
+	f, err := os.Open(obj.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if obj.Size <= s.cfg.PartSize {
+		hdr := objectHeaders(obj)
+		_, err := s.do(ctx, "PUT", key, nil, hdr, io.NewSectionReader(f, 0, obj.Size), obj.Size, unsignedPayload)
+		return err
+	}
+	err = s.putMultipart(ctx, key, f, obj)
+	var se *S3Error
+	if errors.As(err, &se) && se.Code == "NoSuchUpload" {
+		// Сохраненная загрузка истекла или была отменена на сервере: начинаем заново
+		if derr := s.state.DeleteUpload(s.stateKey(key)); derr != nil {
+			return derr
+		}
+		err = s.putMultipart(ctx, key, f, obj)
+	}
+	return err
+}
+
+func (s *S3) Remove(ctx context.Context, key string) (int64, error) {
+	ok := s.objectKey(key)
+	defer s.lockKey(ok)()
+	if up, found := s.state.GetUpload(s.stateKey(ok)); found {
+		_ = s.abort(ctx, ok, up.UploadID)
+		_ = s.state.DeleteUpload(s.stateKey(ok))
+	}
+	h, err := s.head(ctx, ok)
+	if err != nil {
+		return 0, err
+	}
+	if h == nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, s.Location(key))
+	}
+	size, _ := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if _, err := s.do(ctx, "DELETE", ok, nil, nil, nil, 0, emptySHA256); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Заголовки нового объекта: тип содержимого и sha256 в метаданных
+func objectHeaders(obj Object) http.Header {
+	hdr := http.Header{}
+	hdr.Set("X-Amz-Meta-Sha256", obj.SHA256)
+	if obj.ContentType != "" {
+		hdr.Set("Content-Type", obj.ContentType)
+	}
+	return hdr
+}
+
+// Загружает файл частями, продолжая сохраненную загрузку
+func (s *S3) putMultipart(ctx context.Context, key string, f *os.File, obj Object) error {
+	sk := s.stateKey(key)
+	up, ok := s.state.GetUpload(sk)
+	if ok && (up.SHA256 != obj.SHA256 || up.Size != obj.Size || up.PartSize != s.cfg.PartSize) {
+		_ = s.abort(ctx, key, up.UploadID)
+		if err := s.state.DeleteUpload(sk); err != nil {
+			return err
+		}
+		ok = false
+	}
+	if !ok {
+		id, err := s.createMultipart(ctx, key, obj)
+		if err != nil {
+			return err
+		}
+		up = storage.Upload{UploadID: id, SHA256: obj.SHA256, Size: obj.Size, PartSize: s.cfg.PartSize, StartedAt: time.Now()}
+		if err := s.state.SaveUpload(sk, up); err != nil {
+			return err
+		}
+	}
+
+	done := make(map[int]bool, len(up.Parts))
+	for _, p := range up.Parts {
+		done[p.Number] = true
+	}
+	count := int((obj.Size + up.PartSize - 1) / up.PartSize)
+	for n := 1; n <= count; n++ {
+		if done[n] {
+			continue
+		}
+		off := int64(n-1) * up.PartSize
+		size := up.PartSize
+		if off+size > obj.Size {
+			size = obj.Size - off
+		}
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {up.UploadID}}
+		h, err := s.do(ctx, "PUT", key, q, nil, io.NewSectionReader(f, off, size), size, unsignedPayload)
+		if err != nil {
+			return s.failMultipart(ctx, key, up, fmt.Errorf("upload part %d: %w", n, err))
+		}
+		up.Parts = append(up.Parts, storage.UploadPart{Number: n, ETag: h.Get("ETag"), Size: size})
+		if err := s.state.SaveUpload(sk, up); err != nil {
+			return err
+		}
+	}
+
+	if err := s.completeMultipart(ctx, key, up); err != nil {
+		return s.failMultipart(ctx, key, up, err)
+	}
+	return s.state.DeleteUpload(sk)
+}
+
+// Обрабатывает ошибку составной загрузки. После временной ошибки загрузка сохраняется и продолжается
+// следующей попыткой; ошибку, которую повтор не исправит (например, нет доступа или часть отклонена),
+// загрузка не переживет, поэтому она отменяется, чтобы загруженные части не занимали место в хранилище
+func (s *S3) failMultipart(ctx context.Context, key string, up storage.Upload, err error) error {
+	if !isPermanentS3(err) {
+		return err
+	}
+	_ = s.abort(ctx, key, up.UploadID)
+	if derr := s.state.DeleteUpload(s.stateKey(key)); derr != nil {
+		return derr
+	}
+	return err
+}
+
+// Сообщает, что ошибку S3 не исправит повтор запроса: ответ 4xx, кроме истекшей загрузки,
+// таймаута запроса и ограничения частоты
+func isPermanentS3(err error) bool {
+	var se *S3Error
+	if !errors.As(err, &se) || se.Status < 400 || se.Status >= 500 {
+		return false
+	}
+	switch se.Code {
+	case "NoSuchUpload", "RequestTimeout", "SlowDown":
+		return false
+	}
+	return se.Status != http.StatusRequestTimeout && se.Status != http.StatusTooManyRequests
+}
+
+// Начинает составную загрузку и возвращает ее идентификатор
+func (s *S3) createMultipart(ctx context.Context, key string, obj Object) (string, error) {
+	var res struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.doXML(ctx, "POST", key, url.Values{"uploads": {""}}, objectHeaders(obj), nil, &res); err != nil {
+		return "", err
+	}
+	if res.UploadID == "" {
+		return "", errors.New("s3: empty upload id")
+	}
+	return res.UploadID, nil
+}
+
+// Завершает составную загрузку из частей по порядку
+func (s *S3) completeMultipart(ctx context.Context, key string, up storage.Upload) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	body := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	parts := append([]storage.UploadPart(nil), up.Parts...)
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	for _, p := range parts {
+		body.Parts = append(body.Parts, part{PartNumber: p.Number, ETag: p.ETag})
+	}
+	b, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return s.doXML(ctx, "POST", key, url.Values{"uploadId": {up.UploadID}}, nil, b, nil)
+}
+
+// Отменяет составную загрузку
+func (s *S3) abort(ctx context.Context, key, uploadID string) error {
+	_, err := s.do(ctx, "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil, 0, emptySHA256)
+	return err
+}
+
+// Возвращает заголовки объекта или nil, если объекта нет
+func (s *S3) head(ctx context.Context, key string) (http.Header, error) {
+	h, err := s.do(ctx, "HEAD", key, nil, nil, nil, 0, emptySHA256)
+	var se *S3Error
+	if errors.As(err, &se) && se.Status == http.StatusNotFound {
+		return nil, nil
+	}
+	return h, err
+}
+
+// Выполняет запрос с XML-телом и разбирает XML-ответ.
+// S3 может вернуть ошибку завершения загрузки в теле ответа со статусом 200.
+func (s *S3) doXML(ctx context.Context, method, key string, q url.Values, hdr http.Header, body []byte, out interface{}) error {
+	req, err := s.newRequest(ctx, method, key, q, hdr, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	signV4(req, hexSHA256(body), s.cfg.Region, s.cfg.AccessKey, s.cfg.SecretKey, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 || bytes.Contains(data, []byte("<Error>")) {
+		return parseS3Error(resp.StatusCode, data)
+	}
+	if out != nil {
+		return xml.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// Выполняет запрос и возвращает заголовки ответа
+func (s *S3) do(ctx context.Context, method, key string, q url.Values, hdr http.Header, body io.Reader, size int64, payloadHash string) (http.Header, error) {
+	req, err := s.newRequest(ctx, method, key, q, hdr, body, size)
+	if err != nil {
+		return nil, err
+	}
+	signV4(req, payloadHash, s.cfg.Region, s.cfg.AccessKey, s.cfg.SecretKey, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, parseS3Error(resp.StatusCode, data)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Header, nil
+}
+
+// Собирает запрос к объекту key
+func (s *S3) newRequest(ctx context.Context, method, key string, q url.Values, hdr http.Header, body io.Reader, size int64) (*http.Request, error) {
+	u := *s.endpoint
+	p := "/" + key
+	if s.cfg.PathStyle {
+		p = "/" + s.cfg.Bucket + p
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + p
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(q)
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+// Разбирает XML-ошибку S3
+func parseS3Error(status int, data []byte) error {
+	e := &S3Error{Status: status}
+	_ = xml.Unmarshal(data, e)
+	return e
+}
@@ -0,0 +1,293 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"taskservice/internal/storage"
+)
+
+// Состояние загрузок в памяти
+type memUploads struct {
+	mu sync.Mutex
+	m  map[string]storage.Upload
+}
+
+func (s *memUploads) SaveUpload(key string, u storage.Upload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u.Parts = append([]storage.UploadPart(nil), u.Parts...)
+	s.m[key] = u
+	return nil
+}
+
+func (s *memUploads) GetUpload(key string) (storage.Upload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.m[key]
+	return u, ok
+}
+
+func (s *memUploads) DeleteUpload(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, key)
+	return nil
+}
+
+// Минимальный сервер S3 с адресацией bucket в пути: объекты, составные загрузки и внедрение ошибок
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]http.Header
+	uploads map[string]map[int][]byte // uploadId -> номер части -> содержимое
+	nextID  int
+	aborted []string
+	parts   []int // номера всех принятых частей по порядку
+	// Ошибка для части с номером failPart: статус и код; failTimes - сколько раз ее вернуть
+	failPart  int
+	failCode  int
+	failErr   string
+	failTimes int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, meta: map[string]http.Header{}, uploads: map[string]map[int][]byte{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("X-Amz-Content-Sha256") == "" || r.Header.Get("X-Amz-Date") == "" {
+		s3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	q := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == "HEAD":
+		b, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range f.meta[key] {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	case r.Method == "POST" && q.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == "PUT" && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		if n == f.failPart && f.failTimes > 0 {
+			f.failTimes--
+			s3Error(w, f.failCode, f.failErr)
+			return
+		}
+		parts[n] = body
+		f.parts = append(f.parts, n)
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", n))
+	case r.Method == "POST" && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var req struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) != len(parts) {
+			s3Error(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		var obj []byte
+		for i, p := range req.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf("\"etag-%d\"", p.PartNumber) {
+				s3Error(w, http.StatusBadRequest, "InvalidPartOrder")
+				return
+			}
+			obj = append(obj, parts[p.PartNumber]...)
+		}
+		f.objects[key] = obj
+		delete(f.uploads, q.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == "DELETE" && q.Has("uploadId"):
+		f.aborted = append(f.aborted, q.Get("uploadId"))
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT":
+		f.objects[key] = body
+		f.meta[key] = http.Header{"X-Amz-Meta-Sha256": {r.Header.Get("X-Amz-Meta-Sha256")}}
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>test</Message></Error>", code)
+}
+
+// Создает приемник, подключенный к тестовому серверу, и файл заданного размера
+func newTestS3(t *testing.T, size int64) (*S3, *fakeS3, *memUploads, Object) {
+	t.Helper()
+	fake := newFakeS3()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	state := &memUploads{m: map[string]storage.Upload{}}
+	s, err := NewS3(S3Config{Endpoint: srv.URL, Bucket: "bucket", Prefix: "p/", AccessKey: "key", SecretKey: "secret",
+		PathStyle: true, PartSize: minPartSize}, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, size)
+	rand.Read(data)
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return s, fake, state, Object{Key: "dir/file.bin", SHA256: hex.EncodeToString(sum[:]), Size: size, Path: path}
+}
+
+func readObject(t *testing.T, obj Object) []byte {
+	t.Helper()
+	b, err := os.ReadFile(obj.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestS3PutSingleObject(t *testing.T) {
+	s, fake, _, obj := newTestS3(t, 1000)
+	ctx := context.Background()
+	if err := s.Put(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.objects["p/dir/file.bin"], readObject(t, obj)) {
+		t.Fatal("stored object differs from the file")
+	}
+	exists, same, err := s.Stat(ctx, obj.Key, obj.SHA256)
+	if err != nil || !exists || !same {
+		t.Fatalf("Stat = %v, %v, %v; want existing object with the same content", exists, same, err)
+	}
+	if size, err := s.Remove(ctx, obj.Key); err != nil || size != obj.Size {
+		t.Fatalf("Remove = %d, %v", size, err)
+	}
+	if exists, _, _ := s.Stat(ctx, obj.Key, obj.SHA256); exists {
+		t.Fatal("object exists after Remove")
+	}
+}
+
+func TestS3Multipart(t *testing.T) {
+	s, fake, state, obj := newTestS3(t, 2*minPartSize+1234)
+	if err := s.Put(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.objects["p/dir/file.bin"], readObject(t, obj)) {
+		t.Fatal("assembled object differs from the file")
+	}
+	if got := fmt.Sprint(fake.parts); got != "[1 2 3]" {
+		t.Fatalf("uploaded parts %s, want [1 2 3]", got)
+	}
+	if len(state.m) != 0 || len(fake.uploads) != 0 {
+		t.Fatal("upload state is left after completion")
+	}
+}
+
+func TestS3MultipartResume(t *testing.T) {
+	s, fake, state, obj := newTestS3(t, 2*minPartSize+1234)
+	fake.failPart, fake.failCode, fake.failErr, fake.failTimes = 2, http.StatusInternalServerError, "InternalError", 1
+	ctx := context.Background()
+	if err := s.Put(ctx, obj); err == nil {
+		t.Fatal("Put succeeded despite a failed part")
+	}
+	up, ok := state.GetUpload(s.stateKey("p/dir/file.bin"))
+	if !ok || len(up.Parts) != 1 || up.Parts[0].Number != 1 {
+		t.Fatalf("saved upload %+v, want part 1 after a transient error", up)
+	}
+	if len(fake.aborted) != 0 {
+		t.Fatal("upload was aborted after a transient error")
+	}
+
+	// Следующая попытка продолжает ту же загрузку со второй части
+	if err := s.Put(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(fake.parts); got != "[1 2 3]" {
+		t.Fatalf("uploaded parts %s, want [1 2 3] without repeating part 1", got)
+	}
+	if fake.nextID != 1 {
+		t.Fatalf("%d uploads started, want the saved one to be resumed", fake.nextID)
+	}
+	if !bytes.Equal(fake.objects["p/dir/file.bin"], readObject(t, obj)) {
+		t.Fatal("resumed object differs from the file")
+	}
+}
+
+func TestS3MultipartAbortOnPermanentError(t *testing.T) {
+	s, fake, state, obj := newTestS3(t, 2*minPartSize+1234)
+	fake.failPart, fake.failCode, fake.failErr, fake.failTimes = 2, http.StatusForbidden, "AccessDenied", 1
+	err := s.Put(context.Background(), obj)
+	var se *S3Error
+	if !errors.As(err, &se) || se.Code != "AccessDenied" {
+		t.Fatalf("Put error %v, want AccessDenied", err)
+	}
+	if len(fake.aborted) != 1 || fake.aborted[0] != "upload-1" {
+		t.Fatalf("aborted uploads %v, want [upload-1]", fake.aborted)
+	}
+	if len(state.m) != 0 {
+		t.Fatal("state of the aborted upload is kept")
+	}
+}
+
+func TestS3MultipartRestartsExpiredUpload(t *testing.T) {
+	s, fake, state, obj := newTestS3(t, minPartSize+10)
+	// Сохраненная загрузка, о которой сервер уже не знает
+	_ = state.SaveUpload(s.stateKey("p/dir/file.bin"), storage.Upload{UploadID: "gone", SHA256: obj.SHA256, Size: obj.Size,
+		PartSize: minPartSize, Parts: []storage.UploadPart{{Number: 1, ETag: "\"etag-1\"", Size: minPartSize}}})
+	if err := s.Put(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.objects["p/dir/file.bin"], readObject(t, obj)) {
+		t.Fatal("object differs from the file")
+	}
+	parts := append([]int(nil), fake.parts...)
+	sort.Ints(parts)
+	if fmt.Sprint(parts) != "[1 2]" {
+		t.Fatalf("uploaded parts %v, want all parts of the new upload", fake.parts)
+	}
+}
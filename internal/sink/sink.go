@@ -0,0 +1,33 @@
+package sink
+
+import (
+	"context"
+	"errors"
+)
+
+// Имя локального приемника, который есть всегда
+const LocalName = "local"
+
+// ErrNotFound возвращается при удалении отсутствующего объекта
+var ErrNotFound = errors.New("object not found")
+
+// Объект для записи в приемник
+type Object struct {
+	Key         string // имя файла элемента, относительный путь через "/"
+	SHA256      string
+	Size        int64
+	ContentType string
+	Path        string // локальный файл с содержимым
+}
+
+// Приемник результатов загрузки
+type Sink interface {
+	// Stat сообщает, есть ли объект key и совпадает ли его содержимое с sha
+	Stat(ctx context.Context, key, sha string) (exists, same bool, err error)
+	// Put записывает объект, заменяя существующий
+	Put(ctx context.Context, obj Object) error
+	// Remove удаляет объект и возвращает его размер
+	Remove(ctx context.Context, key string) (int64, error)
+	// Location возвращает адрес объекта для пользователя
+	Location(key string) string
+}
@@ -0,0 +1,188 @@
+package statefile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+// Формат файла выгрузки: первая строка - заголовок, далее по одной записи на строку.
+// Формат не зависит от устройства WAL и snapshot.
+// Версия 2 добавляет расписания и ссылки на файлы хранилища по содержимому.
+const (
+	FormatName = "taskservice-state"
+	Version    = 2
+)
+
+// Типы записей файла выгрузки
+const (
+	kindTask     = "task"
+	kindSchedule = "schedule"
+	kindBlob     = "blob"
+)
+
+// Заголовок файла выгрузки
+type Header struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// HasFullState сообщает, содержит ли файл расписания и ссылки на файлы, то есть все состояние, которое
+// можно заменить импортом. Файлы версии 1 содержат только задачи
+func (h Header) HasFullState() bool { return h.Version >= 2 }
+
+// Запись файла выгрузки
+type record struct {
+	Kind     string          `json:"kind"`
+	Task     *model.Task     `json:"task,omitempty"`
+	Schedule *model.Schedule `json:"schedule,omitempty"`
+	Blob     *storage.Blob   `json:"blob,omitempty"`
+}
+
+// Содержимое файла выгрузки
+type State struct {
+	Tasks     []*model.Task
+	Schedules []*model.Schedule
+	// Ссылки элементов задач на файлы хранилища по содержимому; без них janitor удалил бы файлы как ненужные
+	Blobs []storage.Blob
+}
+
+// Фильтр задач для выгрузки
+type Filter struct {
+	Statuses []model.TaskStatus
+	Since    time.Time
+	Until    time.Time
+}
+
+// Проверяет, подходит ли задача под фильтр
+func (f Filter) Match(t *model.Task) bool {
+	if len(f.Statuses) > 0 {
+		ok := false
+		for _, s := range f.Statuses {
+			if t.Status == s {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && t.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !t.CreatedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Оставляет задачи, подходящие под фильтр, и ссылки на файлы только этих задач; расписания не фильтруются
+func Select(s State, f Filter) State {
+	out := State{Schedules: s.Schedules}
+	ids := make(map[model.TaskID]bool)
+	for _, t := range s.Tasks {
+		if f.Match(t) {
+			out.Tasks = append(out.Tasks, t)
+			ids[t.ID] = true
+		}
+	}
+	for _, b := range s.Blobs {
+		var refs []storage.BlobRef
+		for _, ref := range b.Refs {
+			if ids[ref.TaskID] {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) > 0 {
+			out.Blobs = append(out.Blobs, storage.Blob{SHA256: b.SHA256, Size: b.Size, Refs: refs})
+		}
+	}
+	return out
+}
+
+// Записывает расписания, задачи в порядке создания и ссылки на файлы
+func Write(w io.Writer, s State) error {
+	tasks := make([]*model.Task, len(s.Tasks))
+	copy(tasks, s.Tasks)
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(Header{Format: FormatName, Version: Version, ExportedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+	for _, sc := range s.Schedules {
+		if err := enc.Encode(record{Kind: kindSchedule, Schedule: sc}); err != nil {
+			return err
+		}
+	}
+	for _, t := range tasks {
+		if err := enc.Encode(record{Kind: kindTask, Task: t}); err != nil {
+			return err
+		}
+	}
+	for i := range s.Blobs {
+		if err := enc.Encode(record{Kind: kindBlob, Blob: &s.Blobs[i]}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Читает файл выгрузки и возвращает заголовок и содержимое
+func Read(r io.Reader) (Header, State, error) {
+	var s State
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var h Header
+	if err := dec.Decode(&h); err != nil {
+		return h, s, fmt.Errorf("read header: %w", err)
+	}
+	if h.Format != FormatName {
+		return h, s, fmt.Errorf("unknown format %q", h.Format)
+	}
+	if h.Version < 1 || h.Version > Version {
+		return h, s, fmt.Errorf("unsupported version %d", h.Version)
+	}
+	for line := 2; ; line++ {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return h, s, fmt.Errorf("record %d: %w", line, err)
+		}
+		switch rec.Kind {
+		case kindTask:
+			if rec.Task == nil || rec.Task.ID == "" {
+				return h, s, fmt.Errorf("record %d: task without id", line)
+			}
+			s.Tasks = append(s.Tasks, rec.Task)
+		case kindSchedule:
+			if rec.Schedule == nil || rec.Schedule.ID == "" {
+				return h, s, fmt.Errorf("record %d: schedule without id", line)
+			}
+			s.Schedules = append(s.Schedules, rec.Schedule)
+		case kindBlob:
+			if rec.Blob == nil || rec.Blob.SHA256 == "" {
+				return h, s, fmt.Errorf("record %d: blob without sha256", line)
+			}
+			s.Blobs = append(s.Blobs, *rec.Blob)
+		default:
+			// Неизвестные типы записей пропускаются для совместимости с будущими версиями
+		}
+	}
+	return h, s, nil
+}
@@ -0,0 +1,92 @@
+package statefile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+	"taskservice/internal/storage"
+)
+
+func testState() State {
+	base := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	return State{
+		Tasks: []*model.Task{
+			{ID: "t2", Status: model.TaskStatusFailed, CreatedAt: base.Add(time.Hour)},
+			{ID: "t1", Status: model.TaskStatusCompleted, CreatedAt: base, ScheduleID: "s1"},
+		},
+		Schedules: []*model.Schedule{{ID: "s1", Cron: "0 * * * *", CreatedAt: base}},
+		Blobs: []storage.Blob{
+			{SHA256: "aa", Size: 3, Refs: []storage.BlobRef{{TaskID: "t1", ItemIdx: 0}, {TaskID: "t2", ItemIdx: 1}}},
+			{SHA256: "bb", Size: 5, Refs: []storage.BlobRef{{TaskID: "t2", ItemIdx: 0}}},
+		},
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testState()); err != nil {
+		t.Fatal(err)
+	}
+	h, s, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != Version || !h.HasFullState() {
+		t.Fatalf("header %+v", h)
+	}
+	if len(s.Tasks) != 2 || s.Tasks[0].ID != "t1" || s.Tasks[1].ID != "t2" {
+		t.Fatalf("tasks %+v, want t1, t2 in creation order", s.Tasks)
+	}
+	if len(s.Schedules) != 1 || s.Schedules[0].ID != "s1" || s.Schedules[0].Cron != "0 * * * *" {
+		t.Fatalf("schedules %+v", s.Schedules)
+	}
+	if len(s.Blobs) != 2 || len(s.Blobs[0].Refs) != 2 || s.Blobs[1].Refs[0] != (storage.BlobRef{TaskID: "t2"}) {
+		t.Fatalf("blobs %+v", s.Blobs)
+	}
+}
+
+func TestSelectKeepsBlobRefsOfSelectedTasks(t *testing.T) {
+	s := Select(testState(), Filter{Statuses: []model.TaskStatus{model.TaskStatusCompleted}})
+	if len(s.Tasks) != 1 || s.Tasks[0].ID != "t1" {
+		t.Fatalf("tasks %+v, want t1", s.Tasks)
+	}
+	if len(s.Schedules) != 1 {
+		t.Fatalf("schedules %+v, want all schedules", s.Schedules)
+	}
+	if len(s.Blobs) != 1 || s.Blobs[0].SHA256 != "aa" || len(s.Blobs[0].Refs) != 1 || s.Blobs[0].Refs[0].TaskID != "t1" {
+		t.Fatalf("blobs %+v, want only the ref of t1", s.Blobs)
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	in := `{"format":"taskservice-state","version":1,"exported_at":"2025-09-01T00:00:00Z"}
+{"kind":"task","task":{"id":"t1","status":"completed"}}
+`
+	h, s, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.HasFullState() {
+		t.Fatal("version 1 file reports schedules and blob refs")
+	}
+	if len(s.Tasks) != 1 || len(s.Schedules) != 0 || len(s.Blobs) != 0 {
+		t.Fatalf("state %+v", s)
+	}
+}
+
+func TestReadRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"unknown format":    `{"format":"other","version":1}`,
+		"future version":    `{"format":"taskservice-state","version":99}`,
+		"task without id":   "{\"format\":\"taskservice-state\",\"version\":2}\n{\"kind\":\"task\",\"task\":{}}",
+		"blob without hash": "{\"format\":\"taskservice-state\",\"version\":2}\n{\"kind\":\"blob\",\"blob\":{\"size\":1}}",
+	}
+	for name, in := range tests {
+		if _, _, err := Read(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
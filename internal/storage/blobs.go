@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Ссылка элемента задачи на файл в хранилище по содержимому
+type BlobRef struct {
+	TaskID  model.TaskID `json:"task_id"`
+	ItemIdx int          `json:"item_idx"`
+}
+
+// Запись индекса URL: какое содержимое было получено по адресу
+type URLRecord struct {
+	SHA256          string    `json:"sha256"`
+	Size            int64     `json:"size"`
+	FetchedAt       time.Time `json:"fetched_at"`
+	DispositionName string    `json:"disposition_name,omitempty"` // имя из Content-Disposition
+	ContentType     string    `json:"content_type,omitempty"`
+	SniffedType     string    `json:"sniffed_type,omitempty"`
+	// Валидаторы для условных запросов
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Адрес ответа и перенаправления, пройденные при получении
+	FinalURL      string           `json:"final_url,omitempty"`
+	RedirectChain []model.Redirect `json:"redirect_chain,omitempty"`
+}
+
+// HasValidators сообщает, можно ли проверить изменение содержимого условным запросом
+func (r URLRecord) HasValidators() bool { return r.ETag != "" || r.LastModified != "" }
+
+// Состояние файла в хранилище по содержимому
+type blobState struct {
+	Size int64     `json:"size"`
+	Refs []BlobRef `json:"refs"`
+}
+
+// Blob - файл в хранилище по содержимому и ссылки элементов задач на него
+type Blob struct {
+	SHA256 string    `json:"sha256"`
+	Size   int64     `json:"size"`
+	Refs   []BlobRef `json:"refs"`
+}
+
+// Представляет запись в WAL для добавления ссылки на файл
+type recordBlobRef struct {
+	SHA256 string  `json:"sha256"`
+	Size   int64   `json:"size"`
+	Ref    BlobRef `json:"ref"`
+}
+
+// Представляет запись в WAL для освобождения ссылок задачи
+type recordBlobRelease struct {
+	TaskID model.TaskID `json:"task_id"`
+}
+
+// Представляет запись в WAL для обновления индекса URL
+type recordIndexURL struct {
+	URL    string    `json:"url"`
+	Record URLRecord `json:"record"`
+}
+
+// AddBlobRef добавляет ссылку элемента задачи на файл; повторное добавление не меняет счетчик
+func (s *Store) AddBlobRef(sha string, size int64, ref BlobRef) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.addBlobRefLocked(sha, size, ref) {
+		return nil
+	}
+	return s.appendRecord(walRecord{Type: "blob_ref", Data: recordBlobRef{SHA256: sha, Size: size, Ref: ref}})
+}
+
+// ReleaseBlobRefs удаляет все ссылки элементов задачи и возвращает файлы, на которые больше никто не ссылается.
+// Записи индекса URL для таких файлов удаляются.
+func (s *Store) ReleaseBlobRefs(id model.TaskID) ([]string, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hasBlobRefsLocked(id) {
+		return nil, nil
+	}
+	released := s.releaseBlobRefsLocked(id)
+	return released, s.appendRecord(walRecord{Type: "blob_release", Data: recordBlobRelease{TaskID: id}})
+}
+
+// BlobRefCount возвращает число ссылок на файл
+func (s *Store) BlobRefCount(sha string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if b, ok := s.blobs[sha]; ok {
+		return len(b.Refs)
+	}
+	return 0
+}
+
+// HasBlob сообщает, есть ли на файл хотя бы одна ссылка
+func (s *Store) HasBlob(sha string) bool { return s.BlobRefCount(sha) > 0 }
+
+// ListBlobs возвращает копии файлов со ссылками в порядке sha256
+func (s *Store) ListBlobs() []Blob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Blob, 0, len(s.blobs))
+	for sha, b := range s.blobs {
+		out = append(out, Blob{SHA256: sha, Size: b.Size, Refs: append([]BlobRef(nil), b.Refs...)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SHA256 < out[j].SHA256 })
+	return out
+}
+
+// IndexURL запоминает содержимое, полученное по адресу
+func (s *Store) IndexURL(url string, rec URLRecord) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.urls[url] = rec
+	return s.appendRecord(walRecord{Type: "index_url", Data: recordIndexURL{URL: url, Record: rec}})
+}
+
+// LookupURL возвращает запись индекса URL
+func (s *Store) LookupURL(url string) (URLRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.urls[url]
+	return rec, ok
+}
+
+// Добавляет ссылку в память; возвращает false, если ссылка уже была
+func (s *Store) addBlobRefLocked(sha string, size int64, ref BlobRef) bool {
+	b, ok := s.blobs[sha]
+	if !ok {
+		b = &blobState{Size: size}
+		s.blobs[sha] = b
+	}
+	for _, r := range b.Refs {
+		if r == ref {
+			return false
+		}
+	}
+	b.Refs = append(b.Refs, ref)
+	return true
+}
+
+// Есть ли у задачи ссылки на файлы
+func (s *Store) hasBlobRefsLocked(id model.TaskID) bool {
+	for _, b := range s.blobs {
+		for _, r := range b.Refs {
+			if r.TaskID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Удаляет ссылки задачи из памяти и возвращает файлы без ссылок
+func (s *Store) releaseBlobRefsLocked(id model.TaskID) []string {
+	var released []string
+	for sha, b := range s.blobs {
+		refs := b.Refs[:0]
+		changed := false
+		for _, r := range b.Refs {
+			if r.TaskID == id {
+				changed = true
+				continue
+			}
+			refs = append(refs, r)
+		}
+		b.Refs = refs
+		if changed && len(refs) == 0 {
+			delete(s.blobs, sha)
+			released = append(released, sha)
+		}
+	}
+	if len(released) > 0 {
+		gone := make(map[string]bool, len(released))
+		for _, sha := range released {
+			gone[sha] = true
+		}
+		for url, rec := range s.urls {
+			if gone[rec.SHA256] {
+				delete(s.urls, url)
+			}
+		}
+	}
+	return released
+}
@@ -0,0 +1,70 @@
+package storage
+
+import "taskservice/internal/model"
+
+// Дополнительное состояние, сохраняемое рядом со snapshot задач
+type extraState struct {
+	History    map[model.TaskID][]model.Transition  `json:"history,omitempty"`
+	HistorySeq map[model.TaskID]int64               `json:"history_seq,omitempty"`
+	Blobs      map[string]*blobState                `json:"blobs,omitempty"`
+	URLs       map[string]URLRecord                 `json:"urls,omitempty"`
+	Uploads    map[string]Upload                    `json:"uploads,omitempty"`
+	Schedules  map[model.ScheduleID]*model.Schedule `json:"schedules,omitempty"`
+}
+
+// Снимает копию дополнительного состояния для snapshot; вызывается под s.mu
+func (s *Store) captureExtra() *extraState {
+	extra := &extraState{
+		History:    make(map[model.TaskID][]model.Transition, len(s.history)),
+		HistorySeq: make(map[model.TaskID]int64, len(s.historySeq)),
+		Blobs:      make(map[string]*blobState, len(s.blobs)),
+		URLs:       make(map[string]URLRecord, len(s.urls)),
+		Uploads:    make(map[string]Upload, len(s.uploads)),
+		Schedules:  make(map[model.ScheduleID]*model.Schedule, len(s.schedules)),
+	}
+	for id, seq := range s.historySeq {
+		extra.HistorySeq[id] = seq
+	}
+	for id := range s.history {
+		s.trimHistoryLocked(id)
+		if h, ok := s.history[id]; ok {
+			extra.History[id] = append([]model.Transition(nil), h...)
+		}
+	}
+	for sha, b := range s.blobs {
+		extra.Blobs[sha] = &blobState{Size: b.Size, Refs: append([]BlobRef(nil), b.Refs...)}
+	}
+	for url, rec := range s.urls {
+		extra.URLs[url] = rec
+	}
+	for key, u := range s.uploads {
+		extra.Uploads[key] = u
+	}
+	for id, sc := range s.schedules {
+		cp := *sc
+		extra.Schedules[id] = &cp
+	}
+	return extra
+}
+
+// Восстанавливает дополнительное состояние из snapshot
+func (s *Store) restoreExtra(extra *extraState) {
+	for id, h := range extra.History {
+		s.history[id] = h
+	}
+	for id, seq := range extra.HistorySeq {
+		s.historySeq[id] = seq
+	}
+	for sha, b := range extra.Blobs {
+		s.blobs[sha] = b
+	}
+	for url, rec := range extra.URLs {
+		s.urls[url] = rec
+	}
+	for key, u := range extra.Uploads {
+		s.uploads[key] = u
+	}
+	for id, sc := range extra.Schedules {
+		s.schedules[id] = sc
+	}
+}
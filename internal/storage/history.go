@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Ограничения хранения истории переходов; нулевые значения снимают ограничение
+type HistoryRetention struct {
+	MaxEntries int           // максимум записей на задачу
+	MaxAge     time.Duration // максимальный возраст записи
+}
+
+// Представляет запись в WAL для добавления переходов в историю задачи
+type recordAppendHistory struct {
+	TaskID      model.TaskID       `json:"task_id"`
+	Transitions []model.Transition `json:"transitions"`
+}
+
+// Задает ограничения хранения истории
+func (s *Store) SetHistoryRetention(r HistoryRetention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = r
+	for id := range s.history {
+		s.trimHistoryLocked(id)
+	}
+}
+
+// AppendHistory добавляет переходы в историю задачи. В WAL они попадают в одной записи со следующим
+// сохранением задачи (UpsertTask, UpdateTask, UpdateItem), поэтому переход и новое состояние задачи
+// записываются одним fsync; переходы без последующего сохранения записываются при Close
+func (s *Store) AppendHistory(id model.TaskID, trs ...model.Transition) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if len(trs) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range trs {
+		trs[i].Seq = s.historySeq[id] + int64(i) + 1
+	}
+	s.appendHistoryLocked(id, trs)
+	s.pendingHistory[id] = append(s.pendingHistory[id], trs...)
+	// Новый статус задачи виден в TaskStatuses сразу, до сохранения задачи
+	if _, ok := s.tasks[id]; ok {
+		for _, tr := range trs {
+			if tr.ItemIdx == nil {
+				s.statuses[id] = model.TaskStatus(tr.To)
+			}
+		}
+	}
+	return nil
+}
+
+// Забирает переходы задачи, ожидающие записи в WAL
+func (s *Store) takeHistoryLocked(id model.TaskID) []model.Transition {
+	trs := s.pendingHistory[id]
+	delete(s.pendingHistory, id)
+	return trs
+}
+
+// Возвращает переходы в ожидание, если запись с ними не попала в WAL
+func (s *Store) restoreHistoryOnError(id model.TaskID, trs []model.Transition, err error) error {
+	if err != nil && len(trs) > 0 {
+		s.pendingHistory[id] = append(trs, s.pendingHistory[id]...)
+	}
+	return err
+}
+
+// Записывает в WAL переходы, которые не попали ни в одно сохранение задачи
+func (s *Store) flushHistoryLocked() {
+	for id, trs := range s.pendingHistory {
+		if err := s.appendRecord(walRecord{Type: "append_history", Data: recordAppendHistory{TaskID: id, Transitions: trs}}); err != nil {
+			return
+		}
+		delete(s.pendingHistory, id)
+	}
+}
+
+// GetHistory возвращает историю задачи в хронологическом порядке
+func (s *Store) GetHistory(id model.TaskID) []model.Transition {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := s.history[id]
+	cutoff := s.historyCutoff()
+	out := make([]model.Transition, 0, len(h))
+	for _, tr := range h {
+		if !cutoff.IsZero() && tr.At.Before(cutoff) {
+			continue
+		}
+		out = append(out, tr)
+	}
+	return out
+}
+
+// Добавляет переходы в память с учетом ограничений хранения.
+// Переходы с уже известным порядковым номером пропускаются, поэтому
+// повторное воспроизведение WAL поверх snapshot не создает дублей.
+func (s *Store) appendHistoryLocked(id model.TaskID, trs []model.Transition) {
+	for _, tr := range trs {
+		if tr.Seq <= s.historySeq[id] {
+			continue
+		}
+		s.historySeq[id] = tr.Seq
+		s.history[id] = append(s.history[id], tr)
+	}
+	s.trimHistoryLocked(id)
+}
+
+// Удаляет устаревшие и лишние записи истории задачи
+func (s *Store) trimHistoryLocked(id model.TaskID) {
+	h := s.history[id]
+	if cutoff := s.historyCutoff(); !cutoff.IsZero() {
+		i := 0
+		for i < len(h) && h[i].At.Before(cutoff) {
+			i++
+		}
+		h = h[i:]
+	}
+	if n := s.retention.MaxEntries; n > 0 && len(h) > n {
+		h = h[len(h)-n:]
+	}
+	if len(h) == 0 {
+		delete(s.history, id)
+		return
+	}
+	s.history[id] = h
+}
+
+// Момент, раньше которого записи истории считаются устаревшими
+func (s *Store) historyCutoff() time.Time {
+	if s.retention.MaxAge <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-s.retention.MaxAge)
+}
@@ -0,0 +1,15 @@
+package storage
+
+import "taskservice/internal/metrics"
+
+// Метрики хранилища
+var (
+	walAppendSeconds = metrics.NewHistogram("taskservice_wal_append_seconds",
+		"Time to append one record to the WAL, including fsync.", metrics.ExponentialBuckets(0.0001, 4, 9))
+	walFsyncSeconds = metrics.NewHistogram("taskservice_wal_fsync_seconds",
+		"Time to fsync the WAL after an append.", metrics.ExponentialBuckets(0.0001, 4, 9))
+	snapshotSeconds = metrics.NewHistogram("taskservice_snapshot_seconds",
+		"Time to write the state snapshot.", metrics.ExponentialBuckets(0.01, 3, 8))
+	walSizeBytes = metrics.NewGauge("taskservice_wal_size_bytes",
+		"Current size of the WAL file in bytes.")
+)
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"sort"
+
+	"taskservice/internal/model"
+)
+
+// Представляет запись в WAL для сохранения расписания
+type recordUpsertSchedule struct {
+	Schedule *model.Schedule `json:"schedule"`
+}
+
+// Представляет запись в WAL для удаления расписания
+type recordDeleteSchedule struct {
+	ID model.ScheduleID `json:"id"`
+}
+
+// UpsertSchedule сохраняет расписание
+func (s *Store) UpsertSchedule(sc *model.Schedule) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *sc
+	s.schedules[sc.ID] = &cp
+	return s.appendRecord(walRecord{Type: "upsert_schedule", Data: recordUpsertSchedule{Schedule: &cp}})
+}
+
+// DeleteSchedule удаляет расписание; задачи расписания не затрагиваются
+func (s *Store) DeleteSchedule(id model.ScheduleID) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[id]; !ok {
+		return nil
+	}
+	delete(s.schedules, id)
+	return s.appendRecord(walRecord{Type: "delete_schedule", Data: recordDeleteSchedule{ID: id}})
+}
+
+// GetSchedule возвращает копию расписания
+func (s *Store) GetSchedule(id model.ScheduleID) (*model.Schedule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sc, ok := s.schedules[id]
+	if !ok {
+		return nil, false
+	}
+	cp := *sc
+	return &cp, true
+}
+
+// ListSchedules возвращает копии расписаний в порядке создания
+func (s *Store) ListSchedules() []*model.Schedule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*model.Schedule, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		cp := *sc
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
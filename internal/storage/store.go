@@ -8,17 +8,45 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"taskservice/internal/model"
 )
 
+// Имена файлов хранилища
+const (
+	snapshotFileName = "state.snapshot.json"
+	extraFileName    = "state.extra.json"
+	walFileName      = "state.wal"
+)
+
+// Ошибка записи в хранилище, открытое только для чтения
+var ErrReadOnly = errors.New("store is read-only")
+
+// Ошибка обновления задачи, которой нет в хранилище, например уже удаленной
+var ErrTaskNotFound = errors.New("task not found")
+
 // Реализует долговечное хранилище с использованием WAL + snapshot в одной директории
 type Store struct {
-	dir       string
-	mu        sync.RWMutex
-	tasks     map[model.TaskID]*model.Task
-	walFile   *os.File
-	walWriter *bufio.Writer
+	dir      string
+	readOnly bool
+	mu       sync.RWMutex
+	tasks    map[model.TaskID]*model.Task
+	// Статусы задач на момент последнего сохранения или перехода; читаются без блокировки задачи
+	statuses   map[model.TaskID]model.TaskStatus
+	history    map[model.TaskID][]model.Transition
+	historySeq map[model.TaskID]int64
+	// Переходы, еще не записанные в WAL: записываются вместе со следующим сохранением задачи
+	pendingHistory map[model.TaskID][]model.Transition
+	blobs          map[string]*blobState
+	urls           map[string]URLRecord
+	uploads        map[string]Upload // незавершенные загрузки в удаленные приемники
+	schedules      map[model.ScheduleID]*model.Schedule
+	retention      HistoryRetention
+	walFile        *os.File
+	walWriter      *bufio.Writer
+	walTorn        bool  // WAL заканчивается незавершенной записью
+	walValid       int64 // смещение конца корректных записей WAL
 }
 
 // Представляет запись в WAL
@@ -29,13 +57,30 @@ type walRecord struct {
 
 // Представляет запись в WAL для upsert задачи
 type recordUpsertTask struct {
-	Task *model.Task `json:"task"`
+	Task    *model.Task        `json:"task"`
+	History []model.Transition `json:"history,omitempty"`
 }
 
 // Представляет запись в WAL для update задачи
 type recordUpdateTask struct {
+	TaskID  model.TaskID       `json:"task_id"`
+	Task    *model.Task        `json:"task"`
+	History []model.Transition `json:"history,omitempty"`
+}
+
+// Представляет запись в WAL для обновления одного элемента задачи и ее статуса
+type recordUpdateItem struct {
+	TaskID     model.TaskID       `json:"task_id"`
+	Index      int                `json:"index"`
+	Item       model.Item         `json:"item"`
+	Status     model.TaskStatus   `json:"status"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	History    []model.Transition `json:"history,omitempty"`
+}
+
+// Представляет запись в WAL для удаления задачи
+type recordDeleteTask struct {
 	TaskID model.TaskID `json:"task_id"`
-	Task   *model.Task  `json:"task"`
 }
 
 // Создает новый Store
@@ -43,7 +88,7 @@ func NewStore(dir string) (*Store, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, err
 	}
-	s := &Store{dir: dir, tasks: make(map[model.TaskID]*model.Task)}
+	s := newStore(dir, false)
 	if err := s.loadSnapshotAndWal(); err != nil {
 		return nil, err
 	}
@@ -53,11 +98,41 @@ func NewStore(dir string) (*Store, error) {
 	return s, nil
 }
 
+// Создает пустое состояние Store
+func newStore(dir string, readOnly bool) *Store {
+	return &Store{
+		dir:            dir,
+		readOnly:       readOnly,
+		tasks:          make(map[model.TaskID]*model.Task),
+		statuses:       make(map[model.TaskID]model.TaskStatus),
+		history:        make(map[model.TaskID][]model.Transition),
+		historySeq:     make(map[model.TaskID]int64),
+		pendingHistory: make(map[model.TaskID][]model.Transition),
+		blobs:          make(map[string]*blobState),
+		urls:           make(map[string]URLRecord),
+		uploads:        make(map[string]Upload),
+		schedules:      make(map[model.ScheduleID]*model.Schedule),
+	}
+}
+
+// Открывает Store только для чтения: файлы не создаются, WAL не открывается на запись
+func OpenReadOnly(dir string) (*Store, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	s := newStore(dir, true)
+	if err := s.loadSnapshotAndWal(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
 // Закрывает Store
 func (s *Store) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.walWriter != nil {
+		s.flushHistoryLocked()
 		s.walWriter.Flush()
 	}
 	if s.walFile != nil {
@@ -68,59 +143,168 @@ func (s *Store) Close() error {
 
 // Открывает WAL
 func (s *Store) openWal() error {
-	f, err := os.OpenFile(filepath.Join(s.dir, "state.wal"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	f, err := os.OpenFile(filepath.Join(s.dir, walFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
 	}
+	// Отбрасываем поврежденный хвост, чтобы новые записи не склеивались с ним
+	if s.walTorn {
+		if err := f.Truncate(s.walValid); err != nil {
+			f.Close()
+			return err
+		}
+	}
 	s.walFile = f
 	s.walWriter = bufio.NewWriter(f)
+	if fi, err := f.Stat(); err == nil {
+		walSizeBytes.Set(float64(fi.Size()))
+	}
 	return nil
 }
 
 // Загружает snapshot и WAL
 func (s *Store) loadSnapshotAndWal() error {
 	// Загружает snapshot
-	snapPath := filepath.Join(s.dir, "state.snapshot.json")
-	if b, err := os.ReadFile(snapPath); err == nil {
-		var snapshot map[model.TaskID]*model.Task
-		if err := json.Unmarshal(b, &snapshot); err == nil {
-			s.tasks = snapshot
-		}
-	}
-	// Воспроизводит WAL
-	walPath := filepath.Join(s.dir, "state.wal")
-	if f, err := os.OpenFile(walPath, os.O_RDONLY|os.O_CREATE, 0o644); err == nil {
-		defer f.Close()
-		dec := json.NewDecoder(f)
-		for {
-			var rec walRecord
-			if err := dec.Decode(&rec); err != nil {
-				if errors.Is(err, os.ErrClosed) {
-					break
-				}
-				break
+	if snapshot, err := readSnapshot(s.dir); err == nil && snapshot != nil {
+		for _, t := range snapshot {
+			s.putTaskLocked(t)
+		}
+	}
+	if extra, err := readExtra(s.dir); err == nil && extra != nil {
+		s.restoreExtra(extra)
+	}
+	// Воспроизводит WAL; поврежденный хвост игнорируется
+	walPath := filepath.Join(s.dir, walFileName)
+	if !s.readOnly {
+		if f, err := os.OpenFile(walPath, os.O_RDONLY|os.O_CREATE, 0o644); err == nil {
+			f.Close()
+		}
+	}
+	valid, err := scanWalFile(walPath, func(e WALEntry) error {
+		s.applyEntry(e)
+		return nil
+	})
+	s.walValid = valid
+	s.walTorn = errors.Is(err, ErrTornTail)
+	var walErr *WALError
+	if errors.Is(err, os.ErrNotExist) || errors.As(err, &walErr) {
+		return nil
+	}
+	return err
+}
+
+// Читает snapshot; отсутствующий snapshot не является ошибкой
+func readSnapshot(dir string) (map[model.TaskID]*model.Task, error) {
+	b, err := os.ReadFile(filepath.Join(dir, snapshotFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var snapshot map[model.TaskID]*model.Task
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Читает дополнительное состояние, сохраненное вместе со snapshot
+func readExtra(dir string) (*extraState, error) {
+	b, err := os.ReadFile(filepath.Join(dir, extraFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var extra extraState
+	if err := json.Unmarshal(b, &extra); err != nil {
+		return nil, err
+	}
+	return &extra, nil
+}
+
+// Применяет запись WAL к состоянию в памяти
+func (s *Store) applyEntry(e WALEntry) {
+	switch e.Type {
+	case "upsert_task":
+		var r recordUpsertTask
+		if err := json.Unmarshal(e.Data, &r); err == nil && r.Task != nil {
+			s.putTaskLocked(r.Task)
+			s.appendHistoryLocked(r.Task.ID, r.History)
+		}
+	case "update_task":
+		var r recordUpdateTask
+		// Обновление удаленной задачи ее не восстанавливает
+		if err := json.Unmarshal(e.Data, &r); err == nil && r.Task != nil {
+			if _, ok := s.tasks[r.TaskID]; ok {
+				s.tasks[r.TaskID] = r.Task
+				s.statuses[r.TaskID] = r.Task.Status
+				s.appendHistoryLocked(r.TaskID, r.History)
 			}
-			switch rec.Type {
-			case "upsert_task":
-				raw, _ := json.Marshal(rec.Data)
-				var r recordUpsertTask
-				if err := json.Unmarshal(raw, &r); err == nil {
-					s.tasks[r.Task.ID] = r.Task
-				}
-			case "update_task":
-				raw, _ := json.Marshal(rec.Data)
-				var r recordUpdateTask
-				if err := json.Unmarshal(raw, &r); err == nil {
-					s.tasks[r.TaskID] = r.Task
-				}
+		}
+	case "update_item":
+		var r recordUpdateItem
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			if t, ok := s.tasks[r.TaskID]; ok && r.Index >= 0 && r.Index < len(t.Items) {
+				t.Items[r.Index] = r.Item
+				t.Status, t.FinishedAt = r.Status, r.FinishedAt
+				s.statuses[r.TaskID] = r.Status
+				s.appendHistoryLocked(r.TaskID, r.History)
 			}
 		}
+	case "delete_task":
+		var r recordDeleteTask
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			s.deleteTaskLocked(r.TaskID)
+		}
+	case "append_history":
+		var r recordAppendHistory
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			s.appendHistoryLocked(r.TaskID, r.Transitions)
+		}
+	case "blob_ref":
+		var r recordBlobRef
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			s.addBlobRefLocked(r.SHA256, r.Size, r.Ref)
+		}
+	case "blob_release":
+		var r recordBlobRelease
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			s.releaseBlobRefsLocked(r.TaskID)
+		}
+	case "index_url":
+		var r recordIndexURL
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			s.urls[r.URL] = r.Record
+		}
+	case "save_upload":
+		var r recordSaveUpload
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			s.uploads[r.Key] = r.Upload
+		}
+	case "delete_upload":
+		var r recordDeleteUpload
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			delete(s.uploads, r.Key)
+		}
+	case "upsert_schedule":
+		var r recordUpsertSchedule
+		if err := json.Unmarshal(e.Data, &r); err == nil && r.Schedule != nil {
+			s.schedules[r.Schedule.ID] = r.Schedule
+		}
+	case "delete_schedule":
+		var r recordDeleteSchedule
+		if err := json.Unmarshal(e.Data, &r); err == nil {
+			delete(s.schedules, r.ID)
+		}
 	}
-	return nil
 }
 
 // Добавляет запись в WAL
 func (s *Store) appendRecord(rec walRecord) error {
+	start := time.Now()
 	b, err := json.Marshal(rec)
 	if err != nil {
 		return err
@@ -131,24 +315,45 @@ func (s *Store) appendRecord(rec walRecord) error {
 	if err := s.walWriter.Flush(); err != nil {
 		return err
 	}
-	return s.walFile.Sync()
+	walSizeBytes.Add(float64(len(b) + 1))
+	syncStart := time.Now()
+	err = s.walFile.Sync()
+	walFsyncSeconds.Observe(time.Since(syncStart).Seconds())
+	walAppendSeconds.Observe(time.Since(start).Seconds())
+	return err
 }
 
 // Сохраняет snapshot
 func (s *Store) SaveSnapshot() error {
-	s.mu.RLock()
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
 	snapshot := make(map[model.TaskID]*model.Task, len(s.tasks))
 	for k, v := range s.tasks {
 		snapshot[k] = v
 	}
-	s.mu.RUnlock()
+	extra := s.captureExtra()
+	s.mu.Unlock()
+	return s.writeSnapshot(snapshot, extra)
+}
+
+// Атомарно записывает snapshot и дополнительное состояние на диск
+func (s *Store) writeSnapshot(snapshot map[model.TaskID]*model.Task, extra *extraState) error {
+	defer func(start time.Time) { snapshotSeconds.Observe(time.Since(start).Seconds()) }(time.Now())
+	if err := writeJSONFile(filepath.Join(s.dir, extraFileName), extra); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(s.dir, snapshotFileName), snapshot)
+}
 
-	b, err := json.MarshalIndent(snapshot, "", "  ")
+// Атомарно записывает JSON файл через временный файл
+func writeJSONFile(final string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return err
 	}
-	tmp := filepath.Join(s.dir, "state.snapshot.json.tmp")
-	final := filepath.Join(s.dir, "state.snapshot.json")
+	tmp := final + ".tmp"
 	if err := os.WriteFile(tmp, b, 0o644); err != nil {
 		return err
 	}
@@ -158,20 +363,144 @@ func (s *Store) SaveSnapshot() error {
 	return nil
 }
 
+// ReplaceAll заменяет задачи, расписания и ссылки на файлы: записывает новый snapshot и очищает WAL.
+// Ссылки на задачи, которых нет в tasks, отбрасываются; индекс URL сохраняется для файлов, на которые есть ссылки
+func (s *Store) ReplaceAll(tasks []*model.Task, schedules []*model.Schedule, blobs []Blob) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := make(map[model.TaskID]*model.Task, len(tasks))
+	for _, t := range tasks {
+		next[t.ID] = t
+	}
+	// История сохраняется только для оставшихся задач
+	for id := range s.tasks {
+		if _, ok := next[id]; !ok {
+			s.deleteTaskLocked(id)
+		}
+	}
+	s.blobs = make(map[string]*blobState, len(blobs))
+	for _, b := range blobs {
+		for _, ref := range b.Refs {
+			if _, ok := next[ref.TaskID]; ok {
+				s.addBlobRefLocked(b.SHA256, b.Size, ref)
+			}
+		}
+	}
+	for url, rec := range s.urls {
+		if _, ok := s.blobs[rec.SHA256]; !ok {
+			delete(s.urls, url)
+		}
+	}
+	s.schedules = make(map[model.ScheduleID]*model.Schedule, len(schedules))
+	for _, sc := range schedules {
+		cp := *sc
+		s.schedules[sc.ID] = &cp
+	}
+	if err := s.writeSnapshot(next, s.captureExtra()); err != nil {
+		return err
+	}
+	if err := s.walWriter.Flush(); err != nil {
+		return err
+	}
+	if err := s.walFile.Truncate(0); err != nil {
+		return err
+	}
+	walSizeBytes.Set(0)
+	s.tasks = next
+	s.statuses = make(map[model.TaskID]model.TaskStatus, len(next))
+	for id, t := range next {
+		s.statuses[id] = t.Status
+	}
+	// История оставшихся задач, включая незаписанные переходы, сохранена в snapshot
+	s.pendingHistory = make(map[model.TaskID][]model.Transition)
+	return s.walFile.Sync()
+}
+
 // UpsertTask создает или обновляет задачу
 func (s *Store) UpsertTask(t *model.Task) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.tasks[t.ID] = t
-	return s.appendRecord(walRecord{Type: "upsert_task", Data: recordUpsertTask{Task: t}})
+	s.putTaskLocked(t)
+	trs := s.takeHistoryLocked(t.ID)
+	return s.restoreHistoryOnError(t.ID, trs,
+		s.appendRecord(walRecord{Type: "upsert_task", Data: recordUpsertTask{Task: t, History: trs}}))
 }
 
-// UpdateTask обновляет задачу
+// UpdateTask обновляет задачу; удаленная задача не восстанавливается, возвращается ErrTaskNotFound
 func (s *Store) UpdateTask(t *model.Task) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, ok := s.tasks[t.ID]; !ok {
+		return ErrTaskNotFound
+	}
+	s.putTaskLocked(t)
+	trs := s.takeHistoryLocked(t.ID)
+	return s.restoreHistoryOnError(t.ID, trs,
+		s.appendRecord(walRecord{Type: "update_task", Data: recordUpdateTask{TaskID: t.ID, Task: t, History: trs}}))
+}
+
+// UpdateItem сохраняет один элемент задачи вместе со статусом задачи; в отличие от UpdateTask
+// размер записи не зависит от числа элементов. Остальные поля задачи должны быть сохранены ранее
+func (s *Store) UpdateItem(t *model.Task, idx int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if idx < 0 || idx >= len(t.Items) {
+		return s.UpdateTask(t)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[t.ID]; !ok {
+		return ErrTaskNotFound
+	}
+	s.putTaskLocked(t)
+	trs := s.takeHistoryLocked(t.ID)
+	return s.restoreHistoryOnError(t.ID, trs, s.appendRecord(walRecord{Type: "update_item", Data: recordUpdateItem{
+		TaskID:     t.ID,
+		Index:      idx,
+		Item:       t.Items[idx],
+		Status:     t.Status,
+		FinishedAt: t.FinishedAt,
+		History:    trs,
+	}}))
+}
+
+// DeleteTask удаляет задачу вместе с ее историей
+func (s *Store) DeleteTask(id model.TaskID) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[id]; !ok {
+		return nil
+	}
+	s.deleteTaskLocked(id)
+	return s.appendRecord(walRecord{Type: "delete_task", Data: recordDeleteTask{TaskID: id}})
+}
+
+// Сохраняет задачу в памяти вместе с ее текущим статусом
+func (s *Store) putTaskLocked(t *model.Task) {
 	s.tasks[t.ID] = t
-	return s.appendRecord(walRecord{Type: "update_task", Data: recordUpdateTask{TaskID: t.ID, Task: t}})
+	s.statuses[t.ID] = t.Status
+}
+
+// Удаляет задачу из памяти
+func (s *Store) deleteTaskLocked(id model.TaskID) {
+	delete(s.tasks, id)
+	delete(s.statuses, id)
+	delete(s.history, id)
+	delete(s.historySeq, id)
+	delete(s.pendingHistory, id)
 }
 
 // ListTasks получает все задачи
@@ -193,6 +522,18 @@ func (s *Store) GetTask(id model.TaskID) (*model.Task, bool) {
 	return t, ok
 }
 
+// TaskStatuses возвращает статусы задач на момент их последнего сохранения или перехода в истории.
+// В отличие от полей задач из ListTasks, их можно читать без блокировки задачи
+func (s *Store) TaskStatuses() map[model.TaskID]model.TaskStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[model.TaskID]model.TaskStatus, len(s.statuses))
+	for id, st := range s.statuses {
+		out[id] = st
+	}
+	return out
+}
+
 // Debug helper
 func (s *Store) Stats() string {
 	s.mu.RLock()
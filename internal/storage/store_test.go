@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"taskservice/internal/model"
+)
+
+// Создает хранилище во временном каталоге
+func openTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return s
+}
+
+func newTestTask(id model.TaskID, n int) *model.Task {
+	t := &model.Task{ID: id, CreatedAt: time.Now(), Status: model.TaskStatusPending}
+	for i := 0; i < n; i++ {
+		t.Items = append(t.Items, model.Item{URL: "http://example.com/" + string(rune('a'+i)), Status: model.ItemStatusQueued})
+	}
+	return t
+}
+
+func TestUpdateDeletedTaskIsNotRestored(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	task := newTestTask("t1", 2)
+	if err := s.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Устаревший указатель на задачу после удаления
+	task.Items[0].Status = model.ItemStatusQueued
+	if err := s.UpdateItem(task, 0); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("UpdateItem of deleted task: got %v, want ErrTaskNotFound", err)
+	}
+	if err := s.UpdateTask(task); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("UpdateTask of deleted task: got %v, want ErrTaskNotFound", err)
+	}
+	if _, ok := s.GetTask(task.ID); ok {
+		t.Fatal("deleted task is back in memory")
+	}
+	s.Close()
+
+	s = openTestStore(t, dir)
+	defer s.Close()
+	if _, ok := s.GetTask(task.ID); ok {
+		t.Fatal("deleted task is back after WAL replay")
+	}
+}
+
+func TestWALReplayRestoresItemUpdates(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	task := newTestTask("t1", 3)
+	if err := s.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+	task.Items[1].Status = model.ItemStatusDone
+	task.Items[1].SHA256 = "abc"
+	task.Status = model.TaskStatusRunning
+	if err := s.UpdateItem(task, 1); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestStore(t, dir)
+	defer s.Close()
+	got, ok := s.GetTask(task.ID)
+	if !ok {
+		t.Fatal("task is lost after WAL replay")
+	}
+	if got.Status != model.TaskStatusRunning || got.Items[1].Status != model.ItemStatusDone || got.Items[1].SHA256 != "abc" {
+		t.Fatalf("replayed task: status %s, item %+v", got.Status, got.Items[1])
+	}
+	if got.Items[0].Status != model.ItemStatusQueued {
+		t.Fatalf("untouched item changed: %+v", got.Items[0])
+	}
+}
+
+func TestReplaceAllReplacesSchedulesAndBlobs(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	for _, id := range []model.TaskID{"old", "kept"} {
+		if err := s.UpsertTask(newTestTask(id, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddBlobRef("old-blob", 1, BlobRef{TaskID: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.IndexURL("http://example.com/old", URLRecord{SHA256: "old-blob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertSchedule(&model.Schedule{ID: "old-schedule"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.ReplaceAll([]*model.Task{newTestTask("kept", 1), newTestTask("new", 1)},
+		[]*model.Schedule{{ID: "new-schedule"}},
+		[]Blob{
+			{SHA256: "shared", Size: 2, Refs: []BlobRef{{TaskID: "kept"}, {TaskID: "new"}}},
+			{SHA256: "dangling", Size: 3, Refs: []BlobRef{{TaskID: "missing"}}},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestStore(t, dir)
+	defer s.Close()
+	if _, ok := s.GetTask("old"); ok {
+		t.Fatal("replaced task is still present")
+	}
+	if _, ok := s.GetSchedule("old-schedule"); ok {
+		t.Fatal("replaced schedule is still present")
+	}
+	if _, ok := s.GetSchedule("new-schedule"); !ok {
+		t.Fatal("imported schedule is missing")
+	}
+	if n := s.BlobRefCount("shared"); n != 2 {
+		t.Fatalf("shared blob has %d refs, want 2", n)
+	}
+	if s.HasBlob("old-blob") || s.HasBlob("dangling") {
+		t.Fatal("refs of tasks outside the new state are kept")
+	}
+	if _, ok := s.LookupURL("http://example.com/old"); ok {
+		t.Fatal("URL index points to a blob without refs")
+	}
+}
+
+func TestHistoryIsWrittenWithTaskUpdate(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	task := newTestTask("t1", 1)
+	at := time.Now()
+	if err := s.AppendHistory(task.ID, model.Transition{At: at, To: string(model.TaskStatusPending)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+	idx := 0
+	task.Status, task.Items[0].Status = model.TaskStatusRunning, model.ItemStatusDownloading
+	if err := s.AppendHistory(task.ID,
+		model.Transition{At: at, From: string(model.TaskStatusPending), To: string(model.TaskStatusRunning)},
+		model.Transition{At: at, ItemIdx: &idx, From: string(model.ItemStatusQueued), To: string(model.ItemStatusDownloading)},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateItem(task, 0); err != nil {
+		t.Fatal(err)
+	}
+	task.Status = model.TaskStatusCanceled
+	if err := s.AppendHistory(task.ID, model.Transition{At: at, From: string(model.TaskStatusRunning), To: string(model.TaskStatusCanceled)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// Каждое сохранение задачи - одна запись WAL вместе с переходами
+	var types []string
+	if _, err := ScanWAL(dir, func(e WALEntry) error {
+		types = append(types, e.Type)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 3 || types[0] != "upsert_task" || types[1] != "update_item" || types[2] != "update_task" {
+		t.Fatalf("wal records %v, want upsert_task, update_item, update_task", types)
+	}
+
+	// Воспроизведение WAL без Close, как после сбоя
+	r := openTestStore(t, dir)
+	defer r.Close()
+	h := r.GetHistory(task.ID)
+	want := []string{"pending", "running", "downloading", "canceled"}
+	if len(h) != len(want) {
+		t.Fatalf("history %+v, want %d transitions", h, len(want))
+	}
+	for i, tr := range h {
+		if tr.To != want[i] || tr.Seq != int64(i+1) {
+			t.Fatalf("transition %d: %+v, want to %s with seq %d", i, tr, want[i], i+1)
+		}
+	}
+	s.Close()
+}
+
+func TestPendingHistoryIsFlushedOnClose(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	task := newTestTask("t1", 1)
+	if err := s.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendHistory(task.ID, model.Transition{At: time.Now(), To: "pending"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s = openTestStore(t, dir)
+	defer s.Close()
+	if h := s.GetHistory(task.ID); len(h) != 1 || h[0].To != "pending" {
+		t.Fatalf("history %+v, want the transition written on close", h)
+	}
+}
+
+func TestTaskStatusesFollowSavedState(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	task := newTestTask("t1", 1)
+	if err := s.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+	// Статус, измененный без сохранения, не виден
+	task.Status = model.TaskStatusRunning
+	if got := s.TaskStatuses()["t1"]; got != model.TaskStatusPending {
+		t.Fatalf("status before save = %q, want pending", got)
+	}
+	if err := s.UpdateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	task.Status = model.TaskStatusCompleted
+	if err := s.UpdateItem(task, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertTask(newTestTask("t2", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTask("t2"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestStore(t, dir)
+	defer s.Close()
+	got := s.TaskStatuses()
+	if len(got) != 1 || got["t1"] != model.TaskStatusCompleted {
+		t.Fatalf("statuses after WAL replay = %v, want t1 completed", got)
+	}
+}
+
+func TestTaskStatusesFollowStatusTransitions(t *testing.T) {
+	s := openTestStore(t, t.TempDir())
+	defer s.Close()
+	task := newTestTask("t1", 1)
+	if err := s.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+	idx := 0
+	// Переход элемента не меняет статус задачи, переход задачи виден до ее сохранения
+	s.AppendHistory("t1", model.Transition{ItemIdx: &idx, To: string(model.ItemStatusDone)})
+	if got := s.TaskStatuses()["t1"]; got != model.TaskStatusPending {
+		t.Fatalf("status after item transition = %q, want pending", got)
+	}
+	s.AppendHistory("t1", model.Transition{From: string(model.TaskStatusPending), To: string(model.TaskStatusCompleted)})
+	if got := s.TaskStatuses()["t1"]; got != model.TaskStatusCompleted {
+		t.Fatalf("status after task transition = %q, want completed", got)
+	}
+	// История удаленной задачи не возвращает ее статус
+	s.DeleteTask("t1")
+	s.AppendHistory("t1", model.Transition{To: string(model.TaskStatusFailed)})
+	if _, ok := s.TaskStatuses()["t1"]; ok {
+		t.Fatal("deleted task has a status")
+	}
+}
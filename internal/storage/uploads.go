@@ -0,0 +1,68 @@
+package storage
+
+import "time"
+
+// Незавершенная составная загрузка в удаленный приемник
+type Upload struct {
+	UploadID  string       `json:"upload_id"`
+	SHA256    string       `json:"sha256"`
+	Size      int64        `json:"size"`
+	PartSize  int64        `json:"part_size"`
+	Parts     []UploadPart `json:"parts,omitempty"`
+	StartedAt time.Time    `json:"started_at"`
+}
+
+// Загруженная часть составной загрузки
+type UploadPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// Представляет запись в WAL для сохранения состояния загрузки
+type recordSaveUpload struct {
+	Key    string `json:"key"`
+	Upload Upload `json:"upload"`
+}
+
+// Представляет запись в WAL для удаления состояния загрузки
+type recordDeleteUpload struct {
+	Key string `json:"key"`
+}
+
+// SaveUpload сохраняет состояние составной загрузки объекта key
+func (s *Store) SaveUpload(key string, u Upload) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u.Parts = append([]UploadPart(nil), u.Parts...)
+	s.uploads[key] = u
+	return s.appendRecord(walRecord{Type: "save_upload", Data: recordSaveUpload{Key: key, Upload: u}})
+}
+
+// GetUpload возвращает состояние составной загрузки объекта key
+func (s *Store) GetUpload(key string) (Upload, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.uploads[key]
+	if ok {
+		u.Parts = append([]UploadPart(nil), u.Parts...)
+	}
+	return u, ok
+}
+
+// DeleteUpload забывает состояние загрузки объекта key
+func (s *Store) DeleteUpload(key string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.uploads[key]; !ok {
+		return nil
+	}
+	delete(s.uploads, key)
+	return s.appendRecord(walRecord{Type: "delete_upload", Data: recordDeleteUpload{Key: key}})
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+
+	"taskservice/internal/model"
+)
+
+// Результат сверки snapshot и WAL
+type VerifyReport struct {
+	SnapshotTasks  int
+	SnapshotError  string
+	WALRecords     int
+	WALBytes       int64
+	WALError       string
+	UnknownRecords []int64        // смещения записей неизвестного типа
+	OrphanUpdates  []int64        // смещения update_task и update_item для задач, которые не создавались
+	OnlyInSnapshot []model.TaskID // задачи без записей в WAL
+	OnlyInWAL      []model.TaskID // задачи, отсутствующие в snapshot
+	Matching       []model.TaskID // состояние в snapshot совпадает с результатом WAL
+	Diverging      []model.TaskID // snapshot отстает или расходится с WAL
+}
+
+// Есть ли в хранилище ошибки, мешающие корректной загрузке
+func (r *VerifyReport) OK() bool {
+	return r.SnapshotError == "" && r.WALError == "" && len(r.UnknownRecords) == 0 && len(r.OrphanUpdates) == 0
+}
+
+// Сверяет snapshot и WAL в директории хранилища, ничего не изменяя
+func Verify(dir string) (*VerifyReport, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	rep := &VerifyReport{}
+	snapshot, err := readSnapshot(dir)
+	if err != nil {
+		rep.SnapshotError = err.Error()
+	}
+	rep.SnapshotTasks = len(snapshot)
+
+	// Воспроизводим WAL поверх snapshot и отдельно запоминаем задачи, затронутые WAL
+	replayed := newStore(dir, true)
+	for id, t := range snapshot {
+		replayed.tasks[id] = t
+	}
+	touched := make(map[model.TaskID]bool)
+	valid, err := ScanWAL(dir, func(e WALEntry) error {
+		rep.WALRecords++
+		switch e.Type {
+		case "upsert_task":
+			touched[e.TaskID] = true
+		case "update_task", "update_item":
+			if _, ok := replayed.tasks[e.TaskID]; !ok {
+				rep.OrphanUpdates = append(rep.OrphanUpdates, e.Offset)
+			}
+			touched[e.TaskID] = true
+		case "delete_task":
+			touched[e.TaskID] = true
+		case "append_history", "blob_ref", "blob_release", "index_url", "save_upload", "delete_upload",
+			"upsert_schedule", "delete_schedule":
+		default:
+			rep.UnknownRecords = append(rep.UnknownRecords, e.Offset)
+		}
+		replayed.applyEntry(e)
+		return nil
+	})
+	rep.WALBytes = valid
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		rep.WALError = err.Error()
+	}
+
+	for id := range snapshot {
+		if !touched[id] {
+			rep.OnlyInSnapshot = append(rep.OnlyInSnapshot, id)
+		}
+	}
+	for id := range touched {
+		snap, inSnapshot := snapshot[id]
+		final, ok := replayed.tasks[id]
+		switch {
+		case !ok:
+		case !inSnapshot:
+			rep.OnlyInWAL = append(rep.OnlyInWAL, id)
+		case sameTask(snap, final):
+			rep.Matching = append(rep.Matching, id)
+		default:
+			rep.Diverging = append(rep.Diverging, id)
+		}
+	}
+	for _, ids := range [][]model.TaskID{rep.OnlyInSnapshot, rep.OnlyInWAL, rep.Matching, rep.Diverging} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return rep, nil
+}
+
+// Сравнивает задачи по их JSON представлению
+func sameTask(a, b *model.Task) bool {
+	ab, err1 := json.Marshal(a)
+	bb, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(ab, bb)
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"taskservice/internal/model"
+)
+
+func appendWAL(t *testing.T, dir, lines string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(lines); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	for _, id := range []model.TaskID{"same", "ahead", "old"} {
+		if err := s.UpsertTask(newTestTask(id, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	// После snapshot: "ahead" меняется только в WAL, "new" появляется только в WAL
+	ahead, _ := s.GetTask("ahead")
+	ahead.Status = model.TaskStatusRunning
+	if err := s.UpdateTask(ahead); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertTask(newTestTask("new", 1)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	// Snapshot без WAL для "old": переписываем WAL без записей о нем
+	wal, err := os.ReadFile(filepath.Join(dir, walFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []byte
+	if _, err := ScanWAL(dir, func(e WALEntry) error {
+		if e.TaskID != "old" {
+			kept = append(kept, wal[e.Offset:e.Offset+int64(e.Size)]...)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, walFileName), kept, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.OK() {
+		t.Fatalf("report %+v is not OK", rep)
+	}
+	check := func(name string, got []model.TaskID, want ...model.TaskID) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s = %v, want %v", name, got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("%s = %v, want %v", name, got, want)
+			}
+		}
+	}
+	if rep.SnapshotTasks != 3 || rep.WALRecords != 4 {
+		t.Fatalf("snapshot tasks %d, wal records %d, want 3 and 4", rep.SnapshotTasks, rep.WALRecords)
+	}
+	check("OnlyInSnapshot", rep.OnlyInSnapshot, "old")
+	check("OnlyInWAL", rep.OnlyInWAL, "new")
+	check("Matching", rep.Matching, "same")
+	check("Diverging", rep.Diverging, "ahead")
+}
+
+func TestVerifyReportsDamage(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	if err := s.UpsertTask(newTestTask("t1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	appendWAL(t, dir, `{"type":"update_task","data":{"task_id":"ghost","task":{"id":"ghost"}}}`+"\n"+
+		`{"type":"mystery","data":{}}`+"\n"+
+		`{"type":"upsert_task","data":{"task":{"id":"t`)
+
+	rep, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.OK() {
+		t.Fatal("damaged storage reported as OK")
+	}
+	if len(rep.OrphanUpdates) != 1 || len(rep.UnknownRecords) != 1 {
+		t.Fatalf("orphan updates %v, unknown records %v, want one of each", rep.OrphanUpdates, rep.UnknownRecords)
+	}
+	if rep.WALError == "" {
+		t.Fatal("torn tail is not reported")
+	}
+	if rep.WALRecords != 3 {
+		t.Fatalf("wal records %d, want 3 before the torn tail", rep.WALRecords)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("OpenReadOnly of missing dir: %v, want ErrNotExist", err)
+	}
+	empty := t.TempDir()
+	ro, err := OpenReadOnly(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(empty); len(entries) != 0 {
+		t.Fatalf("read-only open created %d files", len(entries))
+	}
+	if err := ro.UpsertTask(newTestTask("t1", 1)); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("UpsertTask on read-only store: %v, want ErrReadOnly", err)
+	}
+
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	if err := s.UpsertTask(newTestTask("t1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	ro, err = OpenReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ro.GetTask("t1"); !ok {
+		t.Fatal("read-only store did not replay the WAL")
+	}
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"taskservice/internal/model"
+)
+
+// Запись WAL, прочитанная с диска
+type WALEntry struct {
+	Offset int64           `json:"offset"`
+	Size   int             `json:"size"`
+	Type   string          `json:"type"`
+	TaskID model.TaskID    `json:"task_id,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Незавершенная запись в конце WAL (например, после сбоя питания)
+var ErrTornTail = errors.New("truncated record at end of wal")
+
+// Ошибка разбора WAL с указанием смещения
+type WALError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *WALError) Error() string { return fmt.Sprintf("wal offset %d: %v", e.Offset, e.Err) }
+func (e *WALError) Unwrap() error { return e.Err }
+
+// Последовательно читает записи WAL из директории хранилища.
+// Возвращает смещение конца последней корректной записи.
+func ScanWAL(dir string, fn func(WALEntry) error) (int64, error) {
+	return scanWalFile(filepath.Join(dir, walFileName), fn)
+}
+
+// Читает записи из файла WAL, по одной JSON записи на строку
+func scanWalFile(path string, fn func(WALEntry) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && err == io.EOF {
+			return offset, &WALError{Offset: offset, Err: ErrTornTail}
+		}
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, &WALError{Offset: offset, Err: err}
+		}
+		e, perr := parseWalLine(bytes.TrimSpace(line))
+		if perr != nil {
+			return offset, &WALError{Offset: offset, Err: perr}
+		}
+		e.Offset = offset
+		e.Size = len(line)
+		if err := fn(e); err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+	}
+}
+
+// Разбирает строку WAL
+func parseWalLine(line []byte) (WALEntry, error) {
+	var e WALEntry
+	if err := json.Unmarshal(line, &e); err != nil {
+		return e, err
+	}
+	if e.Type == "" {
+		return e, errors.New("record without type")
+	}
+	var ref struct {
+		TaskID model.TaskID `json:"task_id"`
+		Task   *struct {
+			ID model.TaskID `json:"id"`
+		} `json:"task"`
+	}
+	if err := json.Unmarshal(e.Data, &ref); err == nil {
+		e.TaskID = ref.TaskID
+		if e.TaskID == "" && ref.Task != nil {
+			e.TaskID = ref.Task.ID
+		}
+	}
+	return e, nil
+}